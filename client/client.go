@@ -0,0 +1,384 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultPoolSize    = 4
+	DefaultDialTimeout = time.Second
+	DefaultTimeout     = 5 * time.Second
+)
+
+var ErrClientClosed = errors.New("client error: client is closed")
+
+// Client keeps pool of connections to server, it is safe for concurrent use.
+// Connections are dialed on first use and redialed if they are broken, e.g. server is restarted.
+type Client struct {
+	address     string
+	dialTimeout time.Duration
+	timeout     time.Duration
+	user        string
+	password    string
+	tlsConfig   *tls.Config
+
+	slots  []*slot
+	next   atomic.Uint64
+	closed atomic.Bool
+}
+
+type slot struct {
+	mx   sync.Mutex
+	conn *Conn
+}
+
+type Option func(*Client)
+
+// WithPoolSize sets number of connections, commands sent concurrently by one connection are pipelined.
+func WithPoolSize(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.slots = make([]*slot, size)
+		}
+	}
+}
+
+// WithDialTimeout limits time of connecting to server and handshake.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.dialTimeout = timeout
+	}
+}
+
+// WithTimeout limits time of command if context has no deadline.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithCredentials makes every connection authenticate with AUTH right after handshake.
+func WithCredentials(user string, password string) Option {
+	return func(c *Client) {
+		c.user = user
+		c.password = password
+	}
+}
+
+// WithTLS makes connections use TLS, client certificate of config is used if server verifies clients.
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+func New(address string, opts ...Option) *Client {
+	c := &Client{
+		address:     address,
+		dialTimeout: DefaultDialTimeout,
+		timeout:     DefaultTimeout,
+		slots:       make([]*slot, DefaultPoolSize),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for i := range c.slots {
+		c.slots[i] = &slot{}
+	}
+
+	return c
+}
+
+// Get returns value of key, ok is false if key doesn't exist.
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+
+	return reply.Value, !reply.IsNil(), nil
+}
+
+func (c *Client) Set(ctx context.Context, key string, value string) error {
+	_, err := c.Do(ctx, "SET", key, value)
+	return err
+}
+
+// SetNX sets key only if it doesn't exist, ok is true if key is set.
+func (c *Client) SetNX(ctx context.Context, key string, value string) (bool, error) {
+	reply, err := c.Do(ctx, "SETNX", key, value)
+	if err != nil {
+		return false, err
+	}
+
+	return reply.Integer == 1, nil
+}
+
+// GetSet sets key and returns its previous value, ok is false if key didn't exist.
+func (c *Client) GetSet(ctx context.Context, key string, value string) (string, bool, error) {
+	reply, err := c.Do(ctx, "GETSET", key, value)
+	if err != nil {
+		return "", false, err
+	}
+
+	return reply.Value, !reply.IsNil(), nil
+}
+
+// CAS replaces value of key by value only if it equals expected one, ok is true if value is replaced.
+// Key keeps its expiration time.
+func (c *Client) CAS(ctx context.Context, key string, expected string, value string) (bool, error) {
+	reply, err := c.Do(ctx, "CAS", key, expected, value)
+	if err != nil {
+		return false, err
+	}
+
+	return reply.Integer == 1, nil
+}
+
+// Del deletes keys and returns number of deleted ones, missing keys are skipped.
+func (c *Client) Del(ctx context.Context, keys ...string) (int, error) {
+	reply, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(reply.Integer), nil
+}
+
+// MGet returns values of keys, missing keys are absent in result.
+func (c *Client) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	reply, err := c.Do(ctx, append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.Array) != len(keys) {
+		return nil, fmt.Errorf("unexpected mget reply: %d items for %d keys", len(reply.Array), len(keys))
+	}
+
+	values := make(map[string]string, len(keys))
+	for i, item := range reply.Array {
+		if !item.IsNil() {
+			values[keys[i]] = item.Value
+		}
+	}
+	return values, nil
+}
+
+// MSet atomically sets all keys to their values, other clients never see only part of them set.
+func (c *Client) MSet(ctx context.Context, values map[string]string) error {
+	args := make([]string, 0, 1+2*len(values))
+	args = append(args, "MSET")
+	for key, value := range values {
+		args = append(args, key, value)
+	}
+
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Incr atomically increments integer value of key and returns new value, missing key is counted from zero.
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	return c.counter(ctx, "INCR", key)
+}
+
+// Decr atomically decrements integer value of key, see Incr.
+func (c *Client) Decr(ctx context.Context, key string) (int64, error) {
+	return c.counter(ctx, "DECR", key)
+}
+
+// IncrBy atomically adds delta to integer value of key, see Incr.
+func (c *Client) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.counter(ctx, "INCRBY", key, strconv.FormatInt(delta, 10))
+}
+
+// IncrByFloat atomically adds delta to float value of key and returns new value.
+func (c *Client) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
+	reply, err := c.Do(ctx, "INCRBYFLOAT", key, strconv.FormatFloat(delta, 'f', -1, 64))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(reply.Value, 64)
+}
+
+func (c *Client) counter(ctx context.Context, args ...string) (int64, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return reply.Integer, nil
+}
+
+// Scan returns page of keys matching pattern and cursor of the next page, zero cursor starts and finishes iteration.
+// Empty pattern matches any key, count is a hint of page size, zero means default of server.
+func (c *Client) Scan(ctx context.Context, cursor uint64, pattern string, count int) ([]string, uint64, error) {
+	args := []string{"SCAN", strconv.FormatUint(cursor, 10)}
+	if pattern != "" {
+		args = append(args, "MATCH", pattern)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(reply.Array) != 2 {
+		return nil, 0, fmt.Errorf("unexpected scan reply: %d items", len(reply.Array))
+	}
+
+	next, err := strconv.ParseUint(reply.Array[0].Value, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unexpected scan cursor: %w", err)
+	}
+
+	return values(reply.Array[1]), next, nil
+}
+
+// Keys returns all keys matching pattern, prefer Scan for large databases.
+func (c *Client) Keys(ctx context.Context, pattern string) ([]string, error) {
+	reply, err := c.Do(ctx, "KEYS", pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return values(reply), nil
+}
+
+// Range returns keys between start and end inclusive in ascending or, if reverse is set, descending order.
+// Limit zero means no limit. Server must use ordered engine, otherwise ErrNotOrdered is returned.
+func (c *Client) Range(ctx context.Context, start string, end string, limit int, reverse bool) ([]string, error) {
+	command := "RANGE"
+	if reverse {
+		command = "REVRANGE"
+	}
+	return c.keyRange(ctx, []string{command, start, end}, limit)
+}
+
+// Prefix returns keys starting with prefix, see Range.
+func (c *Client) Prefix(ctx context.Context, prefix string, limit int, reverse bool) ([]string, error) {
+	command := "PREFIX"
+	if reverse {
+		command = "REVPREFIX"
+	}
+	return c.keyRange(ctx, []string{command, prefix}, limit)
+}
+
+func (c *Client) keyRange(ctx context.Context, args []string, limit int) ([]string, error) {
+	if limit > 0 {
+		args = append(args, "LIMIT", strconv.Itoa(limit))
+	}
+
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return values(reply), nil
+}
+
+// Do sends any command, error reply of server is returned as *Error which matches server errors, e.g. ErrUnknownCommandType.
+func (c *Client) Do(ctx context.Context, args ...string) (Reply, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	return conn.Do(ctx, args...)
+}
+
+// Pipeline sends commands by one connection at once, see Conn.Pipeline.
+func (c *Client) Pipeline(ctx context.Context, commands ...[]string) ([]Reply, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.Pipeline(ctx, commands...)
+}
+
+// Close closes all connections, client can't be used after it.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+
+	var errs []error
+	for _, s := range c.slots {
+		s.mx.Lock()
+		if s.conn != nil {
+			errs = append(errs, s.conn.Close())
+			s.conn = nil
+		}
+		s.mx.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// conn returns connection of next slot, broken connection is replaced by new one.
+func (c *Client) conn(ctx context.Context) (*Conn, error) {
+	if c.closed.Load() {
+		return nil, ErrClientClosed
+	}
+
+	s := c.slots[c.next.Add(1)%uint64(len(c.slots))]
+
+	defer s.mx.Unlock()
+	s.mx.Lock()
+
+	if s.conn != nil && s.conn.Err() == nil {
+		return s.conn, nil
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	dialCtx := ctx
+	if c.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, c.dialTimeout)
+		defer cancel()
+	}
+
+	conn, err := DialTLS(dialCtx, c.address, c.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" {
+		if _, err := conn.Do(dialCtx, "AUTH", c.user, c.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	// client may be closed while dialing
+	if c.closed.Load() {
+		conn.Close()
+		return nil, ErrClientClosed
+	}
+	s.conn = conn
+
+	return conn, nil
+}
+
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
+}
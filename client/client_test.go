@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetSetDel(t *testing.T) {
+	c := New(startServer(t), WithPoolSize(2))
+	defer c.Close()
+	ctx := context.Background()
+
+	_, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "key", "binary\x00\nvalue"))
+
+	value, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "binary\x00\nvalue", value)
+
+	deleted, err := c.Del(ctx, "key", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, ok, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClient_MultiKey(t *testing.T) {
+	c := New(startServer(t))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.MSet(ctx, map[string]string{"a": "1", "b": "2"}))
+
+	values, err := c.MGet(ctx, "a", "missing", "b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, values)
+
+	deleted, err := c.Del(ctx, "a", "b", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+}
+
+func TestClient_ConditionalSet(t *testing.T) {
+	c := New(startServer(t))
+	defer c.Close()
+	ctx := context.Background()
+
+	ok, err := c.SetNX(ctx, "leader", "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.SetNX(ctx, "leader", "b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.CAS(ctx, "leader", "b", "c")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.CAS(ctx, "leader", "a", "c")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	previous, ok, err := c.GetSet(ctx, "leader", "d")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "c", previous)
+
+	_, ok, err = c.GetSet(ctx, "missing", "d")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClient_Counters(t *testing.T) {
+	c := New(startServer(t))
+	defer c.Close()
+	ctx := context.Background()
+
+	n, err := c.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = c.IncrBy(ctx, "counter", 41)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	n, err = c.Decr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(41), n)
+
+	f, err := c.IncrByFloat(ctx, "counter", 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 41.5, f)
+
+	_, err = c.Incr(ctx, "counter")
+	assert.ErrorIs(t, err, ErrNotInteger)
+}
+
+func TestClient_Info(t *testing.T) {
+	c := New(startServer(t))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "key", "value"))
+
+	reply, err := c.Do(ctx, "INFO", "keyspace")
+	require.NoError(t, err)
+	require.Equal(t, MapReply, reply.Type)
+
+	keyspace, ok := reply.Get("keyspace")
+	require.True(t, ok)
+	keys, ok := keyspace.Get("keys")
+	require.True(t, ok)
+	assert.Equal(t, Reply{Type: IntegerReply, Integer: 1}, keys)
+}
+
+func TestClient_Scan(t *testing.T) {
+	c := New(startServer(t))
+	defer c.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"user:1", "user:2", "order:1"} {
+		require.NoError(t, c.Set(ctx, key, "value"))
+	}
+
+	var keys []string
+	var cursor uint64
+	for {
+		page, next, err := c.Scan(ctx, cursor, "user:*", 1)
+		require.NoError(t, err)
+		keys = append(keys, page...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.ElementsMatch(t, []string{"user:1", "user:2"}, keys)
+
+	keys, err := c.Keys(ctx, "order:*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order:1"}, keys)
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	c := New(startServer(t))
+	defer c.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr error
+	}{
+		{
+			name:    "unknown command",
+			args:    []string{"UNKNOWN", "key"},
+			wantErr: ErrUnknownCommandType,
+		},
+		{
+			name:    "no enough arguments",
+			args:    []string{"GET"},
+			wantErr: ErrNoEnoughArgumentsForGetCommand,
+		},
+		{
+			name:    "too many arguments",
+			args:    []string{"GET", "key", "extra"},
+			wantErr: ErrTooManyArguments,
+		},
+		{
+			name:    "invalid expire time",
+			args:    []string{"SET", "key", "value", "EX", "soon"},
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "exec without multi",
+			args:    []string{"EXEC"},
+			wantErr: ErrExecWithoutMulti,
+		},
+		{
+			name:    "unknown info section",
+			args:    []string{"INFO", "unknown"},
+			wantErr: ErrUnknownInfoSection,
+		},
+		{
+			name:    "invalid scan cursor",
+			args:    []string{"SCAN", "next"},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "range of unordered engine",
+			args:    []string{"RANGE", "a", "z"},
+			wantErr: ErrNotOrdered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.Do(ctx, tt.args...)
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			var serverErr *Error
+			require.ErrorAs(t, err, &serverErr)
+			assert.Equal(t, "ERR", serverErr.Code)
+		})
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	cfg := config.Default()
+	cfg.Network.IdleTimeout = config.Timeout(50 * time.Millisecond)
+
+	c := New(startServerWithConfig(t, cfg), WithPoolSize(1))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "key", "value"))
+	conn := c.slots[0].conn
+
+	// server closes idle connection with BYE
+	require.Eventually(t, func() bool { return conn.Err() != nil }, time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, conn.Err(), ErrServerBye)
+
+	value, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+	assert.NotSame(t, conn, c.slots[0].conn)
+}
+
+func TestClient_Credentials(t *testing.T) {
+	hash, err := auth.HashPassword("secret", 1000)
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.Auth.Users = []config.ConfigUser{
+		{Name: "reader", Password: hash, Commands: []string{"GET"}, Keys: []string{"public:*"}},
+	}
+	address := startServerWithConfig(t, cfg)
+	ctx := context.Background()
+
+	anonymous := New(address)
+	defer anonymous.Close()
+	_, _, err = anonymous.Get(ctx, "public:1")
+	assert.ErrorIs(t, err, ErrAuthRequired)
+
+	wrong := New(address, WithCredentials("reader", "wrong"))
+	defer wrong.Close()
+	_, _, err = wrong.Get(ctx, "public:1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	reader := New(address, WithCredentials("reader", "secret"))
+	defer reader.Close()
+	_, ok, err := reader.Get(ctx, "public:1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	err = reader.Set(ctx, "public:1", "value")
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	c := New("127.0.0.1:1", WithDialTimeout(10*time.Millisecond))
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, _, err := c.Get(ctx, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_Closed(t *testing.T) {
+	c := New(startServer(t))
+	require.NoError(t, c.Set(context.Background(), "key", "value"))
+	require.NoError(t, c.Close())
+
+	err := c.Set(context.Background(), "key", "value")
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
@@ -0,0 +1,253 @@
+// Package client talks to simple-kv server by binary framing protocol.
+// Client keeps pool of connections, Conn is a single connection which pipelines concurrent commands.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MitrickX/simple-kv/protocol"
+)
+
+const handshakeTimeout = time.Second
+
+var (
+	ErrClosed             = errors.New("client error: connection is closed")
+	ErrServerBye          = errors.New("client error: server closed connection")
+	ErrHandshake          = errors.New("client error: handshake failed")
+	ErrFramesNotSupported = errors.New("client error: server doesn't support binary frames")
+)
+
+// Conn is a connection to server, it is safe for concurrent use.
+// Commands of concurrent calls are pipelined: they are sent without waiting for replies to previous ones,
+// replies are matched to commands by request IDs.
+type Conn struct {
+	conn net.Conn
+
+	writeMx sync.Mutex
+	writer  *protocol.Writer
+
+	mx      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan protocol.Response
+	err     error
+	// closed when connection is broken or closed, err keeps the reason
+	done chan struct{}
+}
+
+// Dial connects to server and negotiates binary framing protocol.
+func Dial(ctx context.Context, address string) (*Conn, error) {
+	return DialTLS(ctx, address, nil)
+}
+
+// DialTLS connects to server over TLS, plain TCP is used if config is nil.
+func DialTLS(ctx context.Context, address string, tlsConfig *tls.Config) (*Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if tlsConfig != nil {
+		d := tls.Dialer{Config: tlsConfig}
+		conn, err = d.DialContext(ctx, "tcp", address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := handshake(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Conn{
+		conn:    conn,
+		writer:  protocol.NewWriter(conn),
+		pending: make(map[uint64]chan protocol.Response),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+
+	return c, nil
+}
+
+func handshake(ctx context.Context, conn net.Conn) error {
+	deadline := time.Now().Add(handshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	defer conn.SetDeadline(time.Time{})
+
+	version := strconv.Itoa(protocol.Version)
+	if _, err := conn.Write([]byte(protocol.MessageHello + " " + version)); err != nil {
+		return fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+
+	switch string(buf[:n]) {
+	case protocol.MessageHi + " " + version:
+		return nil
+	case protocol.MessageHi:
+		return ErrFramesNotSupported
+	default:
+		return fmt.Errorf("%w: unexpected reply %q", ErrHandshake, buf[:n])
+	}
+}
+
+// Do sends command and waits for its reply. Error reply of server is returned as *Error.
+func (c *Conn) Do(ctx context.Context, args ...string) (Reply, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	return replies[0], replies[0].Err
+}
+
+// Pipeline sends all commands at once and waits for all replies, so it takes one round trip.
+// Error replies of server are kept in replies, only connection errors are returned.
+func (c *Conn) Pipeline(ctx context.Context, commands ...[]string) ([]Reply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ids, waits, err := c.send(ctx, commands)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make([]Reply, len(commands))
+	for i, wait := range waits {
+		select {
+		case response := <-wait:
+			replies[i] = NewReply(response)
+		case <-ctx.Done():
+			c.forget(ids[i:])
+			return nil, ctx.Err()
+		case <-c.done:
+			return nil, c.err
+		}
+	}
+
+	return replies, nil
+}
+
+// Err returns reason why connection is broken or closed, nil means connection may be used.
+func (c *Conn) Err() error {
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	return c.err
+}
+
+// Close closes connection, commands waiting for replies fail with ErrClosed.
+func (c *Conn) Close() error {
+	c.fail(ErrClosed)
+	return c.conn.Close()
+}
+
+func (c *Conn) send(ctx context.Context, commands [][]string) ([]uint64, []chan protocol.Response, error) {
+	ids := make([]uint64, 0, len(commands))
+	waits := make([]chan protocol.Response, 0, len(commands))
+
+	c.mx.Lock()
+	if c.err != nil {
+		c.mx.Unlock()
+		return nil, nil, c.err
+	}
+	for range commands {
+		c.nextID++
+		wait := make(chan protocol.Response, 1)
+		c.pending[c.nextID] = wait
+		ids = append(ids, c.nextID)
+		waits = append(waits, wait)
+	}
+	c.mx.Unlock()
+
+	defer c.writeMx.Unlock()
+	c.writeMx.Lock()
+
+	deadline, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(deadline)
+
+	for i, args := range commands {
+		if err := c.writer.WriteRequest(protocol.NewRequest(ids[i], args)); err != nil {
+			c.fail(err)
+			return nil, nil, err
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		c.fail(err)
+		return nil, nil, err
+	}
+
+	return ids, waits, nil
+}
+
+// readLoop dispatches replies to waiting commands by request IDs until connection is broken.
+func (c *Conn) readLoop() {
+	reader := protocol.NewReader(c.conn, 0)
+	for {
+		id, response, err := reader.ReadResponse()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		// request ID 0 is used by server for its own messages
+		if id == 0 {
+			if response.Type == protocol.StatusResponse && response.Status == protocol.MessageBye {
+				c.fail(ErrServerBye)
+				return
+			}
+			continue
+		}
+
+		c.mx.Lock()
+		wait, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mx.Unlock()
+
+		// command may be already canceled
+		if ok {
+			wait <- response
+		}
+	}
+}
+
+// forget drops commands which nobody waits for anymore.
+func (c *Conn) forget(ids []uint64) {
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	for _, id := range ids {
+		delete(c.pending, id)
+	}
+}
+
+// fail marks connection as broken, the first reason is kept.
+func (c *Conn) fail(err error) {
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.pending = nil
+	close(c.done)
+}
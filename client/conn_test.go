@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/db"
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/network"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// startServer starts in-memory server on random port and returns its address.
+func startServer(tb testing.TB) string {
+	tb.Helper()
+
+	cfg := config.Default()
+	cfg.Network.MaxConnections = 100
+
+	return startServerWithConfig(tb, cfg)
+}
+
+func startServerWithConfig(tb testing.TB, cfg config.Config) string {
+	tb.Helper()
+
+	acl, err := auth.NewACL(cfg.Auth)
+	require.NoError(tb, err)
+
+	st := storage.NewStorage(engine.NewEngine())
+	d := db.NewDB(interpreter.NewInterpreter(parser.NewParser()), st, db.WithACL(acl))
+	server := network.NewTcpServer(&cfg, d, zap.NewNop())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	go server.Serve(context.Background(), ln)
+	tb.Cleanup(func() { ln.Close() })
+
+	return ln.Addr().String()
+}
+
+func dial(tb testing.TB) *Conn {
+	tb.Helper()
+
+	conn, err := Dial(context.Background(), startServer(tb))
+	require.NoError(tb, err)
+	tb.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestConn_Do(t *testing.T) {
+	conn := dial(t)
+	ctx := context.Background()
+
+	reply, err := conn.Do(ctx, "SET", "key", "line1\nline2")
+	require.NoError(t, err)
+	assert.Equal(t, Reply{Type: StatusReply, Status: "OK"}, reply)
+
+	reply, err = conn.Do(ctx, "GET", "key")
+	require.NoError(t, err)
+	assert.Equal(t, Reply{Type: ValueReply, Value: "line1\nline2"}, reply)
+
+	reply, err = conn.Do(ctx, "TTL", "key")
+	require.NoError(t, err)
+	assert.Equal(t, Reply{Type: IntegerReply, Integer: -1}, reply)
+
+	reply, err = conn.Do(ctx, "GET", "unknown")
+	require.NoError(t, err)
+	assert.True(t, reply.IsNil())
+
+	_, err = conn.Do(ctx, "UNKNOWN", "key")
+	var serverErr *Error
+	require.True(t, errors.As(err, &serverErr), "got %v", err)
+	assert.Equal(t, "ERR", serverErr.Code)
+
+	// connection is still usable after error reply
+	_, err = conn.Do(ctx, "DEL", "key")
+	require.NoError(t, err)
+}
+
+func TestConn_Pipeline(t *testing.T) {
+	conn := dial(t)
+
+	replies, err := conn.Pipeline(context.Background(),
+		[]string{"MULTI"},
+		[]string{"SET", "a", "1"},
+		[]string{"GET", "a"},
+		[]string{"EXEC"},
+		[]string{"GET"},
+		[]string{"GET", "a"},
+	)
+	require.NoError(t, err)
+	require.Len(t, replies, 6)
+
+	assert.Equal(t, "OK", replies[0].Status)
+	assert.Equal(t, "QUEUED", replies[1].Status)
+	assert.Equal(t, "QUEUED", replies[2].Status)
+	assert.Equal(t, Reply{Type: ArrayReply, Array: []Reply{
+		{Type: StatusReply, Status: "OK"},
+		{Type: ValueReply, Value: "1"},
+	}}, replies[3])
+	assert.Equal(t, ErrorReply, replies[4].Type)
+	assert.Equal(t, Reply{Type: ValueReply, Value: "1"}, replies[5])
+}
+
+func TestConn_Concurrent(t *testing.T) {
+	conn := dial(t)
+	ctx := context.Background()
+
+	// replies of concurrent commands sharing connection are matched by request IDs
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, value := "key"+strconv.Itoa(i), strconv.Itoa(i)
+			for j := 0; j < 50; j++ {
+				_, err := conn.Do(ctx, "SET", key, value)
+				assert.NoError(t, err)
+				reply, err := conn.Do(ctx, "GET", key)
+				assert.NoError(t, err)
+				assert.Equal(t, value, reply.Value)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConn_Close(t *testing.T) {
+	conn := dial(t)
+	require.NoError(t, conn.Close())
+
+	_, err := conn.Do(context.Background(), "GET", "key")
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestConn_Canceled(t *testing.T) {
+	conn := dial(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := conn.Do(ctx, "GET", "key")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// reply of canceled command doesn't break next ones
+	reply, err := conn.Do(context.Background(), "SET", "key", "value")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", reply.Status)
+}
+
+const pipelineBatch = 100
+
+func prepareBenchmark(b *testing.B) *Conn {
+	conn := dial(b)
+	_, err := conn.Do(context.Background(), "SET", "key", "value")
+	require.NoError(b, err)
+	b.ResetTimer()
+
+	return conn
+}
+
+// BenchmarkConn_Get waits for every reply before sending next command.
+func BenchmarkConn_Get(b *testing.B) {
+	conn := prepareBenchmark(b)
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.Do(ctx, "GET", "key"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConn_PipelineGet sends commands in batches, b.N is number of commands.
+func BenchmarkConn_PipelineGet(b *testing.B) {
+	conn := prepareBenchmark(b)
+	ctx := context.Background()
+
+	batch := make([][]string, pipelineBatch)
+	for i := range batch {
+		batch[i] = []string{"GET", "key"}
+	}
+
+	for sent := 0; sent < b.N; sent += len(batch) {
+		n := min(len(batch), b.N-sent)
+		if _, err := conn.Pipeline(ctx, batch[:n]...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConn_ParallelGet shares one connection between goroutines, their commands are pipelined.
+func BenchmarkConn_ParallelGet(b *testing.B) {
+	conn := prepareBenchmark(b)
+	ctx := context.Background()
+
+	b.SetParallelism(pipelineBatch)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := conn.Do(ctx, "GET", "key"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
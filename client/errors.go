@@ -0,0 +1,110 @@
+package client
+
+import (
+	"github.com/MitrickX/simple-kv/protocol"
+)
+
+// serverErrors are known errors of server by their codes, they are registered by newServerError.
+var serverErrors = map[string]error{}
+
+// Errors of server, error replies match them with errors.Is by codes sent by server.
+var (
+	ErrNoTokensInQuery       = newServerError(protocol.CodeNoTokensInQuery, "no tokens in query")
+	ErrUnknownCommandType    = newServerError(protocol.CodeUnknownCommandType, "unknown command type")
+	ErrInvalidArgumentFormat = newServerError(protocol.CodeInvalidArgumentFormat, "invalid argument format")
+	ErrInvalidExpireTime     = newServerError(protocol.CodeInvalidExpireTime, "invalid expire time")
+	ErrInvalidCursor         = newServerError(protocol.CodeInvalidCursor, "invalid cursor")
+	ErrInvalidCount          = newServerError(protocol.CodeInvalidCount, "invalid count")
+	ErrUnknownOption         = newServerError(protocol.CodeUnknownOption, "unknown option")
+	ErrInvalidLimit          = newServerError(protocol.CodeInvalidLimit, "invalid limit")
+	ErrInvalidIncrement      = newServerError(protocol.CodeInvalidIncrement, "invalid increment")
+	ErrConflictingOptions    = newServerError(protocol.CodeConflictingOptions, "conflicting options")
+	ErrTooManyArguments      = newServerError(protocol.CodeTooManyArguments, "too many arguments")
+
+	ErrNoEnoughArgumentsForSetCommand         = newServerError(protocol.CodeNoEnoughArgumentsForSetCommand, "no enough arguments for set command")
+	ErrNoEnoughArgumentsForGetCommand         = newServerError(protocol.CodeNoEnoughArgumentsForGetCommand, "no enough arguments for get command")
+	ErrNoEnoughArgumentsForDelCommand         = newServerError(protocol.CodeNoEnoughArgumentsForDelCommand, "no enough arguments for del command")
+	ErrNoEnoughArgumentsForExpireCommand      = newServerError(protocol.CodeNoEnoughArgumentsForExpireCommand, "no enough arguments for expire command")
+	ErrNoEnoughArgumentsForTTLCommand         = newServerError(protocol.CodeNoEnoughArgumentsForTTLCommand, "no enough arguments for ttl command")
+	ErrNoEnoughArgumentsForPersistCommand     = newServerError(protocol.CodeNoEnoughArgumentsForPersistCommand, "no enough arguments for persist command")
+	ErrNoEnoughArgumentsForWatchCommand       = newServerError(protocol.CodeNoEnoughArgumentsForWatchCommand, "no enough arguments for watch command")
+	ErrNoEnoughArgumentsForAuthCommand        = newServerError(protocol.CodeNoEnoughArgumentsForAuthCommand, "no enough arguments for auth command")
+	ErrNoEnoughArgumentsForScanCommand        = newServerError(protocol.CodeNoEnoughArgumentsForScanCommand, "no enough arguments for scan command")
+	ErrNoEnoughArgumentsForKeysCommand        = newServerError(protocol.CodeNoEnoughArgumentsForKeysCommand, "no enough arguments for keys command")
+	ErrNoEnoughArgumentsForRangeCommand       = newServerError(protocol.CodeNoEnoughArgumentsForRangeCommand, "no enough arguments for range command")
+	ErrNoEnoughArgumentsForPrefixCommand      = newServerError(protocol.CodeNoEnoughArgumentsForPrefixCommand, "no enough arguments for prefix command")
+	ErrNoEnoughArgumentsForIncrCommand        = newServerError(protocol.CodeNoEnoughArgumentsForIncrCommand, "no enough arguments for incr command")
+	ErrNoEnoughArgumentsForDecrCommand        = newServerError(protocol.CodeNoEnoughArgumentsForDecrCommand, "no enough arguments for decr command")
+	ErrNoEnoughArgumentsForIncrByCommand      = newServerError(protocol.CodeNoEnoughArgumentsForIncrByCommand, "no enough arguments for incrby command")
+	ErrNoEnoughArgumentsForIncrByFloatCommand = newServerError(protocol.CodeNoEnoughArgumentsForIncrByFloatCommand, "no enough arguments for incrbyfloat command")
+	ErrNoEnoughArgumentsForSetNXCommand       = newServerError(protocol.CodeNoEnoughArgumentsForSetNXCommand, "no enough arguments for setnx command")
+	ErrNoEnoughArgumentsForGetSetCommand      = newServerError(protocol.CodeNoEnoughArgumentsForGetSetCommand, "no enough arguments for getset command")
+	ErrNoEnoughArgumentsForCASCommand         = newServerError(protocol.CodeNoEnoughArgumentsForCASCommand, "no enough arguments for cas command")
+	ErrNoEnoughArgumentsForMGetCommand        = newServerError(protocol.CodeNoEnoughArgumentsForMGetCommand, "no enough arguments for mget command")
+	ErrNoEnoughArgumentsForMSetCommand        = newServerError(protocol.CodeNoEnoughArgumentsForMSetCommand, "no enough arguments for mset command")
+	ErrNoEnoughArgumentsForMDelCommand        = newServerError(protocol.CodeNoEnoughArgumentsForMDelCommand, "no enough arguments for mdel command")
+
+	ErrNestedMulti         = newServerError(protocol.CodeNestedMulti, "MULTI calls can not be nested")
+	ErrExecWithoutMulti    = newServerError(protocol.CodeExecWithoutMulti, "EXEC without MULTI")
+	ErrDiscardWithoutMulti = newServerError(protocol.CodeDiscardWithoutMulti, "DISCARD without MULTI")
+	ErrWatchInsideMulti    = newServerError(protocol.CodeWatchInsideMulti, "WATCH inside MULTI is not allowed")
+	ErrTransactionAborted  = newServerError(protocol.CodeTransactionAborted, "transaction discarded because of previous errors")
+	ErrUnknownInfoSection  = newServerError(protocol.CodeUnknownInfoSection, "unknown INFO section")
+	ErrNotInteger          = newServerError(protocol.CodeNotInteger, "value is not an integer or out of range")
+	ErrNotFloat            = newServerError(protocol.CodeNotFloat, "value is not a valid float")
+	ErrIncrementOverflow   = newServerError(protocol.CodeIncrementOverflow, "increment or decrement would overflow")
+
+	ErrAuthRequired       = newServerError(protocol.CodeAuthRequired, "authentication required")
+	ErrAuthNotEnabled     = newServerError(protocol.CodeAuthNotEnabled, "AUTH called without any users configured")
+	ErrInvalidCredentials = newServerError(protocol.CodeInvalidCredentials, "invalid username-password pair")
+	ErrPermissionDenied   = newServerError(protocol.CodePermissionDenied, "permission denied")
+
+	ErrOutOfMemory        = newServerError(protocol.CodeOutOfMemory, "out of memory")
+	ErrReadOnly           = newServerError(protocol.CodeReadOnly, "read-only replica doesn't accept writes")
+	ErrSnapshotsDisabled  = newServerError(protocol.CodeSnapshotsDisabled, "snapshots are not configured")
+	ErrSnapshotInProgress = newServerError(protocol.CodeSnapshotInProgress, "snapshot is already in progress")
+	ErrNotOrdered         = newServerError(protocol.CodeNotOrdered, "engine doesn't keep keys ordered")
+
+	ErrMessageTooLarge = newServerError(protocol.CodeMessageTooLarge, "message is too large")
+	ErrProtocol        = newServerError(protocol.CodeProtocol, "protocol error")
+)
+
+// serverError is a known error of server, it is matched by code, message only describes it.
+type serverError struct {
+	message string
+}
+
+func newServerError(code, message string) error {
+	err := &serverError{message: message}
+	serverErrors[code] = err
+	return err
+}
+
+func (e *serverError) Error() string {
+	return "server error: " + e.message
+}
+
+// Error is an error returned by server for command, connection may be used further.
+type Error struct {
+	// Code is a class of error, e.g. ERR or OOM
+	Code    string
+	Message string
+	// err is a known server error of code of reply, nil if client doesn't know it
+	err error
+}
+
+func newError(response protocol.Response) *Error {
+	return &Error{
+		Code:    response.Class,
+		Message: response.Message,
+		err:     serverErrors[response.Code],
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Code + " " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	tests := []struct {
+		name     string
+		response protocol.Response
+		wantErr  error
+	}{
+		{
+			name:     "known code",
+			response: protocol.Response{Class: "ERR", Code: protocol.CodeNotInteger, Message: "value is not an integer"},
+			wantErr:  ErrNotInteger,
+		},
+		{
+			// message of error contains text of another one, but error is matched by code only
+			name:     "message of another error",
+			response: protocol.Response{Class: "ERR", Code: protocol.CodeInvalidCount, Message: "invalid count: too many arguments"},
+			wantErr:  ErrInvalidCount,
+		},
+		{
+			name:     "unknown code",
+			response: protocol.Response{Class: "ERR", Code: "NEW_ERROR", Message: "something failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newError(tt.response)
+			assert.Equal(t, tt.response.Class+" "+tt.response.Message, err.Error())
+			assert.Equal(t, tt.wantErr, errors.Unwrap(err))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MitrickX/simple-kv/protocol"
+)
+
+type ReplyType int
+
+const (
+	// StatusReply is a short status message, e.g. OK or QUEUED
+	StatusReply ReplyType = iota + 1
+	// ValueReply is a stored value, it may contain any bytes
+	ValueReply
+	// NilReply means there is no value, e.g. key doesn't exist
+	NilReply
+	IntegerReply
+	// ArrayReply is a list of replies, nil list means there is no array at all, e.g. transaction is aborted
+	ArrayReply
+	ErrorReply
+	// MapReply is a list of named replies in fixed order, e.g. sections of INFO
+	MapReply
+)
+
+// Reply is a typed result of command, only field of its type is set.
+type Reply struct {
+	Type    ReplyType
+	Status  string
+	Value   string
+	Integer int64
+	Array   []Reply
+	Map     []MapEntry
+	Err     error
+}
+
+// MapEntry is a named item of map reply.
+type MapEntry struct {
+	Key   string
+	Value Reply
+}
+
+// Get returns value of map entry by key.
+func (r Reply) Get(key string) (Reply, bool) {
+	for _, entry := range r.Map {
+		if entry.Key == key {
+			return entry.Value, true
+		}
+	}
+	return Reply{}, false
+}
+
+// IsNil reports whether reply has no value: nil or nil array.
+func (r Reply) IsNil() bool {
+	return r.Type == NilReply || (r.Type == ArrayReply && r.Array == nil)
+}
+
+// values returns values of array reply.
+func values(r Reply) []string {
+	items := make([]string, 0, len(r.Array))
+	for _, item := range r.Array {
+		items = append(items, item.Value)
+	}
+	return items
+}
+
+// NewReply converts response read from frames to reply, e.g. by raw protocol.Reader.
+func NewReply(response protocol.Response) Reply {
+	switch response.Type {
+	case protocol.StatusResponse:
+		return Reply{Type: StatusReply, Status: response.Status}
+	case protocol.ValueResponse:
+		return Reply{Type: ValueReply, Value: response.Value}
+	case protocol.NilResponse:
+		return Reply{Type: NilReply}
+	case protocol.IntegerResponse:
+		return Reply{Type: IntegerReply, Integer: response.Integer}
+	case protocol.ArrayResponse:
+		if response.Array == nil {
+			return Reply{Type: ArrayReply}
+		}
+		items := make([]Reply, 0, len(response.Array))
+		for _, item := range response.Array {
+			items = append(items, NewReply(item))
+		}
+		return Reply{Type: ArrayReply, Array: items}
+	case protocol.MapResponse:
+		entries := make([]MapEntry, 0, len(response.Map))
+		for _, entry := range response.Map {
+			entries = append(entries, MapEntry{Key: entry.Key, Value: NewReply(entry.Value)})
+		}
+		return Reply{Type: MapReply, Map: entries}
+	default:
+		return Reply{Type: ErrorReply, Err: newError(response)}
+	}
+}
+
+// FormatText formats reply the same way as server replies in line protocol:
+//
+//	OK                  status
+//	"value"             value, quoted with Go escapes, so it never contains line breaks
+//	(nil)               no value
+//	(integer) 1         integer
+//	(error) ERR message error with code
+//	(array) 2           array header followed by numbered items, one per line
+//	(empty array)       array without items
+//	(map) 2             map header followed by "key: value" items, one per line
+//	(empty map)         map without items
+//
+// Items of nested arrays and maps are indented by three spaces.
+func FormatText(reply Reply) string {
+	var b strings.Builder
+	writeText(&b, reply, "")
+	return b.String()
+}
+
+func writeText(b *strings.Builder, reply Reply, indent string) {
+	switch reply.Type {
+	case StatusReply:
+		b.WriteString(reply.Status)
+	case ValueReply:
+		b.WriteString(strconv.Quote(reply.Value))
+	case NilReply:
+		b.WriteString("(nil)")
+	case IntegerReply:
+		fmt.Fprintf(b, "(integer) %d", reply.Integer)
+	case ErrorReply:
+		fmt.Fprintf(b, "(error) %s", reply.Err.Error())
+	case ArrayReply:
+		if reply.Array == nil {
+			b.WriteString("(nil)")
+			return
+		}
+		if len(reply.Array) == 0 {
+			b.WriteString("(empty array)")
+			return
+		}
+		fmt.Fprintf(b, "(array) %d", len(reply.Array))
+		for i, item := range reply.Array {
+			fmt.Fprintf(b, "\n%s%d) ", indent, i+1)
+			writeText(b, item, indent+"   ")
+		}
+	case MapReply:
+		if len(reply.Map) == 0 {
+			b.WriteString("(empty map)")
+			return
+		}
+		fmt.Fprintf(b, "(map) %d", len(reply.Map))
+		for _, entry := range reply.Map {
+			fmt.Fprintf(b, "\n%s%s: ", indent, entry.Key)
+			writeText(b, entry.Value, indent+"   ")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/MitrickX/simple-kv/protocol"
+)
+
+func TestFormatText(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply Reply
+		want  string
+	}{
+		{name: "status", reply: Reply{Type: StatusReply, Status: "OK"}, want: "OK"},
+		{name: "value", reply: Reply{Type: ValueReply, Value: "none"}, want: `"none"`},
+		{name: "value with line break", reply: Reply{Type: ValueReply, Value: "a\nb\""}, want: `"a\nb\""`},
+		{name: "nil", reply: Reply{Type: NilReply}, want: "(nil)"},
+		{name: "integer", reply: Reply{Type: IntegerReply, Integer: -2}, want: "(integer) -2"},
+		{
+			name: "error",
+			reply: NewReply(protocol.Response{
+				Type:    protocol.ErrorResponse,
+				Class:   "ERR",
+				Code:    protocol.CodeNotInteger,
+				Message: "value is not an integer",
+			}),
+			want: "(error) ERR value is not an integer",
+		},
+		{name: "empty array", reply: Reply{Type: ArrayReply, Array: []Reply{}}, want: "(empty array)"},
+		{name: "nil array", reply: Reply{Type: ArrayReply}, want: "(nil)"},
+		{
+			name: "array",
+			reply: Reply{Type: ArrayReply, Array: []Reply{
+				{Type: StatusReply, Status: "OK"},
+				{Type: ArrayReply, Array: []Reply{{Type: ValueReply, Value: "v"}, {Type: NilReply}}},
+				{Type: IntegerReply, Integer: 1},
+			}},
+			want: "(array) 3\n1) OK\n2) (array) 2\n   1) \"v\"\n   2) (nil)\n3) (integer) 1",
+		},
+		{name: "empty map", reply: Reply{Type: MapReply}, want: "(empty map)"},
+		{
+			name: "map",
+			reply: Reply{Type: MapReply, Map: []MapEntry{
+				{Key: "server", Value: Reply{Type: MapReply, Map: []MapEntry{
+					{Key: "version", Value: Reply{Type: ValueReply, Value: "0.1.0"}},
+					{Key: "uptime", Value: Reply{Type: IntegerReply, Integer: 5}},
+				}}},
+				{Key: "keys", Value: Reply{Type: IntegerReply, Integer: 3}},
+			}},
+			want: "(map) 2\nserver: (map) 2\n   version: \"0.1.0\"\n   uptime: (integer) 5\nkeys: (integer) 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatText(tt.reply); got != tt.want {
+				t.Errorf("FormatText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
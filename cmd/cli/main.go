@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"net"
@@ -18,19 +20,53 @@ const (
 
 func main() {
 	address := flag.String("address", "", "network address to connect to TCP server")
+	binary := flag.Bool("binary", false, "use binary frames, so values may contain line breaks, e.g. SET key \"line1\\nline2\"")
+	useTLS := flag.Bool("tls", false, "connect over TLS")
+	caFile := flag.String("tls-ca", "", "CA to verify server certificate, system CAs are used if it is empty")
+	certFile := flag.String("tls-cert", "", "client certificate if server verifies clients")
+	keyFile := flag.String("tls-key", "", "key of client certificate")
 	flag.Parse()
 
 	if *address == "" {
-		fmt.Println("Usage: cli --address <address>")
+		fmt.Println("Usage: cli --address <address> [--binary] [--tls [--tls-ca <file>] [--tls-cert <file> --tls-key <file>]]")
 		os.Exit(1)
 	}
 
-	fmt.Println("Support commands: SET/GET/DEL")
-	fmt.Println("SET key value")
+	fmt.Println("Support commands: SET/GET/DEL/EXPIRE/TTL/PERSIST/MULTI/EXEC/DISCARD/WATCH/UNWATCH/SNAPSHOT/AUTH/INFO/SCAN/KEYS/RANGE/REVRANGE/PREFIX/REVPREFIX/INCR/DECR/INCRBY/INCRBYFLOAT/SETNX/GETSET/CAS/MGET/MSET/MDEL")
+	fmt.Println("SET key value [EX seconds|PX milliseconds] [NX|XX] [GET]")
 	fmt.Println("GET key")
-	fmt.Println("DEL key")
+	fmt.Println("DEL key [key ...], MDEL key [key ...]")
+	fmt.Println("MGET key [key ...], MSET key value [key value ...]")
+	fmt.Println("EXPIRE key seconds")
+	fmt.Println("TTL key")
+	fmt.Println("PERSIST key")
+	fmt.Println("MULTI, EXEC, DISCARD, WATCH key [key ...], UNWATCH")
+	fmt.Println("SNAPSHOT")
+	fmt.Println("AUTH user password")
+	fmt.Println("INFO [server|clients|stats|keyspace|memory|persistence|replication]")
+	fmt.Println("SCAN cursor [MATCH pattern] [COUNT count]")
+	fmt.Println("KEYS pattern")
+	fmt.Println("RANGE start end [LIMIT count], REVRANGE start end [LIMIT count]")
+	fmt.Println("PREFIX prefix [LIMIT count], REVPREFIX prefix [LIMIT count]")
+	fmt.Println("INCR key, DECR key, INCRBY key increment, INCRBYFLOAT key increment")
+	fmt.Println("SETNX key value, GETSET key value, CAS key expected value")
+	fmt.Println(`Arguments with spaces or special characters must be quoted: SET greeting "hello\tworld\x21"`)
 
-	conn, err := net.DialTimeout("tcp", *address, tcpDialTimeout)
+	var (
+		conn net.Conn
+		err  error
+	)
+	if *useTLS {
+		var tlsConfig *tls.Config
+		tlsConfig, err = buildTLSConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			fmt.Printf("invalid tls config: %v\n", err)
+			os.Exit(1)
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: tcpDialTimeout}, "tcp", *address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", *address, tcpDialTimeout)
+	}
 	if err != nil {
 		fmt.Printf("failed to connect to server: %v\n", err)
 		os.Exit(1)
@@ -48,6 +84,36 @@ func main() {
 		os.Exit(0)
 	}()
 
-	cli := cli.NewCli(os.Stdin, os.Stdout, os.Stderr, conn)
+	mode := cli.ModeText
+	if *binary {
+		mode = cli.ModeFrames
+	}
+
+	cli := cli.NewCli(os.Stdin, os.Stdout, os.Stderr, conn, mode)
 	cli.Go()
 }
+
+func buildTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
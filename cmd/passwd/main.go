@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+)
+
+// passwd reads password from stdin and prints its hash for auth section of server config.
+func main() {
+	iterations := flag.Int("iterations", auth.DefaultIterations, "number of hashing iterations")
+	flag.Parse()
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		fmt.Fprintln(os.Stderr, "\nfailed to read password")
+		os.Exit(1)
+	}
+
+	hash, err := auth.HashPassword(scanner.Text(), *iterations)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hash password: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(hash)
+}
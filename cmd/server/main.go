@@ -5,16 +5,25 @@ import (
 	"flag"
 	"log"
 	"os"
-
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/MitrickX/simple-kv/internal/auth"
 	"github.com/MitrickX/simple-kv/internal/config"
 	"github.com/MitrickX/simple-kv/internal/db"
 	"github.com/MitrickX/simple-kv/internal/interpreter"
 	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/metrics"
 	"github.com/MitrickX/simple-kv/internal/network"
+	"github.com/MitrickX/simple-kv/internal/replication"
 	"github.com/MitrickX/simple-kv/internal/storage"
 	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/MitrickX/simple-kv/internal/storage/engine/lsm"
+	"github.com/MitrickX/simple-kv/internal/storage/snapshot"
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -37,17 +46,344 @@ func main() {
 
 	parser := parser.NewParser()
 	interpreter := interpreter.NewInterpreter(parser)
-	engine := engine.NewEngine()
-	storage := storage.NewStorage(engine)
-	db := db.NewDB(interpreter, storage)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	replicaType := cfg.Replication.ReplicaType
+	if replicaType != "" && cfg.WAL.DataDirectory == "" {
+		logger.Fatal("replication requires wal", zap.String("replicaType", replicaType))
+	}
+	// memtable of lsm engine isn't logged, it would be lost on crash without wal
+	if cfg.Engine.Type == config.EngineTypeLSM && cfg.WAL.DataDirectory == "" {
+		logger.Fatal("lsm engine requires wal")
+	}
+
+	eng := buildEngine(&cfg, logger)
+
+	// metrics are collected only if they are served
+	var registry *metrics.Registry
+	if cfg.Metrics.Address != "" {
+		registry = metrics.NewRegistry()
+	}
+
+	var (
+		storageOpts []storage.Option
+		walLog      *wal.WAL
+	)
+	if cfg.WAL.DataDirectory != "" {
+		var err error
+		walLog, err = wal.NewWAL(cfg.WAL, logger)
+		if err != nil {
+			logger.Fatal("failed to create wal", zap.Error(err))
+		}
+		storageOpts = append(storageOpts, storage.WithWAL(walLog))
+	}
+	if cfg.Snapshot.DataDirectory != "" {
+		snapshotter, err := snapshot.NewSnapshotter(cfg.Snapshot, logger)
+		if err != nil {
+			logger.Fatal("failed to create snapshotter", zap.Error(err))
+		}
+		storageOpts = append(storageOpts, storage.WithSnapshotter(snapshotter))
+	}
+	acl, err := auth.NewACL(cfg.Auth)
+	if err != nil {
+		logger.Fatal("invalid auth config", zap.Error(err))
+	}
+	// certificates secure connections of clients and of slaves to master
+	certs, err := network.NewCertificates(cfg.Network)
+	if err != nil {
+		logger.Fatal("invalid tls config", zap.Error(err))
+	}
+
+	// master is created before storage, storage keeps log which slaves haven't pulled yet
+	var master *replication.Master
+	switch replicaType {
+	case config.ReplicaTypeMaster:
+		masterOpts := []replication.MasterOption{replication.WithACL(acl)}
+		if certs != nil {
+			masterOpts = append(masterOpts, replication.WithTLS(certs.TLSConfig()))
+		}
+		master = replication.NewMaster(&cfg, walLog, logger, masterOpts...)
+		storageOpts = append(storageOpts, storage.WithReplicas(master))
+	case config.ReplicaTypeSlave:
+		storageOpts = append(storageOpts, storage.WithReadOnly())
+	}
+	if registry != nil {
+		storageOpts = append(storageOpts, storage.WithMetrics(registry))
+	}
+
+	st := storage.NewStorage(eng, storageOpts...)
+
+	if err := st.Recover(); err != nil {
+		logger.Fatal("failed to recover storage", zap.Error(err))
+	}
+
+	// servers and slave are created after db, INFO looks them up when it is called
+	var (
+		tcpServer  *network.TcpServer
+		respServer *network.RespServer
+		slave      *replication.Slave
+	)
+	dbOpts := []db.Option{
+		db.WithACL(acl),
+		db.WithInfo(db.InfoSectionServer, func() []db.MapEntry {
+			return serverInfo(&cfg)
+		}),
+		db.WithInfo(db.InfoSectionClients, func() []db.MapEntry {
+			return clientsInfo(&cfg, tcpServer, respServer)
+		}),
+		db.WithInfo(db.InfoSectionMemory, func() []db.MapEntry {
+			policy := cfg.Engine.EvictionPolicy
+			if policy == "" {
+				policy = config.EvictionPolicyNoEviction
+			}
+			return []db.MapEntry{{Key: "eviction_policy", Value: db.ValueResponse(policy)}}
+		}),
+		db.WithInfo(db.InfoSectionPersistence, func() []db.MapEntry {
+			return []db.MapEntry{
+				{Key: "wal_enabled", Value: db.BoolResponse(cfg.WAL.DataDirectory != "")},
+				{Key: "snapshots_enabled", Value: db.BoolResponse(cfg.Snapshot.DataDirectory != "")},
+			}
+		}),
+		db.WithInfo(db.InfoSectionReplication, func() []db.MapEntry {
+			return replicationInfo(&cfg, slave)
+		}),
+	}
+	if registry != nil {
+		dbOpts = append(dbOpts, db.WithMetrics(registry))
+	}
+	db := db.NewDB(interpreter, st, dbOpts...)
+
+	// servers stop accepting clients and drain connections on signal
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// background jobs and servers which must stop before storage is closed
+	var wg sync.WaitGroup
+	goBackground := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+
+	goBackground(func() {
+		engine.RunSweeper(ctx, eng, engine.DefaultSweepInterval)
+	})
+	if cfg.Snapshot.DataDirectory != "" {
+		goBackground(func() {
+			storage.RunSnapshotter(ctx, st, time.Duration(cfg.Snapshot.Interval), uint64(cfg.Snapshot.WritesThreshold), logger)
+		})
+	}
+
+	switch replicaType {
+	case "":
+	case config.ReplicaTypeMaster:
+		goBackground(func() {
+			if err := master.Start(ctx); err != nil {
+				logger.Fatal("replication master exited with error", zap.Error(err))
+			}
+		})
+	case config.ReplicaTypeSlave:
+		var slaveOpts []replication.SlaveOption
+		if certs != nil {
+			slaveOpts = append(slaveOpts, replication.WithSlaveTLS(certs.ClientTLSConfig))
+		}
+		slave = replication.NewSlave(&cfg, st, logger, slaveOpts...)
+		if registry != nil {
+			registerSlaveMetrics(registry, slave)
+		}
+		goBackground(func() {
+			if err := slave.Start(ctx); err != nil {
+				logger.Fatal("replication slave exited with error", zap.Error(err))
+			}
+		})
+	default:
+		logger.Fatal("unknown replica type", zap.String("replicaType", replicaType))
+	}
+
+	var serverOpts []network.ServerOption
+	if certs != nil {
+		serverOpts = append(serverOpts, network.WithTLS(certs))
+		goBackground(func() {
+			reloadCertificates(ctx, certs, logger)
+		})
+	}
+	if registry != nil {
+		serverOpts = append(serverOpts, network.WithMetrics(registry))
+
+		metricsServer := metrics.NewServer(cfg.Metrics.Address, registry, logger)
+		goBackground(func() {
+			if err := metricsServer.Start(ctx); err != nil {
+				logger.Fatal("metrics server exited with error", zap.Error(err))
+			}
+		})
+	}
+
+	// both servers are created before any of them starts, so INFO of the first client sees them
+	tcpServer = network.NewTcpServer(&cfg, db, logger, serverOpts...)
+	if cfg.Network.RespAddress != "" {
+		respServer = network.NewRespServer(&cfg, db, logger, serverOpts...)
+		goBackground(func() {
+			if err := respServer.Start(ctx); err != nil {
+				logger.Fatal("resp server exited with error", zap.Error(err))
+			}
+		})
+	}
 
-	server := network.NewTcpServer(&cfg, db, logger)
-	if err := server.Start(ctx); err != nil {
+	if err := tcpServer.Start(ctx); err != nil {
 		logger.Fatal("server exited with error", zap.Error(err))
 	}
+
+	stop()
+	wg.Wait()
+	shutdownStorage(&cfg, st, logger)
+	logger.Info("server stopped")
+}
+
+// reloadCertificates reloads tls certificates on SIGHUP, e.g. after they are renewed.
+func reloadCertificates(ctx context.Context, certs *network.Certificates, logger *zap.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := certs.Reload(); err != nil {
+				logger.Error("failed to reload tls certificates, previous ones are used", zap.Error(err))
+				continue
+			}
+			logger.Info("tls certificates reloaded")
+		}
+	}
+}
+
+func registerSlaveMetrics(registry *metrics.Registry, slave *replication.Slave) {
+	registry.NewGaugeFunc("simplekv_replication_lag_records",
+		"Number of master log records which slave hasn't applied as of the last sync.", func() float64 {
+			return float64(slave.Lag())
+		})
+	registry.NewGaugeFunc("simplekv_replication_last_sync_timestamp_seconds",
+		"Unix time of the last successful sync with master, 0 if there was none.", func() float64 {
+			t := slave.LastSync()
+			if t.IsZero() {
+				return 0
+			}
+			return float64(t.UnixNano()) / 1e9
+		})
+}
+
+// serverInfo summarizes config for INFO.
+func serverInfo(cfg *config.Config) []db.MapEntry {
+	engineType := cfg.Engine.Type
+	if engineType == "" {
+		engineType = config.EngineTypeInMemory
+	}
+
+	return []db.MapEntry{
+		{Key: "version", Value: db.ValueResponse(network.ServerVersion)},
+		{Key: "engine", Value: db.ValueResponse(engineType)},
+		{Key: "tcp_address", Value: db.ValueResponse(cfg.Network.Address)},
+		{Key: "resp_address", Value: db.ValueResponse(cfg.Network.RespAddress)},
+		{Key: "tls_enabled", Value: db.BoolResponse(cfg.Network.TLSCertFile != "")},
+		{Key: "idle_timeout_seconds", Value: db.IntegerResponse(int64(time.Duration(cfg.Network.IdleTimeout) / time.Second))},
+	}
+}
+
+func clientsInfo(cfg *config.Config, tcpServer *network.TcpServer, respServer *network.RespServer) []db.MapEntry {
+	var tcpClients, respClients int
+	if tcpServer != nil {
+		tcpClients = tcpServer.Clients()
+	}
+	if respServer != nil {
+		respClients = respServer.Clients()
+	}
+
+	return []db.MapEntry{
+		{Key: "connected_clients", Value: db.IntegerResponse(int64(tcpClients + respClients))},
+		{Key: "tcp_clients", Value: db.IntegerResponse(int64(tcpClients))},
+		{Key: "resp_clients", Value: db.IntegerResponse(int64(respClients))},
+		{Key: "max_clients", Value: db.IntegerResponse(int64(cfg.Network.MaxConnections))},
+	}
+}
+
+func replicationInfo(cfg *config.Config, slave *replication.Slave) []db.MapEntry {
+	switch cfg.Replication.ReplicaType {
+	case config.ReplicaTypeMaster:
+		return []db.MapEntry{
+			{Key: "role", Value: db.ValueResponse(config.ReplicaTypeMaster)},
+			{Key: "listen_address", Value: db.ValueResponse(cfg.Replication.MasterAddress)},
+		}
+	case config.ReplicaTypeSlave:
+		entries := []db.MapEntry{
+			{Key: "role", Value: db.ValueResponse(config.ReplicaTypeSlave)},
+			{Key: "master_address", Value: db.ValueResponse(cfg.Replication.MasterAddress)},
+		}
+		if slave != nil {
+			var lastSync int64
+			if t := slave.LastSync(); !t.IsZero() {
+				lastSync = t.Unix()
+			}
+			entries = append(entries,
+				db.MapEntry{Key: "lag_records", Value: db.IntegerResponse(int64(slave.Lag()))},
+				db.MapEntry{Key: "last_sync_time", Value: db.IntegerResponse(lastSync)},
+			)
+		}
+		return entries
+	default:
+		return []db.MapEntry{{Key: "role", Value: db.ValueResponse("standalone")}}
+	}
+}
+
+// shutdownStorage takes the last snapshot, so next start doesn't replay log, and flushes log.
+func shutdownStorage(cfg *config.Config, st storage.Storage, logger *zap.Logger) {
+	if cfg.Snapshot.DataDirectory != "" && st.ChangesSinceSnapshot() > 0 {
+		if err := st.Snapshot(); err != nil {
+			logger.Error("failed to write snapshot on shutdown", zap.Error(err))
+		}
+	}
+
+	if err := st.Close(); err != nil {
+		logger.Error("failed to close storage", zap.Error(err))
+	}
+}
+
+func buildEngine(cfg *config.Config, logger *zap.Logger) engine.Engine {
+	var opts []engine.Option
+	if cfg.Engine.MaxMemory > 0 {
+		switch cfg.Engine.EvictionPolicy {
+		case config.EvictionPolicyNoEviction, config.EvictionPolicyAllKeysLRU, config.EvictionPolicyAllKeysLFU,
+			config.EvictionPolicyVolatileTTL, config.EvictionPolicyRandom:
+		case "":
+			cfg.Engine.EvictionPolicy = config.EvictionPolicyNoEviction
+		default:
+			logger.Fatal("unknown eviction policy", zap.String("policy", cfg.Engine.EvictionPolicy))
+		}
+		opts = append(opts, engine.WithMaxMemory(uint64(cfg.Engine.MaxMemory), engine.EvictionPolicy(cfg.Engine.EvictionPolicy)))
+	}
+
+	switch cfg.Engine.Type {
+	case config.EngineTypeInMemory, "":
+		return engine.NewEngine(opts...)
+	case config.EngineTypeInMemorySharded:
+		return engine.NewShardedEngine(cfg.Engine.Shards, opts...)
+	case config.EngineTypeBTree:
+		return engine.NewBTreeEngine(opts...)
+	case config.EngineTypeLSM:
+		if cfg.Engine.MaxMemory > 0 {
+			logger.Warn("max memory is ignored by lsm engine, it keeps data on disk")
+		}
+		eng, err := lsm.NewEngine(cfg.Engine, logger)
+		if err != nil {
+			logger.Fatal("failed to open lsm engine", zap.Error(err))
+		}
+		return eng
+	default:
+		logger.Fatal("unknown engine type", zap.String("type", cfg.Engine.Type))
+		return nil
+	}
 }
 
 func buildZap(cfg *config.Config) *zap.Logger {
@@ -0,0 +1,141 @@
+// Package auth authenticates users by hashed passwords and checks their access to commands and keys.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/glob"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+)
+
+// AllowAll in list of commands or keys of user allows any of them.
+const AllowAll = "*"
+
+var (
+	ErrInvalidCredentials = errors.New("auth error: invalid username-password pair")
+	ErrPermissionDenied   = errors.New("auth error: permission denied")
+)
+
+// ACL keeps users from config.
+type ACL struct {
+	users map[string]*User
+	// password of unknown user is verified against dummy hash,
+	// so time of failed authentication doesn't tell whether user exists
+	dummy passwordHash
+}
+
+type User struct {
+	name     string
+	password passwordHash
+	// nil means all commands are allowed
+	commands map[parser.CommandType]struct{}
+	keys     []string
+}
+
+// NewACL validates users of config, ACL is nil if there are no users, so authentication is disabled.
+func NewACL(cfg config.ConfigAuth) (*ACL, error) {
+	if len(cfg.Users) == 0 {
+		return nil, nil
+	}
+
+	acl := &ACL{users: make(map[string]*User, len(cfg.Users))}
+	for _, u := range cfg.Users {
+		if u.Name == "" {
+			return nil, errors.New("auth error: user name is empty")
+		}
+		if _, ok := acl.users[u.Name]; ok {
+			return nil, fmt.Errorf("auth error: user %s is defined twice", u.Name)
+		}
+
+		password, err := parsePasswordHash(u.Password)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Name, err)
+		}
+
+		if password.iterations > acl.dummy.iterations {
+			acl.dummy = passwordHash{
+				iterations: password.iterations,
+				salt:       make([]byte, saltSize),
+				key:        make([]byte, keySize),
+			}
+		}
+
+		user := &User{name: u.Name, password: password, keys: u.Keys}
+		for _, name := range u.Commands {
+			if name == AllowAll {
+				user.commands = nil
+				break
+			}
+			if user.commands == nil {
+				user.commands = make(map[parser.CommandType]struct{}, len(u.Commands))
+			}
+			if !parser.IsKnownCommand(name) {
+				return nil, fmt.Errorf("auth error: user %s: unknown command %s", u.Name, name)
+			}
+			user.commands[parser.CommandType(strings.ToUpper(name))] = struct{}{}
+		}
+		// user without commands can't do anything
+		if len(u.Commands) == 0 {
+			user.commands = map[parser.CommandType]struct{}{}
+		}
+
+		acl.users[u.Name] = user
+	}
+
+	return acl, nil
+}
+
+// Authenticate returns user if password is right. Unknown user takes as much time as wrong password.
+func (a *ACL) Authenticate(name string, password string) (*User, error) {
+	user, ok := a.users[name]
+	if !ok {
+		a.dummy.verify(password)
+		return nil, ErrInvalidCredentials
+	}
+	if !user.password.verify(password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (u *User) Name() string {
+	return u.name
+}
+
+// Allow checks that user may run command with its keys.
+func (u *User) Allow(cmd parser.Command) error {
+	if u.commands != nil {
+		if _, ok := u.commands[cmd.CommandType]; !ok {
+			return fmt.Errorf("%w: user %s has no permissions to run the '%s' command", ErrPermissionDenied, u.name, cmd.CommandType)
+		}
+	}
+
+	for _, key := range cmd.Keys() {
+		if !u.AllowKey(key) {
+			return fmt.Errorf("%w: user %s has no permissions to access the '%s' key", ErrPermissionDenied, u.name, key)
+		}
+	}
+
+	return nil
+}
+
+// AllowAllKeys reports whether user may access any key, e.g. to replicate log which contains all of them.
+func (u *User) AllowAllKeys() bool {
+	return slices.Contains(u.keys, AllowAll)
+}
+
+// AllowKey reports whether key matches one of key patterns of user, e.g. to hide keys listed by KEYS and SCAN.
+func (u *User) AllowKey(key string) bool {
+	for _, pattern := range u.keys {
+		if glob.Match(pattern, key) {
+			return true
+		}
+	}
+
+	return false
+}
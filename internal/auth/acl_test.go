@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestACL(t *testing.T) *ACL {
+	t.Helper()
+
+	hash, err := HashPassword("secret", 1000)
+	require.NoError(t, err)
+
+	acl, err := NewACL(config.ConfigAuth{
+		Users: []config.ConfigUser{
+			{Name: "admin", Password: hash, Commands: []string{AllowAll}, Keys: []string{AllowAll}},
+			{Name: "reader", Password: hash, Commands: []string{"get", "TTL", "WATCH"}, Keys: []string{"public:*"}},
+			{Name: "nobody", Password: hash, Keys: []string{AllowAll}},
+		},
+	})
+	require.NoError(t, err)
+
+	return acl
+}
+
+func TestACL_Authenticate(t *testing.T) {
+	acl := newTestACL(t)
+
+	user, err := acl.Authenticate("admin", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", user.Name())
+
+	_, err = acl.Authenticate("admin", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = acl.Authenticate("unknown", "secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	// unknown user is verified as long as known one
+	assert.Equal(t, 1000, acl.dummy.iterations)
+}
+
+func TestUser_Allow(t *testing.T) {
+	acl := newTestACL(t)
+
+	tests := []struct {
+		name    string
+		user    string
+		cmd     parser.Command
+		wantErr bool
+	}{
+		{
+			name: "admin may run anything",
+			user: "admin",
+			cmd:  parser.Command{CommandType: parser.SetCommandType, Arguments: []string{"private:1", "v"}},
+		},
+		{
+			name: "allowed command and key",
+			user: "reader",
+			cmd:  parser.Command{CommandType: parser.GetCommandType, Arguments: []string{"public:1"}},
+		},
+		{
+			name:    "denied command",
+			user:    "reader",
+			cmd:     parser.Command{CommandType: parser.SetCommandType, Arguments: []string{"public:1", "v"}},
+			wantErr: true,
+		},
+		{
+			name:    "denied key",
+			user:    "reader",
+			cmd:     parser.Command{CommandType: parser.GetCommandType, Arguments: []string{"private:1"}},
+			wantErr: true,
+		},
+		{
+			name:    "one of watched keys is denied",
+			user:    "reader",
+			cmd:     parser.Command{CommandType: parser.WatchCommandType, Arguments: []string{"public:1", "private:1"}},
+			wantErr: true,
+		},
+		{
+			name:    "user without commands",
+			user:    "nobody",
+			cmd:     parser.Command{CommandType: parser.GetCommandType, Arguments: []string{"key"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := acl.Authenticate(tt.user, "secret")
+			require.NoError(t, err)
+
+			err = user.Allow(tt.cmd)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrPermissionDenied)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewACL(t *testing.T) {
+	hash, err := HashPassword("secret", 1000)
+	require.NoError(t, err)
+
+	acl, err := NewACL(config.ConfigAuth{})
+	require.NoError(t, err)
+	assert.Nil(t, acl, "no users disable authentication")
+
+	tests := []struct {
+		name  string
+		users []config.ConfigUser
+	}{
+		{name: "empty name", users: []config.ConfigUser{{Password: hash}}},
+		{name: "plain password", users: []config.ConfigUser{{Name: "admin", Password: "secret"}}},
+		{name: "unknown command", users: []config.ConfigUser{{Name: "admin", Password: hash, Commands: []string{"FLUSHALL"}}}},
+		{name: "duplicated user", users: []config.ConfigUser{{Name: "admin", Password: hash}, {Name: "admin", Password: hash}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewACL(config.ConfigAuth{Users: tt.users})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Passwords are hashed by PBKDF2 with HMAC-SHA256, hash is stored as "pbkdf2-sha256$iterations$salt$key"
+// where salt and key are base64 encoded without padding.
+const (
+	hashScheme        = "pbkdf2-sha256"
+	DefaultIterations = 100000
+	saltSize          = 16
+	keySize           = sha256.Size
+)
+
+var ErrInvalidPasswordHash = errors.New("auth error: invalid password hash")
+
+var encoding = base64.RawStdEncoding
+
+// HashPassword hashes password with random salt.
+func HashPassword(password string, iterations int) (string, error) {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("auth error: can't generate salt: %w", err)
+	}
+
+	key := pbkdf2([]byte(password), salt, iterations, keySize)
+
+	return strings.Join([]string{
+		hashScheme,
+		strconv.Itoa(iterations),
+		encoding.EncodeToString(salt),
+		encoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+type passwordHash struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+func parsePasswordHash(hash string) (passwordHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return passwordHash{}, ErrInvalidPasswordHash
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return passwordHash{}, fmt.Errorf("%w: invalid number of iterations", ErrInvalidPasswordHash)
+	}
+	salt, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return passwordHash{}, fmt.Errorf("%w: invalid salt: %w", ErrInvalidPasswordHash, err)
+	}
+	key, err := encoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return passwordHash{}, fmt.Errorf("%w: invalid key", ErrInvalidPasswordHash)
+	}
+
+	return passwordHash{iterations: iterations, salt: salt, key: key}, nil
+}
+
+// verify compares keys in constant time, so time doesn't tell how much of password is right.
+func (h passwordHash) verify(password string) bool {
+	key := pbkdf2([]byte(password), h.salt, h.iterations, len(h.key))
+	return subtle.ConstantTimeCompare(key, h.key) == 1
+}
+
+// pbkdf2 derives key from password as defined in RFC 8018, section 5.2.
+func pbkdf2(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	var block [4]byte
+	u := make([]byte, hashLen)
+	t := make([]byte, hashLen)
+	for i := 1; i <= blocks; i++ {
+		// U1 = PRF(password, salt || INT(i))
+		binary.BigEndian.PutUint32(block[:], uint32(i))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		// T = U1 ^ U2 ^ ... ^ Uc, where Uj = PRF(password, Uj-1)
+		for j := 1; j < iterations; j++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for k := range t {
+				t[k] ^= u[k]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// test vector of RFC 7914, section 11
+	key := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t,
+		"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"+
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		hex.EncodeToString(key),
+	)
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret", 1000)
+	require.NoError(t, err)
+
+	parsed, err := parsePasswordHash(hash)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, parsed.iterations)
+	assert.True(t, parsed.verify("secret"))
+	assert.False(t, parsed.verify("Secret"))
+	assert.False(t, parsed.verify(""))
+
+	// salt is random, so the same password has different hashes
+	other, err := HashPassword("secret", 1000)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestParsePasswordHash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "plain password", hash: "secret"},
+		{name: "unknown scheme", hash: "md5$1000$c2FsdA$aGFzaA"},
+		{name: "invalid iterations", hash: "pbkdf2-sha256$-1$c2FsdA$aGFzaA"},
+		{name: "invalid salt", hash: "pbkdf2-sha256$1000$!!$aGFzaA"},
+		{name: "empty key", hash: "pbkdf2-sha256$1000$c2FsdA$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePasswordHash(tt.hash)
+			assert.ErrorIs(t, err, ErrInvalidPasswordHash)
+		})
+	}
+}
@@ -2,19 +2,35 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"strconv"
 	"time"
+
+	"github.com/MitrickX/simple-kv/client"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/protocol"
 )
 
 const (
 	readWriteConnDeadlineTimeout = time.Second
 
-	MessageHello = "HELLO"
-	MessageHi    = "HI"
-	MessageBye   = "BYE"
+	MessageHello = protocol.MessageHello
+	MessageHi    = protocol.MessageHi
+	MessageBye   = protocol.MessageBye
+)
+
+// Mode is a protocol which cli uses to talk to server.
+type Mode int
+
+const (
+	// ModeText sends queries as lines of text, so values can't contain line breaks
+	ModeText Mode = iota
+	// ModeFrames sends queries in length-prefixed binary frames, it is negotiated in handshake
+	ModeFrames
 )
 
 type Cli struct {
@@ -22,6 +38,7 @@ type Cli struct {
 	output    io.Writer
 	errOutput io.Writer
 	conn      net.Conn
+	mode      Mode
 }
 
 func NewCli(
@@ -29,26 +46,33 @@ func NewCli(
 	output io.Writer,
 	errOutput io.Writer,
 	conn net.Conn,
+	mode Mode,
 ) *Cli {
 	return &Cli{
 		input:     input,
 		output:    output,
 		errOutput: errOutput,
 		conn:      conn,
+		mode:      mode,
 	}
 }
 
 func (c *Cli) handlshake() {
 	c.conn.SetDeadline(time.Now().Add(readWriteConnDeadlineTimeout))
 
-	buf := []byte(MessageHello)
-	n, err := c.conn.Write(buf)
-	if err != nil && n != 6 {
+	hello := MessageHello
+	if c.mode == ModeFrames {
+		hello += " " + strconv.Itoa(protocol.Version)
+	}
+
+	n, err := c.conn.Write([]byte(hello))
+	if err != nil && n != len(hello) {
 		_ = fmt.Sprintln(c.output, "\nSession ended cause of fail handshake.")
 		c.conn.Close()
 		os.Exit(0)
 	}
 
+	buf := make([]byte, 16)
 	n, err = c.conn.Read(buf)
 	if err != nil && string(buf[0:n]) != MessageHi {
 		_ = fmt.Sprintln(c.output, "\nSession ended cause of fail handshake.")
@@ -56,6 +80,12 @@ func (c *Cli) handlshake() {
 		os.Exit(0)
 	}
 
+	// server which doesn't support framing replies just HI
+	if c.mode == ModeFrames && string(buf[0:n]) != MessageHi+" "+strconv.Itoa(protocol.Version) {
+		fmt.Fprintln(c.errOutput, "server doesn't support binary frames, fall back to text mode")
+		c.mode = ModeText
+	}
+
 	c.conn.SetDeadline(time.Time{})
 }
 
@@ -64,6 +94,11 @@ func (c *Cli) Go() {
 
 	c.handlshake()
 
+	if c.mode == ModeFrames {
+		c.goFrames()
+		return
+	}
+
 	go func() {
 		scanner := bufio.NewScanner(c.input)
 		for {
@@ -102,3 +137,60 @@ func (c *Cli) Go() {
 		break
 	}
 }
+
+// goFrames sends every query in its own frame and prints responses in the same text form as line protocol does.
+func (c *Cli) goFrames() {
+	go func() {
+		writer := protocol.NewWriter(c.conn)
+		scanner := bufio.NewScanner(c.input)
+		var id uint64
+		for {
+			fmt.Fprint(c.output, "> ")
+			if !scanner.Scan() {
+				break
+			}
+
+			args, err := parser.Split(scanner.Text())
+			if err != nil {
+				fmt.Fprintln(c.output, client.FormatText(client.Reply{
+					Type: client.ErrorReply,
+					Err:  &client.Error{Code: "ERR", Message: err.Error()},
+				}))
+				continue
+			}
+			if len(args) == 0 {
+				continue
+			}
+
+			id++
+			if err := writer.WriteRequest(protocol.NewRequest(id, args)); err != nil {
+				fmt.Fprintf(c.errOutput, "failed to send: %v\n", err)
+				break
+			}
+			if err := writer.Flush(); err != nil {
+				fmt.Fprintf(c.errOutput, "failed to send: %v\n", err)
+				break
+			}
+		}
+	}()
+
+	reader := protocol.NewReader(c.conn, 0)
+	for {
+		id, response, err := reader.ReadResponse()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				fmt.Fprintln(c.errOutput, "server closed connection")
+			} else {
+				fmt.Fprintf(c.errOutput, "server closed connection: %s\n", err.Error())
+			}
+			return
+		}
+
+		if id == 0 && response.Type == protocol.StatusResponse && response.Status == MessageBye {
+			fmt.Fprintln(c.errOutput, "server send bye message and closed connection")
+			return
+		}
+
+		fmt.Fprintln(c.output, client.FormatText(client.NewReply(response)))
+	}
+}
@@ -13,13 +13,23 @@ import (
 )
 
 const (
-	EngineTypeInMemory  = "in_memory"
-	LoggingLevelDebug   = "debug"
-	LoggingLevelInfo    = "info"
-	LoggingLevelWarning = "warning"
-	LoggingLevelError   = "error"
-	LoggingLevelPanic   = "panic"
-	LoggingLevelFatal   = "fatal"
+	EngineTypeInMemory        = "in_memory"
+	EngineTypeInMemorySharded = "in_memory_sharded"
+	EngineTypeBTree           = "btree"
+	EngineTypeLSM             = "lsm"
+	EvictionPolicyNoEviction  = "noeviction"
+	EvictionPolicyAllKeysLRU  = "allkeys-lru"
+	EvictionPolicyAllKeysLFU  = "allkeys-lfu"
+	EvictionPolicyVolatileTTL = "volatile-ttl"
+	EvictionPolicyRandom      = "random"
+	ReplicaTypeMaster         = "master"
+	ReplicaTypeSlave          = "slave"
+	LoggingLevelDebug         = "debug"
+	LoggingLevelInfo          = "info"
+	LoggingLevelWarning       = "warning"
+	LoggingLevelError         = "error"
+	LoggingLevelPanic         = "panic"
+	LoggingLevelFatal         = "fatal"
 )
 
 type (
@@ -68,21 +78,84 @@ func (d *DataSize) UnmarshalYAML(value *yaml.Node) error {
 	case "tb":
 		*d = DataSize(v * TB)
 	default:
-		return fmt.Errorf("invalid data size format: unknown unit: %s", unit)
+		return fmt.Errorf("invalid data size format: unknown unit: %s", matches[2])
 	}
 
 	return nil
 }
 
 type ConfigEngine struct {
-	Type string `yaml:"type"`
+	Type           string   `yaml:"type"`
+	Shards         int      `yaml:"shards"`
+	MaxMemory      DataSize `yaml:"max_memory"`
+	EvictionPolicy string   `yaml:"eviction_policy"`
+	// DataDirectory keeps tables of lsm engine
+	DataDirectory string `yaml:"data_directory"`
+	// MemtableSize is size of memtable of lsm engine which is flushed to table when it is reached
+	MemtableSize DataSize `yaml:"memtable_size"`
+	// CompactionThreshold is number of tables of similar size which lsm engine merges into one
+	CompactionThreshold int `yaml:"compaction_threshold"`
 }
 
 type ConfigNetwork struct {
 	Address        string   `yaml:"address"`
+	RespAddress    string   `yaml:"resp_address"`
 	MaxConnections int      `yaml:"max_connections"`
 	MaxMessageSize DataSize `yaml:"max_message_size"`
 	IdleTimeout    Timeout  `yaml:"idle_timeout"`
+	// ShutdownTimeout limits time of waiting for in-flight queries on shutdown, then connections are closed
+	ShutdownTimeout Timeout `yaml:"shutdown_timeout"`
+	// TLS is enabled if certificate and key are set, they are reloaded on SIGHUP
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// TLSCAFile is used to verify client certificates
+	TLSCAFile string `yaml:"tls_ca_file"`
+	// TLSClientAuth requires client certificate signed by CA (mutual TLS)
+	TLSClientAuth bool `yaml:"tls_client_auth"`
+}
+
+type ConfigWAL struct {
+	DataDirectory        string   `yaml:"data_directory"`
+	MaxSegmentSize       DataSize `yaml:"max_segment_size"`
+	FlushingBatchSize    int      `yaml:"flushing_batch_size"`
+	FlushingBatchTimeout Timeout  `yaml:"flushing_batch_timeout"`
+	Fsync                bool     `yaml:"fsync"`
+}
+
+type ConfigSnapshot struct {
+	DataDirectory   string  `yaml:"data_directory"`
+	Interval        Timeout `yaml:"interval"`
+	WritesThreshold int     `yaml:"writes_threshold"`
+}
+
+type ConfigReplication struct {
+	ReplicaType   string  `yaml:"replica_type"`
+	MasterAddress string  `yaml:"master_address"`
+	SyncInterval  Timeout `yaml:"sync_interval"`
+	// User and Password authenticate slave on master which has users, user must be allowed to access all keys.
+	// Password is plain, it is sent to master like AUTH of clients does.
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// ConfigAuth lists users, clients must authenticate with AUTH if there is any.
+type ConfigAuth struct {
+	Users []ConfigUser `yaml:"users"`
+}
+
+type ConfigUser struct {
+	Name string `yaml:"name"`
+	// Password is a hash made by cmd/passwd, plain password is never stored
+	Password string `yaml:"password"`
+	// Commands which user may run, "*" allows all of them
+	Commands []string `yaml:"commands"`
+	// Keys are glob patterns of keys which user may touch, e.g. "user:*"
+	Keys []string `yaml:"keys"`
+}
+
+// ConfigMetrics enables HTTP listener which serves metrics in Prometheus text format.
+type ConfigMetrics struct {
+	Address string `yaml:"address"`
 }
 
 type ConfigLogging struct {
@@ -91,9 +164,14 @@ type ConfigLogging struct {
 }
 
 type Config struct {
-	Engine  ConfigEngine  `yaml:"engine"`
-	Network ConfigNetwork `yaml:"network"`
-	Logging ConfigLogging `yaml:"logging"`
+	Engine      ConfigEngine      `yaml:"engine"`
+	Network     ConfigNetwork     `yaml:"network"`
+	WAL         ConfigWAL         `yaml:"wal"`
+	Snapshot    ConfigSnapshot    `yaml:"snapshot"`
+	Replication ConfigReplication `yaml:"replication"`
+	Auth        ConfigAuth        `yaml:"auth"`
+	Metrics     ConfigMetrics     `yaml:"metrics"`
+	Logging     ConfigLogging     `yaml:"logging"`
 }
 
 // Parse reads a YAML config file and unmarshals it into Config.
@@ -113,13 +191,29 @@ func Parse(filePath string) (Config, error) {
 func Default() Config {
 	return Config{
 		Engine: ConfigEngine{
-			Type: EngineTypeInMemory,
+			Type:           EngineTypeInMemory,
+			Shards:         64,
+			EvictionPolicy: EvictionPolicyNoEviction,
 		},
 		Network: ConfigNetwork{
-			Address:        "127.0.0.1:0",
-			MaxConnections: 5,
-			MaxMessageSize: DataSize(4 * KB),
-			IdleTimeout:    Timeout(5 * time.Minute),
+			Address:         "127.0.0.1:0",
+			MaxConnections:  5,
+			MaxMessageSize:  DataSize(4 * KB),
+			IdleTimeout:     Timeout(5 * time.Minute),
+			ShutdownTimeout: Timeout(10 * time.Second),
+		},
+		WAL: ConfigWAL{
+			MaxSegmentSize:       DataSize(10 * MB),
+			FlushingBatchSize:    100,
+			FlushingBatchTimeout: Timeout(10 * time.Millisecond),
+			Fsync:                true,
+		},
+		Snapshot: ConfigSnapshot{
+			Interval:        Timeout(time.Hour),
+			WritesThreshold: 100000,
+		},
+		Replication: ConfigReplication{
+			SyncInterval: Timeout(time.Second),
 		},
 		Logging: ConfigLogging{
 			Level:  LoggingLevelInfo,
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -44,6 +45,170 @@ logging:
 				},
 			},
 		},
+		{
+			name: "valid_config_with_wal",
+			content: `
+engine:
+  type: "in_memory_sharded"
+  shards: 32
+  max_memory: 1GB
+  eviction_policy: "allkeys-lru"
+network:
+  address: "127.0.0.1:0"
+  resp_address: "127.0.0.1:6379"
+  max_connections: 100
+  max_message_size: 4kb
+  idle_timeout: 5m
+  shutdown_timeout: 30s
+  tls_cert_file: "/etc/simple-kv/server.crt"
+  tls_key_file: "/etc/simple-kv/server.key"
+  tls_ca_file: "/etc/simple-kv/ca.crt"
+  tls_client_auth: true
+wal:
+  data_directory: "/data/simple-kv/wal"
+  max_segment_size: 10MB
+  flushing_batch_size: 100
+  flushing_batch_timeout: 10ms
+  fsync: true
+snapshot:
+  data_directory: "/data/simple-kv/snapshots"
+  interval: 1h
+  writes_threshold: 1000
+metrics:
+  address: "127.0.0.1:9100"
+logging:
+  level: "info"
+  output: "/log/output.log"
+`,
+			wantConfig: Config{
+				Engine: ConfigEngine{
+					Type:           "in_memory_sharded",
+					Shards:         32,
+					MaxMemory:      DataSize(GB),
+					EvictionPolicy: "allkeys-lru",
+				},
+				Network: ConfigNetwork{
+					Address:         "127.0.0.1:0",
+					RespAddress:     "127.0.0.1:6379",
+					MaxConnections:  100,
+					MaxMessageSize:  DataSize(4 * KB),
+					IdleTimeout:     Timeout(5 * time.Minute),
+					ShutdownTimeout: Timeout(30 * time.Second),
+					TLSCertFile:     "/etc/simple-kv/server.crt",
+					TLSKeyFile:      "/etc/simple-kv/server.key",
+					TLSCAFile:       "/etc/simple-kv/ca.crt",
+					TLSClientAuth:   true,
+				},
+				WAL: ConfigWAL{
+					DataDirectory:        "/data/simple-kv/wal",
+					MaxSegmentSize:       DataSize(10 * MB),
+					FlushingBatchSize:    100,
+					FlushingBatchTimeout: Timeout(10 * time.Millisecond),
+					Fsync:                true,
+				},
+				Snapshot: ConfigSnapshot{
+					DataDirectory:   "/data/simple-kv/snapshots",
+					Interval:        Timeout(time.Hour),
+					WritesThreshold: 1000,
+				},
+				Metrics: ConfigMetrics{
+					Address: "127.0.0.1:9100",
+				},
+				Logging: ConfigLogging{
+					Level:  "info",
+					Output: "/log/output.log",
+				},
+			},
+		},
+		{
+			name: "valid_config_with_replication",
+			content: `
+engine:
+  type: "in_memory"
+network:
+  address: "127.0.0.1:0"
+  max_connections: 100
+  max_message_size: 4kb
+  idle_timeout: 5m
+replication:
+  replica_type: "slave"
+  master_address: "127.0.0.1:9091"
+  sync_interval: 1s
+logging:
+  level: "info"
+  output: "/log/output.log"
+`,
+			wantConfig: Config{
+				Engine: ConfigEngine{
+					Type: "in_memory",
+				},
+				Network: ConfigNetwork{
+					Address:        "127.0.0.1:0",
+					MaxConnections: 100,
+					MaxMessageSize: DataSize(4 * KB),
+					IdleTimeout:    Timeout(5 * time.Minute),
+				},
+				Replication: ConfigReplication{
+					ReplicaType:   "slave",
+					MasterAddress: "127.0.0.1:9091",
+					SyncInterval:  Timeout(time.Second),
+				},
+				Logging: ConfigLogging{
+					Level:  "info",
+					Output: "/log/output.log",
+				},
+			},
+		},
+		{
+			name: "valid_config_with_auth",
+			content: `
+engine:
+  type: "in_memory"
+network:
+  address: "127.0.0.1:0"
+auth:
+  users:
+    - name: "admin"
+      password: "pbkdf2-sha256$1000$c2FsdA$aGFzaA"
+      commands: ["*"]
+      keys: ["*"]
+    - name: "reader"
+      password: "pbkdf2-sha256$1000$c2FsdA$aGFzaA"
+      commands: ["GET", "TTL"]
+      keys: ["public:*"]
+logging:
+  level: "info"
+  output: "/log/output.log"
+`,
+			wantConfig: Config{
+				Engine: ConfigEngine{
+					Type: "in_memory",
+				},
+				Network: ConfigNetwork{
+					Address: "127.0.0.1:0",
+				},
+				Auth: ConfigAuth{
+					Users: []ConfigUser{
+						{
+							Name:     "admin",
+							Password: "pbkdf2-sha256$1000$c2FsdA$aGFzaA",
+							Commands: []string{"*"},
+							Keys:     []string{"*"},
+						},
+						{
+							Name:     "reader",
+							Password: "pbkdf2-sha256$1000$c2FsdA$aGFzaA",
+							Commands: []string{"GET", "TTL"},
+							Keys:     []string{"public:*"},
+						},
+					},
+				},
+				Logging: ConfigLogging{
+					Level:  "info",
+					Output: "/log/output.log",
+				},
+			},
+		},
 		{
 			name: "invalid_timeout",
 			content: `
@@ -127,7 +292,7 @@ engine
 				if err != nil {
 					t.Errorf("unexpected error: %v", err)
 				}
-				if tt.wantConfig != cfg {
+				if !reflect.DeepEqual(tt.wantConfig, cfg) {
 					t.Errorf("expected config %+v, got %+v", tt.wantConfig, cfg)
 				}
 			}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDBWithACL(t *testing.T) *DB {
+	t.Helper()
+
+	hash, err := auth.HashPassword("secret", 1000)
+	require.NoError(t, err)
+
+	acl, err := auth.NewACL(config.ConfigAuth{
+		Users: []config.ConfigUser{
+			{Name: "admin", Password: hash, Commands: []string{auth.AllowAll}, Keys: []string{auth.AllowAll}},
+			{Name: "reader", Password: hash, Commands: []string{"GET", "KEYS", "MULTI", "EXEC"}, Keys: []string{"public:*"}},
+		},
+	})
+	require.NoError(t, err)
+
+	return NewDB(
+		interpreter.NewInterpreter(parser.NewParser()),
+		storage.NewStorage(engine.NewEngine()),
+		WithACL(acl),
+	)
+}
+
+func TestSession_Auth(t *testing.T) {
+	db := newTestDBWithACL(t)
+	admin := db.NewSession()
+	reader := db.NewSession()
+
+	steps := []struct {
+		session  *Session
+		query    string
+		want     Response
+		wantCode ErrorCode
+	}{
+		{session: admin, query: "SET public:1 a", wantCode: ErrorCodeNoAuth},
+		{session: admin, query: "AUTH admin wrong", wantCode: ErrorCodeWrongPass},
+		{session: admin, query: "AUTH nobody secret", wantCode: ErrorCodeWrongPass},
+		{session: admin, query: "AUTH admin secret", want: OKResponse()},
+		{session: admin, query: "SET public:1 a", want: OKResponse()},
+		{session: admin, query: "SET private:1 b", want: OKResponse()},
+		{session: reader, query: "AUTH reader secret", want: OKResponse()},
+		{session: reader, query: "GET public:1", want: ValueResponse("a")},
+		{session: reader, query: "GET private:1", wantCode: ErrorCodeNoPerm},
+		{session: reader, query: "SET public:1 c", wantCode: ErrorCodeNoPerm},
+		// keys which user can't access are hidden
+		{session: reader, query: `KEYS "*"`, want: ArrayResponse(ValueResponse("public:1"))},
+		// denied command discards transaction
+		{session: reader, query: "MULTI", want: OKResponse()},
+		{session: reader, query: "GET public:1", want: StatusResponse(StatusQueued)},
+		{session: reader, query: "DEL public:1", wantCode: ErrorCodeNoPerm},
+		{session: reader, query: "EXEC", wantCode: ErrorCodeExecAbort},
+		{session: reader, query: "GET public:1", want: ValueResponse("a")},
+	}
+
+	for _, step := range steps {
+		got := step.session.Exec(step.query)
+		if step.wantCode != "" {
+			require.Equalf(t, ErrorResponseType, got.Type, "query %q", step.query)
+			require.Equalf(t, step.wantCode, got.Code, "query %q: error = %v", step.query, got.Err)
+			continue
+		}
+		require.Equalf(t, step.want, got, "query %q", step.query)
+	}
+}
+
+func TestDB_ExecWithACL(t *testing.T) {
+	// shared execution has no authenticated user
+	got := newTestDBWithACL(t).Exec("GET key")
+	require.Equal(t, ErrorCodeNoAuth, got.Code)
+
+	got = newTestDB().NewSession().Exec("AUTH admin secret")
+	require.True(t, errors.Is(got.Err, ErrAuthNotEnabled), "error = %v", got.Err)
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/MitrickX/simple-kv/internal/storage"
+)
+
+// floats which fixed-point form is longer are stored in exponent form, e.g. 1e+300 instead of 301 digits
+const maxFixedFloatLength = 32
+
+var (
+	ErrNotInteger        = errors.New("db error: value is not an integer or out of range")
+	ErrNotFloat          = errors.New("db error: value is not a valid float")
+	ErrIncrementOverflow = errors.New("db error: increment or decrement would overflow")
+)
+
+// incrBy atomically adds delta to integer value of key, missing key is counted from zero.
+func (db *DB) incrBy(st storage.Storage, key string, delta int64) Response {
+	value, err := st.Update(key, func(value string, ok bool) (string, error) {
+		var n int64
+		if ok {
+			var err error
+			if n, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return "", ErrNotInteger
+			}
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return "", ErrIncrementOverflow
+		}
+		return strconv.FormatInt(n+delta, 10), nil
+	})
+	if err != nil {
+		return failResponse(err)
+	}
+
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return IntegerResponse(n)
+}
+
+// incrByFloat atomically adds delta to float value of key, missing key is counted from zero.
+// Result is stored in the shortest form which is parsed back to the same number, see formatFloat.
+func (db *DB) incrByFloat(st storage.Storage, key string, delta float64) Response {
+	value, err := st.Update(key, func(value string, ok bool) (string, error) {
+		var f float64
+		if ok {
+			var err error
+			if f, err = strconv.ParseFloat(value, 64); err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				return "", ErrNotFloat
+			}
+		}
+		f += delta
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", ErrIncrementOverflow
+		}
+		return formatFloat(f), nil
+	})
+	if err != nil {
+		return failResponse(err)
+	}
+
+	return ValueResponse(value)
+}
+
+// formatFloat formats f in fixed-point form, very large and very small numbers are formatted with exponent,
+// so their length is bounded.
+func formatFloat(f float64) string {
+	if s := strconv.FormatFloat(f, 'f', -1, 64); len(s) <= maxFixedFloatLength {
+		return s
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
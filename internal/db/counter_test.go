@@ -0,0 +1,84 @@
+package db
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Counters(t *testing.T) {
+	db := newTestDB()
+
+	steps := []struct {
+		query string
+		want  Response
+	}{
+		{query: "INCR counter", want: IntegerResponse(1)},
+		{query: "INCRBY counter 10", want: IntegerResponse(11)},
+		{query: "DECR counter", want: IntegerResponse(10)},
+		{query: "INCRBY counter -20", want: IntegerResponse(-10)},
+		{query: "GET counter", want: ValueResponse("-10")},
+		{query: "DECR missing", want: IntegerResponse(-1)},
+		{query: "INCRBYFLOAT float 10.5", want: ValueResponse("10.5")},
+		{query: "INCRBYFLOAT float -0.25", want: ValueResponse("10.25")},
+		{query: "INCRBYFLOAT counter 0.5", want: ValueResponse("-9.5")},
+		{query: "INCRBYFLOAT large 1e300", want: ValueResponse("1e+300")},
+		{query: "INCRBYFLOAT large 1e300", want: ValueResponse("2e+300")},
+		{query: "INCRBYFLOAT small 1e-300", want: ValueResponse("1e-300")},
+		{query: "INCRBYFLOAT million 1000000", want: ValueResponse("1000000")},
+		{query: "SET big 9223372036854775806", want: OKResponse()},
+		{query: "INCR big", want: IntegerResponse(9223372036854775807)},
+		{query: "EXPIRE big 100", want: IntegerResponse(1)},
+		{query: "DECR big", want: IntegerResponse(9223372036854775806)},
+		{query: "TTL big", want: IntegerResponse(100)},
+	}
+
+	for _, step := range steps {
+		require.Equalf(t, step.want, db.Exec(step.query), "query %q", step.query)
+	}
+}
+
+func TestDB_CountersErrors(t *testing.T) {
+	db := newTestDB()
+	for _, query := range []string{"SET text abc", "SET float 1.5", "SET max 9223372036854775807", "SET huge 1e308"} {
+		require.Equal(t, OKResponse(), db.Exec(query))
+	}
+
+	tests := []struct {
+		query   string
+		wantErr error
+	}{
+		{query: "INCR text", wantErr: ErrNotInteger},
+		{query: "INCRBY float 1", wantErr: ErrNotInteger},
+		{query: "INCR max", wantErr: ErrIncrementOverflow},
+		{query: "INCRBYFLOAT text 1", wantErr: ErrNotFloat},
+		{query: "INCRBYFLOAT huge 1e308", wantErr: ErrIncrementOverflow},
+	}
+
+	for _, tt := range tests {
+		response := db.Exec(tt.query)
+		require.Equalf(t, ErrorResponseType, response.Type, "query %q", tt.query)
+		require.ErrorIsf(t, response.Err, tt.wantErr, "query %q", tt.query)
+	}
+
+	require.Equal(t, ValueResponse("abc"), db.Exec("GET text"), "failed command doesn't change value")
+}
+
+func TestDB_CountersConcurrent(t *testing.T) {
+	db := newTestDB()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				db.Exec("INCR counter")
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, ValueResponse("1000"), db.Exec("GET counter"))
+}
@@ -2,7 +2,11 @@ package db
 
 import (
 	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"github.com/MitrickX/simple-kv/internal/auth"
 	"github.com/MitrickX/simple-kv/internal/interpreter"
 	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
 	"github.com/MitrickX/simple-kv/internal/storage"
@@ -11,39 +15,174 @@ import (
 type DB struct {
 	interpreter interpreter.Interpreter
 	storage     storage.Storage
+	// nil if authentication is disabled
+	acl *auth.ACL
+	// nil if metrics are disabled
+	metrics *dbMetrics
+	// extra fields of INFO sections
+	infoFuncs         map[string][]InfoFunc
+	startedAt         time.Time
+	commandsProcessed atomic.Uint64
+}
+
+type Option func(*DB)
+
+// WithACL makes clients authenticate with AUTH and checks their access to commands and keys.
+func WithACL(acl *auth.ACL) Option {
+	return func(db *DB) {
+		db.acl = acl
+	}
 }
 
 func NewDB(
 	interpreter interpreter.Interpreter,
 	storage storage.Storage,
+	opts ...Option,
 ) *DB {
-	return &DB{
+	db := &DB{
 		interpreter: interpreter,
 		storage:     storage,
+		startedAt:   time.Now(),
 	}
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	return db
 }
 
-func (db *DB) Exec(query string) (string, error) {
+// Exec executes text query.
+func (db *DB) Exec(query string) Response {
+	start := time.Now()
 	result, err := db.interpreter.Interpret(query)
 	if err != nil {
-		return "", fmt.Errorf("db exec fail: %w", err)
+		db.metrics.parseError()
+		return failResponse(err)
+	}
+
+	response := db.execShared(result)
+	db.observe(result.Command.CommandType, start)
+
+	return response
+}
+
+// ExecArgs executes query already split into command name and arguments.
+func (db *DB) ExecArgs(args []string) Response {
+	start := time.Now()
+	result, err := db.interpreter.InterpretArgs(args)
+	if err != nil {
+		db.metrics.parseError()
+		return failResponse(err)
+	}
+
+	response := db.execShared(result)
+	db.observe(result.Command.CommandType, start)
+
+	return response
+}
+
+func (db *DB) execShared(result *interpreter.Result) Response {
+	if isTxCommand(result.Command.CommandType) || result.Command.CommandType == parser.AuthCommandType {
+		return failResponse(ErrSessionRequired)
+	}
+	// there is no authenticated user without session
+	if db.acl != nil {
+		return failResponse(ErrAuthRequired)
 	}
 
+	return db.exec(db.storage, result, nil)
+}
+
+// exec executes command against storage or transaction of it, user is nil if authentication is disabled,
+// otherwise only keys allowed to user are listed.
+func (db *DB) exec(st storage.Storage, result *interpreter.Result, user *auth.User) Response {
 	switch result.Command.CommandType {
 	case parser.SetCommandType:
-		db.storage.Set(result.Command.Arguments[0], result.Command.Arguments[1])
-		return "ok", nil
+		return db.set(st, result.Command.Arguments)
 	case parser.GetCommandType:
-		val, exists := db.storage.Get(result.Command.Arguments[0])
+		val, exists := st.Get(result.Command.Arguments[0])
 		if exists {
-			return fmt.Sprintf("val: %s", val), nil
+			return ValueResponse(val)
 		} else {
-			return "none", nil
+			return NilResponse()
 		}
-	case parser.DelCommandType:
-		db.storage.Del(result.Command.Arguments[0])
-		return "ok", nil
+	case parser.DelCommandType, parser.MDelCommandType:
+		return db.del(st, result.Command.Arguments)
+	case parser.MGetCommandType:
+		return db.mget(st, result.Command.Arguments)
+	case parser.MSetCommandType:
+		return db.mset(st, result.Command.Arguments)
+	case parser.SetNXCommandType:
+		return db.setNX(st, result.Command.Arguments[0], result.Command.Arguments[1])
+	case parser.GetSetCommandType:
+		return db.getSet(st, result.Command.Arguments[0], result.Command.Arguments[1])
+	case parser.CASCommandType:
+		return db.cas(st, result.Command.Arguments[0], result.Command.Arguments[1], result.Command.Arguments[2])
+	case parser.IncrCommandType:
+		return db.incrBy(st, result.Command.Arguments[0], 1)
+	case parser.DecrCommandType:
+		return db.incrBy(st, result.Command.Arguments[0], -1)
+	case parser.IncrByCommandType:
+		delta, _ := strconv.ParseInt(result.Command.Arguments[1], 10, 64)
+		return db.incrBy(st, result.Command.Arguments[0], delta)
+	case parser.IncrByFloatCommandType:
+		delta, _ := strconv.ParseFloat(result.Command.Arguments[1], 64)
+		return db.incrByFloat(st, result.Command.Arguments[0], delta)
+	case parser.ExpireCommandType:
+		expireAt := expireTime(parser.SetOptionEX, result.Command.Arguments[1])
+		ok, err := st.Expire(result.Command.Arguments[0], expireAt)
+		if err != nil {
+			return failResponse(err)
+		}
+		return BoolResponse(ok)
+	case parser.TTLCommandType:
+		expireAt, exists := st.ExpireAt(result.Command.Arguments[0])
+		return IntegerResponse(ttlSeconds(expireAt, exists))
+	case parser.PersistCommandType:
+		ok, err := st.Persist(result.Command.Arguments[0])
+		if err != nil {
+			return failResponse(err)
+		}
+		return BoolResponse(ok)
+	case parser.SnapshotCommandType:
+		if err := st.Snapshot(); err != nil {
+			return failResponse(err)
+		}
+		return OKResponse()
+	case parser.InfoCommandType:
+		return db.info(result.Command.Arguments)
+	case parser.ScanCommandType:
+		return db.scan(st, result.Command.Arguments, user)
+	case parser.KeysCommandType:
+		return db.keys(st, result.Command.Arguments[0], user)
+	case parser.RangeCommandType, parser.RevRangeCommandType, parser.PrefixCommandType, parser.RevPrefixCommandType:
+		return db.keyRange(st, result.Command, user)
 	default:
-		return "none", nil
+		return failResponse(fmt.Errorf("command %s is not supported", result.Command.CommandType))
+	}
+}
+
+func failResponse(err error) Response {
+	return ErrorResponse(fmt.Errorf("db exec fail: %w", err))
+}
+
+// expireTime converts relative expire time in seconds (EX) or milliseconds (PX) to absolute time.
+// Parser limits expire time by parser.MaxExpireTime, so it doesn't overflow.
+func expireTime(unit string, value string) time.Time {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	if unit == parser.SetOptionPX {
+		return time.Now().Add(time.Duration(n) * time.Millisecond)
+	}
+	return time.Now().Add(time.Duration(n) * time.Second)
+}
+
+// ttlSeconds returns remaining time to live in seconds, -1 if key never expires and -2 if key doesn't exist.
+func ttlSeconds(expireAt time.Time, exists bool) int64 {
+	if !exists {
+		return -2
+	}
+	if expireAt.IsZero() {
+		return -1
 	}
+	return int64(time.Until(expireAt).Round(time.Second) / time.Second)
 }
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/metrics"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB() *DB {
+	return NewDB(
+		interpreter.NewInterpreter(parser.NewParser()),
+		storage.NewStorage(engine.NewEngine()),
+	)
+}
+
+func TestDB_Exec(t *testing.T) {
+	db := newTestDB()
+
+	steps := []struct {
+		query string
+		want  Response
+	}{
+		{query: "SET key none", want: OKResponse()},
+		{query: "GET key", want: ValueResponse("none")},
+		{query: "GET missing", want: NilResponse()},
+		{query: "TTL key", want: IntegerResponse(-1)},
+		{query: "TTL missing", want: IntegerResponse(-2)},
+		{query: "EXPIRE key 100", want: IntegerResponse(1)},
+		{query: "TTL key", want: IntegerResponse(100)},
+		{query: "EXPIRE missing 100", want: IntegerResponse(0)},
+		{query: "PERSIST key", want: IntegerResponse(1)},
+		{query: "PERSIST key", want: IntegerResponse(0)},
+		{query: "DEL key", want: IntegerResponse(1)},
+		{query: "DEL key", want: IntegerResponse(0)},
+		{query: `SET empty ""`, want: OKResponse()},
+		{query: "GET empty", want: ValueResponse("")},
+	}
+
+	for _, step := range steps {
+		require.Equalf(t, step.want, db.Exec(step.query), "query %q", step.query)
+	}
+}
+
+func TestDB_ExecErrorCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		db       *DB
+		query    string
+		wantCode ErrorCode
+		wantErr  error
+	}{
+		{
+			name:     "parser error",
+			db:       newTestDB(),
+			query:    "UPDATE key",
+			wantCode: ErrorCodeGeneric,
+			wantErr:  parser.ErrUnknownCommandType,
+		},
+		{
+			name: "out of memory",
+			db: NewDB(
+				newTestDB().interpreter,
+				storage.NewStorage(engine.NewEngine(engine.WithMaxMemory(1, engine.EvictionPolicyNoEviction))),
+			),
+			query:    "SET key value",
+			wantCode: ErrorCodeOOM,
+			wantErr:  engine.ErrOutOfMemory,
+		},
+		{
+			name: "read-only replica",
+			db: NewDB(
+				newTestDB().interpreter,
+				storage.NewStorage(engine.NewEngine(), storage.WithReadOnly()),
+			),
+			query:    "SET key value",
+			wantCode: ErrorCodeReadOnly,
+			wantErr:  storage.ErrReadOnly,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := tt.db.Exec(tt.query)
+			require.Equal(t, ErrorResponseType, response.Type)
+			require.Equal(t, tt.wantCode, response.Code)
+			require.ErrorIs(t, response.Err, tt.wantErr)
+		})
+	}
+}
+
+func TestDB_Metrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	db := NewDB(
+		interpreter.NewInterpreter(parser.NewParser()),
+		storage.NewStorage(engine.NewEngine()),
+		WithMetrics(registry),
+	)
+	session := db.NewSession()
+
+	for _, query := range []string{"SET key value", "GET key", "UPDATE key", "MULTI", "SET key other", "EXEC"} {
+		session.Exec(query)
+	}
+	db.ExecArgs([]string{"GET", "key"})
+	db.ExecArgs([]string{"GET"})
+
+	commands := map[parser.CommandType]uint64{
+		parser.SetCommandType:   2,
+		parser.GetCommandType:   2,
+		parser.MultiCommandType: 1,
+		parser.ExecCommandType:  1,
+	}
+	for commandType, want := range commands {
+		require.Equalf(t, want, db.metrics.commands.With(string(commandType)).Value(), "command %s", commandType)
+		require.Equalf(t, want, db.metrics.latency.With(string(commandType)).Count(), "latency of %s", commandType)
+	}
+	require.Equal(t, uint64(2), db.metrics.parseErrors.Value())
+}
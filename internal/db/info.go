@@ -0,0 +1,130 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+)
+
+// Sections of INFO in order of output.
+const (
+	InfoSectionServer      = "server"
+	InfoSectionClients     = "clients"
+	InfoSectionStats       = "stats"
+	InfoSectionKeyspace    = "keyspace"
+	InfoSectionMemory      = "memory"
+	InfoSectionPersistence = "persistence"
+	InfoSectionReplication = "replication"
+)
+
+var infoSections = []string{
+	InfoSectionServer,
+	InfoSectionClients,
+	InfoSectionStats,
+	InfoSectionKeyspace,
+	InfoSectionMemory,
+	InfoSectionPersistence,
+	InfoSectionReplication,
+}
+
+var ErrUnknownInfoSection = errors.New("db error: unknown INFO section")
+
+// InfoFunc returns fields of INFO section, it is called on every INFO.
+type InfoFunc func() []MapEntry
+
+// WithInfo adds fields which db doesn't know to section of INFO, e.g. config summary or connected clients.
+// Section must be one of InfoSection constants.
+func WithInfo(section string, fn InfoFunc) Option {
+	return func(db *DB) {
+		if db.infoFuncs == nil {
+			db.infoFuncs = make(map[string][]InfoFunc)
+		}
+		db.infoFuncs[section] = append(db.infoFuncs[section], fn)
+	}
+}
+
+// info reports sections of INFO as map of maps, all sections are reported if section is not set.
+// Sections without fields are skipped.
+func (db *DB) info(args []string) Response {
+	sections := infoSections
+	if len(args) > 0 {
+		switch section := strings.ToLower(args[0]); section {
+		case "all", "default", "everything":
+		default:
+			if !isInfoSection(section) {
+				return failResponse(ErrUnknownInfoSection)
+			}
+			sections = []string{section}
+		}
+	}
+
+	entries := make([]MapEntry, 0, len(sections))
+	for _, section := range sections {
+		fields := db.infoFields(section)
+		for _, fn := range db.infoFuncs[section] {
+			fields = append(fields, fn()...)
+		}
+		if len(fields) > 0 {
+			entries = append(entries, MapEntry{Key: section, Value: MapResponse(fields...)})
+		}
+	}
+
+	return MapResponse(entries...)
+}
+
+// infoFields returns fields of section which db knows by itself.
+func (db *DB) infoFields(section string) []MapEntry {
+	switch section {
+	case InfoSectionServer:
+		return []MapEntry{
+			{Key: "uptime_in_seconds", Value: IntegerResponse(int64(time.Since(db.startedAt) / time.Second))},
+			{Key: "auth_enabled", Value: BoolResponse(db.acl != nil)},
+		}
+	case InfoSectionStats:
+		return []MapEntry{
+			{Key: "total_commands_processed", Value: IntegerResponse(int64(db.commandsProcessed.Load()))},
+		}
+	case InfoSectionKeyspace:
+		stats := db.storage.Stats()
+		return []MapEntry{
+			{Key: "keys", Value: IntegerResponse(int64(stats.Keys))},
+			{Key: "keys_estimated", Value: BoolResponse(stats.KeysEstimated)},
+		}
+	case InfoSectionMemory:
+		stats := db.storage.Stats()
+		return []MapEntry{
+			{Key: "used_memory", Value: IntegerResponse(int64(stats.UsedMemory))},
+			{Key: "max_memory", Value: IntegerResponse(int64(stats.MaxMemory))},
+			{Key: "evicted_keys", Value: IntegerResponse(int64(stats.Evictions))},
+		}
+	case InfoSectionPersistence:
+		var lastSnapshot int64
+		if t := db.storage.LastSnapshotTime(); !t.IsZero() {
+			lastSnapshot = t.Unix()
+		}
+		return []MapEntry{
+			{Key: "last_lsn", Value: IntegerResponse(int64(db.storage.LastLSN()))},
+			{Key: "changes_since_last_snapshot", Value: IntegerResponse(int64(db.storage.ChangesSinceSnapshot()))},
+			{Key: "last_snapshot_time", Value: IntegerResponse(lastSnapshot)},
+		}
+	default:
+		return nil
+	}
+}
+
+func isInfoSection(section string) bool {
+	for _, s := range infoSections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// observe counts processed command, metrics are updated if they are enabled.
+func (db *DB) observe(commandType parser.CommandType, start time.Time) {
+	db.commandsProcessed.Add(1)
+	db.metrics.observe(commandType, start)
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/require"
+)
+
+// infoField finds field of section in INFO response.
+func infoField(t *testing.T, response Response, section string, field string) Response {
+	t.Helper()
+
+	require.Equal(t, MapResponseType, response.Type, "error = %v", response.Err)
+	for _, s := range response.Map {
+		if s.Key != section {
+			continue
+		}
+		for _, f := range s.Value.Map {
+			if f.Key == field {
+				return f.Value
+			}
+		}
+	}
+	t.Fatalf("field %s of section %s is not found", field, section)
+	return Response{}
+}
+
+func TestDB_Info(t *testing.T) {
+	db := NewDB(
+		interpreter.NewInterpreter(parser.NewParser()),
+		storage.NewStorage(engine.NewEngine()),
+		WithInfo(InfoSectionClients, func() []MapEntry {
+			return []MapEntry{{Key: "connected_clients", Value: IntegerResponse(2)}}
+		}),
+	)
+
+	db.Exec("SET a 1")
+	db.Exec("SET b 2")
+	db.Exec("GET a")
+
+	response := db.Exec("INFO")
+	var sections []string
+	for _, section := range response.Map {
+		sections = append(sections, section.Key)
+	}
+	// replication has no fields without replication info of server
+	require.Equal(t, []string{
+		InfoSectionServer, InfoSectionClients, InfoSectionStats, InfoSectionKeyspace, InfoSectionMemory, InfoSectionPersistence,
+	}, sections)
+
+	require.Equal(t, IntegerResponse(2), infoField(t, response, InfoSectionClients, "connected_clients"))
+	require.Equal(t, IntegerResponse(3), infoField(t, response, InfoSectionStats, "total_commands_processed"))
+	require.Equal(t, IntegerResponse(2), infoField(t, response, InfoSectionKeyspace, "keys"))
+	require.Equal(t, BoolResponse(false), infoField(t, response, InfoSectionKeyspace, "keys_estimated"))
+	require.Equal(t, IntegerResponse(0), infoField(t, response, InfoSectionPersistence, "last_snapshot_time"))
+	require.Equal(t, IntegerResponse(0), infoField(t, response, InfoSectionServer, "auth_enabled"))
+
+	response = db.Exec("INFO Keyspace")
+	require.Len(t, response.Map, 1)
+	require.Equal(t, IntegerResponse(2), infoField(t, response, InfoSectionKeyspace, "keys"))
+
+	response = db.Exec("INFO all")
+	require.Len(t, response.Map, len(sections))
+
+	response = db.Exec("INFO unknown")
+	require.True(t, errors.Is(response.Err, ErrUnknownInfoSection), "error = %v", response.Err)
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/metrics"
+)
+
+type dbMetrics struct {
+	commands    *metrics.CounterVec
+	latency     *metrics.HistogramVec
+	parseErrors *metrics.Counter
+}
+
+// WithMetrics counts executed commands by type with their latency and queries which can't be parsed.
+func WithMetrics(registry *metrics.Registry) Option {
+	m := &dbMetrics{
+		commands: registry.NewCounterVec("simplekv_commands_total",
+			"Number of executed commands.", "command"),
+		latency: registry.NewHistogramVec("simplekv_command_duration_seconds",
+			"Latency of command execution, commands queued in transaction are executed by EXEC.", "command", metrics.DefaultLatencyBuckets),
+		parseErrors: registry.NewCounter("simplekv_parse_errors_total",
+			"Number of queries which can't be parsed, e.g. unknown commands or wrong arguments."),
+	}
+
+	return func(db *DB) {
+		db.metrics = m
+	}
+}
+
+// observe is no-op if metrics are disabled.
+func (m *dbMetrics) observe(commandType parser.CommandType, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.commands.With(string(commandType)).Inc()
+	m.latency.With(string(commandType)).Observe(time.Since(start).Seconds())
+}
+
+func (m *dbMetrics) parseError() {
+	if m == nil {
+		return
+	}
+	m.parseErrors.Inc()
+}
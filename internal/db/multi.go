@@ -0,0 +1,43 @@
+package db
+
+import (
+	"github.com/MitrickX/simple-kv/internal/storage"
+)
+
+// del deletes keys and returns number of deleted ones.
+func (db *DB) del(st storage.Storage, keys []string) Response {
+	deleted, err := st.MDel(keys)
+	if err != nil {
+		return failResponse(err)
+	}
+	return IntegerResponse(int64(deleted))
+}
+
+// mget returns array of values of keys, missing keys are nil.
+func (db *DB) mget(st storage.Storage, keys []string) Response {
+	values, found := st.MGet(keys)
+	items := make([]Response, 0, len(keys))
+	for i, value := range values {
+		if found[i] {
+			items = append(items, ValueResponse(value))
+		} else {
+			items = append(items, NilResponse())
+		}
+	}
+	return ArrayResponse(items...)
+}
+
+// mset sets keys and values following each other, arguments are validated by parser.
+func (db *DB) mset(st storage.Storage, args []string) Response {
+	keys := make([]string, 0, len(args)/2)
+	values := make([]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+		values = append(values, args[i+1])
+	}
+
+	if err := st.MSet(keys, values); err != nil {
+		return failResponse(err)
+	}
+	return OKResponse()
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_MultiKey(t *testing.T) {
+	db := newTestDB()
+
+	steps := []struct {
+		query string
+		want  Response
+	}{
+		{query: "MSET a 1 b 2 c 3", want: OKResponse()},
+		{query: "MGET a missing c", want: ArrayResponse(ValueResponse("1"), NilResponse(), ValueResponse("3"))},
+		{query: "MSET a 4 a 5", want: OKResponse()},
+		{query: "GET a", want: ValueResponse("5")},
+		{query: "DEL a b missing", want: IntegerResponse(2)},
+		{query: "MDEL c missing", want: IntegerResponse(1)},
+		{query: "MGET a b c", want: ArrayResponse(NilResponse(), NilResponse(), NilResponse())},
+	}
+
+	for _, step := range steps {
+		require.Equalf(t, step.want, db.Exec(step.query), "query %q", step.query)
+	}
+}
+
+func TestDB_MSetOutOfMemory(t *testing.T) {
+	db := NewDB(
+		newTestDB().interpreter,
+		storage.NewStorage(engine.NewEngine(engine.WithMaxMemory(100, engine.EvictionPolicyNoEviction))),
+	)
+
+	response := db.Exec(`MSET a 1 b "` + string(make([]byte, 100)) + `"`)
+	require.Equal(t, ErrorCodeOOM, response.Code)
+	require.Equal(t, NilResponse(), db.Exec("GET a"), "no key is set if one of them doesn't fit")
+}
+
+func TestDB_MSetAtomic(t *testing.T) {
+	db := newTestDB()
+	require.Equal(t, OKResponse(), db.Exec("MSET a 0 b 0"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			db.ExecArgs([]string{"MSET", "a", "1", "b", "1"})
+			db.ExecArgs([]string{"MSET", "a", "0", "b", "0"})
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		got := db.Exec("MGET a b")
+		require.Equal(t, ArrayResponseType, got.Type)
+		require.Equal(t, got.Array[0], got.Array[1], "MGET sees intermediate state of MSET")
+	}
+	wg.Wait()
+
+	require.ErrorIs(t, db.Exec("MSET a 1 b").Err, parser.ErrNoEnoughArgumentsForMSetCommand)
+}
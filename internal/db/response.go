@@ -0,0 +1,138 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+)
+
+type ResponseType int
+
+const (
+	// StatusResponseType is a short status message, e.g. OK or QUEUED
+	StatusResponseType ResponseType = iota + 1
+	// ValueResponseType is a stored value, it may contain any bytes
+	ValueResponseType
+	// NilResponseType means there is no value, e.g. key doesn't exist
+	NilResponseType
+	IntegerResponseType
+	// ArrayResponseType is a list of responses, nil list means there is no array at all
+	ArrayResponseType
+	ErrorResponseType
+	// MapResponseType is a list of named responses in fixed order, e.g. sections of INFO
+	MapResponseType
+)
+
+// ErrorCode is a machine readable class of error, it is the first word of error message in Redis protocol.
+type ErrorCode string
+
+const (
+	ErrorCodeGeneric   ErrorCode = "ERR"
+	ErrorCodeOOM       ErrorCode = "OOM"
+	ErrorCodeReadOnly  ErrorCode = "READONLY"
+	ErrorCodeExecAbort ErrorCode = "EXECABORT"
+	ErrorCodeNoAuth    ErrorCode = "NOAUTH"
+	ErrorCodeWrongPass ErrorCode = "WRONGPASS"
+	ErrorCodeNoPerm    ErrorCode = "NOPERM"
+)
+
+const (
+	StatusOK     = "OK"
+	StatusQueued = "QUEUED"
+)
+
+// Response is a typed result of query, only field of its type is set.
+type Response struct {
+	Type    ResponseType
+	Status  string
+	Value   string
+	Integer int64
+	Array   []Response
+	Map     []MapEntry
+	Code    ErrorCode
+	Err     error
+}
+
+func StatusResponse(status string) Response {
+	return Response{Type: StatusResponseType, Status: status}
+}
+
+func OKResponse() Response {
+	return StatusResponse(StatusOK)
+}
+
+func ValueResponse(value string) Response {
+	return Response{Type: ValueResponseType, Value: value}
+}
+
+func NilResponse() Response {
+	return Response{Type: NilResponseType}
+}
+
+func IntegerResponse(n int64) Response {
+	return Response{Type: IntegerResponseType, Integer: n}
+}
+
+// BoolResponse is integer 1 or 0, like Redis replies to commands which may have no effect.
+func BoolResponse(ok bool) Response {
+	if ok {
+		return IntegerResponse(1)
+	}
+	return IntegerResponse(0)
+}
+
+func ArrayResponse(items ...Response) Response {
+	if items == nil {
+		items = []Response{}
+	}
+	return Response{Type: ArrayResponseType, Array: items}
+}
+
+// NilArrayResponse means there is no array, e.g. transaction is aborted.
+func NilArrayResponse() Response {
+	return Response{Type: ArrayResponseType}
+}
+
+// MapEntry is a named item of map response.
+type MapEntry struct {
+	Key   string
+	Value Response
+}
+
+func MapResponse(entries ...MapEntry) Response {
+	if entries == nil {
+		entries = []MapEntry{}
+	}
+	return Response{Type: MapResponseType, Map: entries}
+}
+
+// ErrorResponse wraps error with code of its class.
+func ErrorResponse(err error) Response {
+	return Response{Type: ErrorResponseType, Code: errorCode(err), Err: err}
+}
+
+// IsNil reports whether response has no value: nil or nil array.
+func (r Response) IsNil() bool {
+	return r.Type == NilResponseType || (r.Type == ArrayResponseType && r.Array == nil)
+}
+
+func errorCode(err error) ErrorCode {
+	switch {
+	case errors.Is(err, engine.ErrOutOfMemory):
+		return ErrorCodeOOM
+	case errors.Is(err, storage.ErrReadOnly):
+		return ErrorCodeReadOnly
+	case errors.Is(err, ErrTransactionAborted):
+		return ErrorCodeExecAbort
+	case errors.Is(err, ErrAuthRequired):
+		return ErrorCodeNoAuth
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		return ErrorCodeWrongPass
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return ErrorCodeNoPerm
+	default:
+		return ErrorCodeGeneric
+	}
+}
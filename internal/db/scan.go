@@ -0,0 +1,110 @@
+package db
+
+import (
+	"strconv"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/glob"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+)
+
+const (
+	// number of keys examined by one SCAN call without COUNT, like in Redis
+	defaultScanCount = 10
+	// number of keys examined by one step of KEYS, storage isn't locked between steps
+	keysScanCount = 1000
+)
+
+// scan returns array of the next cursor and keys of page, arguments are validated by parser.
+// Pattern is applied to keys of page, so page may be empty while iteration isn't finished.
+func (db *DB) scan(st storage.Storage, args []string, user *auth.User) Response {
+	cursor, _ := strconv.ParseUint(args[0], 10, 64)
+	count := defaultScanCount
+	pattern := ""
+	for i := 1; i+1 < len(args); i += 2 {
+		switch args[i] {
+		case parser.ScanOptionMatch:
+			pattern = args[i+1]
+		case parser.ScanOptionCount:
+			count, _ = strconv.Atoi(args[i+1])
+		}
+	}
+
+	keys, next, err := st.Scan(cursor, count)
+	if err != nil {
+		return failResponse(err)
+	}
+	items := make([]Response, 0, len(keys))
+	for _, key := range keys {
+		if visibleKey(key, pattern, user) {
+			items = append(items, ValueResponse(key))
+		}
+	}
+
+	return ArrayResponse(ValueResponse(strconv.FormatUint(next, 10)), ArrayResponse(items...))
+}
+
+// keys returns all keys matching pattern, it scans storage step by step, so writers aren't blocked for long.
+func (db *DB) keys(st storage.Storage, pattern string, user *auth.User) Response {
+	items := []Response{}
+	var cursor uint64
+	for {
+		keys, next, err := st.Scan(cursor, keysScanCount)
+		if err != nil {
+			return failResponse(err)
+		}
+		for _, key := range keys {
+			if visibleKey(key, pattern, user) {
+				items = append(items, ValueResponse(key))
+			}
+		}
+		if next == 0 {
+			return ArrayResponse(items...)
+		}
+		cursor = next
+	}
+}
+
+// keyRange returns keys of RANGE and PREFIX commands and their reverse forms, arguments are validated by parser.
+func (db *DB) keyRange(st storage.Storage, cmd parser.Command, user *auth.User) Response {
+	reverse := cmd.CommandType == parser.RevRangeCommandType || cmd.CommandType == parser.RevPrefixCommandType
+	// range has start and end, prefix has only prefix, optional LIMIT n follows them
+	bounds := 1
+	if cmd.CommandType == parser.RangeCommandType || cmd.CommandType == parser.RevRangeCommandType {
+		bounds = 2
+	}
+	limit := 0
+	if len(cmd.Arguments) == bounds+2 {
+		limit, _ = strconv.Atoi(cmd.Arguments[bounds+1])
+	}
+
+	var (
+		keys []string
+		err  error
+	)
+	if bounds == 2 {
+		keys, err = st.Range(cmd.Arguments[0], cmd.Arguments[1], limit, reverse)
+	} else {
+		keys, err = st.Prefix(cmd.Arguments[0], limit, reverse)
+	}
+	if err != nil {
+		return failResponse(err)
+	}
+
+	items := make([]Response, 0, len(keys))
+	for _, key := range keys {
+		if visibleKey(key, "", user) {
+			items = append(items, ValueResponse(key))
+		}
+	}
+	return ArrayResponse(items...)
+}
+
+// visibleKey reports whether key matches pattern, empty pattern matches any key, and user may access it.
+func visibleKey(key string, pattern string, user *auth.User) bool {
+	if pattern != "" && !glob.Match(pattern, key) {
+		return false
+	}
+	return user == nil || user.AllowKey(key)
+}
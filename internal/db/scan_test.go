@@ -0,0 +1,97 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Scan(t *testing.T) {
+	db := newTestDB()
+	for _, key := range []string{"user:1", "user:2", "user:3", "order:1", "order:2"} {
+		require.Equal(t, OKResponse(), db.Exec("SET "+key+" value"))
+	}
+
+	scanAll := func(options string) []string {
+		var keys []string
+		cursor := "0"
+		for {
+			got := db.Exec("SCAN " + cursor + options)
+			require.Equal(t, ArrayResponseType, got.Type, "error = %v", got.Err)
+			require.Len(t, got.Array, 2)
+			for _, item := range got.Array[1].Array {
+				keys = append(keys, item.Value)
+			}
+			cursor = got.Array[0].Value
+			if cursor == "0" {
+				sort.Strings(keys)
+				return keys
+			}
+			_, err := strconv.ParseUint(cursor, 10, 64)
+			require.NoError(t, err)
+		}
+	}
+
+	require.Equal(t, []string{"order:1", "order:2", "user:1", "user:2", "user:3"}, scanAll(""))
+	require.Equal(t, []string{"user:1", "user:2", "user:3"}, scanAll(` MATCH "user:*" COUNT 1`))
+	require.Empty(t, scanAll(` MATCH "none:*"`))
+	require.Equal(t, []string{"user:1", "user:2", "user:3"}, scanAll(" MATCH user:* COUNT 2"))
+	require.Len(t, scanAll(" MATCH *"), 5)
+
+	got := db.Exec(`KEYS "order:*"`)
+	require.Equal(t, ArrayResponseType, got.Type)
+	keys := make([]string, 0, len(got.Array))
+	for _, item := range got.Array {
+		keys = append(keys, item.Value)
+	}
+	sort.Strings(keys)
+	require.Equal(t, []string{"order:1", "order:2"}, keys)
+
+	require.Equal(t, ArrayResponse(), db.Exec(`KEYS "none:*"`))
+	require.Len(t, db.Exec("KEYS *").Array, 5)
+}
+
+func TestDB_Range(t *testing.T) {
+	db := NewDB(
+		interpreter.NewInterpreter(parser.NewParser()),
+		storage.NewStorage(engine.NewBTreeEngine()),
+	)
+	for _, key := range []string{"user:100", "user:150", "user:200", "user:250", "order:1"} {
+		require.Equal(t, OKResponse(), db.Exec("SET "+key+" value"))
+	}
+
+	keys := func(keys ...string) Response {
+		items := make([]Response, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, ValueResponse(key))
+		}
+		return ArrayResponse(items...)
+	}
+
+	steps := []struct {
+		query string
+		want  Response
+	}{
+		{query: "RANGE user:100 user:200", want: keys("user:100", "user:150", "user:200")},
+		{query: "RANGE user:100 user:200 LIMIT 2", want: keys("user:100", "user:150")},
+		{query: "REVRANGE user:100 user:200 LIMIT 2", want: keys("user:200", "user:150")},
+		{query: "RANGE user:300 user:400", want: keys()},
+		{query: "PREFIX user:", want: keys("user:100", "user:150", "user:200", "user:250")},
+		{query: "REVPREFIX user: LIMIT 1", want: keys("user:250")},
+		{query: "PREFIX order:", want: keys("order:1")},
+	}
+
+	for _, step := range steps {
+		require.Equalf(t, step.want, db.Exec(step.query), "query %q", step.query)
+	}
+
+	got := newTestDB().Exec("RANGE a z")
+	require.True(t, errors.Is(got.Err, storage.ErrNotOrdered), "error = %v", got.Err)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+)
+
+// errValueMismatch aborts update of CAS, it is never returned to client.
+var errValueMismatch = errors.New("db error: value doesn't match expected one")
+
+// set executes SET, arguments are validated by parser. Unconditional SET without GET is a plain write,
+// otherwise condition is checked and previous value is read atomically with write.
+func (db *DB) set(st storage.Storage, args []string) Response {
+	key, value := args[0], args[1]
+	var (
+		expireAt time.Time
+		cond     storage.Condition
+		get      bool
+	)
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case parser.SetOptionEX, parser.SetOptionPX:
+			expireAt = expireTime(args[i], args[i+1])
+			i++
+		case parser.SetOptionNX:
+			cond = notExists
+		case parser.SetOptionXX:
+			cond = exists
+		case parser.SetOptionGet:
+			get = true
+		}
+	}
+
+	if cond == nil && !get {
+		var err error
+		if expireAt.IsZero() {
+			err = st.Set(key, value)
+		} else {
+			err = st.SetWithExpiration(key, value, expireAt)
+		}
+		if err != nil {
+			return failResponse(err)
+		}
+		return OKResponse()
+	}
+
+	if cond == nil {
+		cond = always
+	}
+	result, err := st.SetIf(key, value, expireAt, cond)
+	if err != nil {
+		return failResponse(err)
+	}
+	switch {
+	case get:
+		return previousValue(result)
+	case result.Applied:
+		return OKResponse()
+	default:
+		return NilResponse()
+	}
+}
+
+// setNX sets key only if it doesn't exist, it returns 1 if key is set.
+func (db *DB) setNX(st storage.Storage, key, value string) Response {
+	result, err := st.SetIf(key, value, time.Time{}, notExists)
+	if err != nil {
+		return failResponse(err)
+	}
+	return BoolResponse(result.Applied)
+}
+
+// getSet sets key and returns its previous value.
+func (db *DB) getSet(st storage.Storage, key, value string) Response {
+	result, err := st.SetIf(key, value, time.Time{}, always)
+	if err != nil {
+		return failResponse(err)
+	}
+	return previousValue(result)
+}
+
+// cas replaces value of key by new one if it equals expected one, it returns 1 if value is replaced.
+// Key keeps its expiration time.
+func (db *DB) cas(st storage.Storage, key, expected, value string) Response {
+	_, err := st.Update(key, func(current string, ok bool) (string, error) {
+		if !ok || current != expected {
+			return "", errValueMismatch
+		}
+		return value, nil
+	})
+	if errors.Is(err, errValueMismatch) {
+		return BoolResponse(false)
+	}
+	if err != nil {
+		return failResponse(err)
+	}
+	return BoolResponse(true)
+}
+
+func previousValue(result storage.SetIfResult) Response {
+	if !result.Exists {
+		return NilResponse()
+	}
+	return ValueResponse(result.Value)
+}
+
+func always(string, bool) bool {
+	return true
+}
+
+func exists(_ string, ok bool) bool {
+	return ok
+}
+
+func notExists(_ string, ok bool) bool {
+	return !ok
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_ConditionalSet(t *testing.T) {
+	db := newTestDB()
+
+	steps := []struct {
+		query string
+		want  Response
+	}{
+		{query: "SET lock a NX", want: OKResponse()},
+		{query: "SET lock b NX", want: NilResponse()},
+		{query: "GET lock", want: ValueResponse("a")},
+		{query: "SET missing b XX", want: NilResponse()},
+		{query: "GET missing", want: NilResponse()},
+		{query: "SET lock b XX EX 100", want: OKResponse()},
+		{query: "TTL lock", want: IntegerResponse(100)},
+		{query: "SET lock c GET", want: ValueResponse("b")},
+		{query: "TTL lock", want: IntegerResponse(-1)},
+		{query: "SET other c NX GET", want: NilResponse()},
+		{query: "SET other d NX GET", want: ValueResponse("c")},
+		{query: "GET other", want: ValueResponse("c")},
+		{query: "SETNX other e", want: IntegerResponse(0)},
+		{query: "SETNX new e", want: IntegerResponse(1)},
+		{query: "GETSET new f", want: ValueResponse("e")},
+		{query: "GETSET fresh f", want: NilResponse()},
+		{query: "GET fresh", want: ValueResponse("f")},
+		{query: "EXPIRE fresh 100", want: IntegerResponse(1)},
+		{query: "CAS fresh wrong g", want: IntegerResponse(0)},
+		{query: "CAS fresh f g", want: IntegerResponse(1)},
+		{query: "GET fresh", want: ValueResponse("g")},
+		{query: "TTL fresh", want: IntegerResponse(100)},
+		{query: "CAS absent f g", want: IntegerResponse(0)},
+		{query: "GET absent", want: NilResponse()},
+	}
+
+	for _, step := range steps {
+		require.Equalf(t, step.want, db.Exec(step.query), "query %q", step.query)
+	}
+}
+
+func TestDB_ConditionalSetConcurrent(t *testing.T) {
+	db := newTestDB()
+
+	var (
+		wg      sync.WaitGroup
+		leaders atomic.Int32
+		swaps   atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if db.Exec("SET leader node NX").Status == StatusOK {
+				leaders.Add(1)
+			}
+			if db.Exec("CAS leader node next").Integer == 1 {
+				swaps.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), leaders.Load(), "only one client acquires key")
+	require.Equal(t, int32(1), swaps.Load(), "only one client swaps value")
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+)
+
+var (
+	ErrNestedMulti         = errors.New("db error: MULTI calls can not be nested")
+	ErrExecWithoutMulti    = errors.New("db error: EXEC without MULTI")
+	ErrDiscardWithoutMulti = errors.New("db error: DISCARD without MULTI")
+	ErrWatchInsideMulti    = errors.New("db error: WATCH inside MULTI is not allowed")
+	ErrTransactionAborted  = errors.New("db error: transaction discarded because of previous errors")
+	ErrWatchedKeyChanged   = errors.New("db error: transaction aborted, watched key changed")
+	ErrSessionRequired     = errors.New("db error: transaction and authentication commands require client session")
+	ErrAuthRequired        = errors.New("db error: authentication required")
+	ErrAuthNotEnabled      = errors.New("db error: AUTH called without any users configured")
+)
+
+// Session keeps transaction state of one client connection.
+// Session is not safe for concurrent use, every connection must have its own.
+type Session struct {
+	db      *DB
+	multi   bool
+	aborted bool
+	queue   []*interpreter.Result
+	// versions of watched keys at the moment of WATCH
+	watched map[string]uint64
+	// authenticated user, nil until AUTH succeeds
+	user *auth.User
+}
+
+// NewSession creates session for client connection.
+func (db *DB) NewSession() *Session {
+	return &Session{db: db}
+}
+
+// Exec executes text query in context of session.
+func (s *Session) Exec(query string) Response {
+	start := time.Now()
+	result, err := s.db.interpreter.Interpret(query)
+	if err != nil {
+		s.db.metrics.parseError()
+		s.abort()
+		return failResponse(err)
+	}
+
+	response := s.exec(result)
+	s.db.observe(result.Command.CommandType, start)
+
+	return response
+}
+
+// ExecArgs executes query already split into command name and arguments in context of session.
+func (s *Session) ExecArgs(args []string) Response {
+	start := time.Now()
+	result, err := s.db.interpreter.InterpretArgs(args)
+	if err != nil {
+		s.db.metrics.parseError()
+		s.abort()
+		return failResponse(err)
+	}
+
+	response := s.exec(result)
+	s.db.observe(result.Command.CommandType, start)
+
+	return response
+}
+
+func (s *Session) exec(result *interpreter.Result) Response {
+	if result.Command.CommandType == parser.AuthCommandType {
+		return s.auth(result.Command.Arguments[0], result.Command.Arguments[1])
+	}
+
+	// denied command can't be queued, so transaction is discarded like on any other error
+	if err := s.Authenticated(); err != nil {
+		s.abort()
+		return failResponse(err)
+	}
+	if s.user != nil {
+		if err := s.user.Allow(result.Command); err != nil {
+			s.abort()
+			return failResponse(err)
+		}
+	}
+
+	switch result.Command.CommandType {
+	case parser.MultiCommandType:
+		if s.multi {
+			return failResponse(ErrNestedMulti)
+		}
+		s.multi = true
+		return OKResponse()
+	case parser.ExecCommandType:
+		responses, err := s.Commit()
+		if errors.Is(err, ErrWatchedKeyChanged) {
+			return NilArrayResponse()
+		}
+		if err != nil {
+			return failResponse(err)
+		}
+		return ArrayResponse(responses...)
+	case parser.DiscardCommandType:
+		if !s.multi {
+			return failResponse(ErrDiscardWithoutMulti)
+		}
+		s.reset()
+		return OKResponse()
+	case parser.WatchCommandType:
+		if s.multi {
+			return failResponse(ErrWatchInsideMulti)
+		}
+		if s.watched == nil {
+			s.watched = make(map[string]uint64, len(result.Command.Arguments))
+		}
+		for _, key := range result.Command.Arguments {
+			if _, ok := s.watched[key]; !ok {
+				s.watched[key] = s.db.storage.KeyVersion(key)
+			}
+		}
+		return OKResponse()
+	case parser.UnwatchCommandType:
+		if s.multi {
+			s.queue = append(s.queue, result)
+			return StatusResponse(StatusQueued)
+		}
+		s.watched = nil
+		return OKResponse()
+	}
+
+	if s.multi {
+		s.queue = append(s.queue, result)
+		return StatusResponse(StatusQueued)
+	}
+
+	return s.db.exec(s.db.storage, result, s.user)
+}
+
+// auth authenticates user of session, failed attempt keeps previous user.
+func (s *Session) auth(name string, password string) Response {
+	if s.db.acl == nil {
+		return failResponse(ErrAuthNotEnabled)
+	}
+
+	user, err := s.db.acl.Authenticate(name, password)
+	if err != nil {
+		return failResponse(err)
+	}
+	s.user = user
+
+	return OKResponse()
+}
+
+// Authenticated returns ErrAuthRequired if users are configured and none of them is authenticated by session.
+// Servers check it before commands they answer by themselves.
+func (s *Session) Authenticated() error {
+	if s.db.acl != nil && s.user == nil {
+		return ErrAuthRequired
+	}
+
+	return nil
+}
+
+// Commit atomically executes queued commands, other clients can't see intermediate state of transaction.
+// Errors of single commands don't stop transaction, they are returned as error responses.
+// Keys of commands and watched keys are locked by storage transaction, so only their writers wait for it.
+// Writes of commands are logged by one write and applied after it, so transaction waits for one flush of log.
+// If log fails, none of writes is applied and error is returned instead of responses.
+func (s *Session) Commit() ([]Response, error) {
+	if !s.multi {
+		return nil, ErrExecWithoutMulti
+	}
+	defer s.reset()
+
+	if s.aborted {
+		return nil, ErrTransactionAborted
+	}
+
+	keys := make([]string, 0, len(s.watched))
+	for key := range s.watched {
+		keys = append(keys, key)
+	}
+	for _, result := range s.queue {
+		keys = append(keys, result.Command.Keys()...)
+	}
+	tx := s.db.storage.Begin(keys)
+
+	for key, version := range s.watched {
+		if s.db.storage.KeyVersion(key) != version {
+			tx.Discard()
+			return nil, ErrWatchedKeyChanged
+		}
+	}
+
+	responses := make([]Response, 0, len(s.queue))
+	for _, result := range s.queue {
+		if result.Command.CommandType == parser.UnwatchCommandType {
+			responses = append(responses, OKResponse())
+			continue
+		}
+		responses = append(responses, s.db.exec(tx, result, s.user))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// abort marks transaction as failed if command can't be queued, EXEC will discard it.
+func (s *Session) abort() {
+	if s.multi {
+		s.aborted = true
+	}
+}
+
+// reset finishes transaction, EXEC and DISCARD also unwatch all keys.
+func (s *Session) reset() {
+	s.multi = false
+	s.aborted = false
+	s.queue = nil
+	s.watched = nil
+}
+
+func isTxCommand(commandType parser.CommandType) bool {
+	switch commandType {
+	case parser.MultiCommandType, parser.ExecCommandType, parser.DiscardCommandType,
+		parser.WatchCommandType, parser.UnwatchCommandType:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_Transaction(t *testing.T) {
+	db := newTestDB()
+	session := db.NewSession()
+
+	queued := StatusResponse(StatusQueued)
+	steps := []struct {
+		query   string
+		want    Response
+		wantErr error
+	}{
+		{query: "SET a 1", want: OKResponse()},
+		{query: "EXEC", wantErr: ErrExecWithoutMulti},
+		{query: "DISCARD", wantErr: ErrDiscardWithoutMulti},
+		{query: "MULTI", want: OKResponse()},
+		{query: "MULTI", wantErr: ErrNestedMulti},
+		{query: "SET a 2", want: queued},
+		{query: "GET a", want: queued},
+		{query: "DEL b", want: queued},
+		{query: "WATCH a", wantErr: ErrWatchInsideMulti},
+		{query: "EXEC", want: ArrayResponse(OKResponse(), ValueResponse("2"), IntegerResponse(0))},
+		{query: "MULTI", want: OKResponse()},
+		{query: "SET a 3", want: queued},
+		{query: "DISCARD", want: OKResponse()},
+		{query: "GET a", want: ValueResponse("2")},
+		{query: "MULTI", want: OKResponse()},
+		{query: "EXEC", want: ArrayResponse()},
+		{query: "MULTI", want: OKResponse()},
+		{query: "SET a", wantErr: parser.ErrNoEnoughArgumentsForSetCommand},
+		{query: "SET a 4", want: queued},
+		{query: "EXEC", wantErr: ErrTransactionAborted},
+		{query: "GET a", want: ValueResponse("2")},
+	}
+
+	for _, step := range steps {
+		got := session.Exec(step.query)
+		if step.wantErr != nil {
+			require.Equalf(t, ErrorResponseType, got.Type, "query %q", step.query)
+			require.Truef(t, errors.Is(got.Err, step.wantErr), "query %q: error = %v, want %v", step.query, got.Err, step.wantErr)
+			continue
+		}
+		require.Equalf(t, step.want, got, "query %q", step.query)
+	}
+}
+
+func TestSession_Watch(t *testing.T) {
+	db := newTestDB()
+	first := db.NewSession()
+	second := db.NewSession()
+
+	exec := func(s *Session, query string) Response {
+		t.Helper()
+		response := s.Exec(query)
+		require.NoErrorf(t, response.Err, "query %q", query)
+		return response
+	}
+
+	// watched key modified by another client aborts transaction
+	exec(first, "WATCH balance")
+	exec(second, "SET balance 100")
+	exec(first, "MULTI")
+	exec(first, "SET balance 50")
+	require.Equal(t, NilArrayResponse(), exec(first, "EXEC"))
+	require.Equal(t, ValueResponse("100"), exec(second, "GET balance"))
+
+	// EXEC unwatches keys, so next transaction commits
+	exec(second, "SET balance 200")
+	exec(first, "MULTI")
+	exec(first, "SET balance 50")
+	require.Equal(t, ArrayResponse(OKResponse()), exec(first, "EXEC"))
+
+	// UNWATCH forgets watched keys
+	exec(first, "WATCH balance")
+	exec(second, "DEL balance")
+	exec(first, "UNWATCH")
+	exec(first, "MULTI")
+	exec(first, "SET balance 10")
+	require.Equal(t, ArrayResponse(OKResponse()), exec(first, "EXEC"))
+}
+
+func TestDB_ExecRejectsTransactionCommands(t *testing.T) {
+	db := newTestDB()
+
+	response := db.Exec("MULTI")
+	require.ErrorIs(t, response.Err, ErrSessionRequired)
+}
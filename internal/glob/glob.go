@@ -0,0 +1,113 @@
+// Package glob matches strings against glob-style patterns like Redis does for keys:
+// * matches any sequence, ? matches any byte, [abc], [a-z] and [^a] match byte of class, \ escapes special byte.
+package glob
+
+// Match reports whether the whole s matches pattern. Malformed class without closing bracket matches itself literally.
+func Match(pattern string, s string) bool {
+	// position of the last star and position in s it is matched up to, used to backtrack
+	star, starS := -1, 0
+
+	p, i := 0, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, starS = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if matched, next, ok := matchClass(pattern, p, s[i]); ok {
+					if matched {
+						p = next
+						i++
+						continue
+					}
+				} else if s[i] == '[' {
+					p++
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+				// trailing backslash matches itself
+				if p+1 == len(pattern) && s[i] == '\\' {
+					p++
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+
+		// mismatch, let the last star match one more byte
+		if star < 0 {
+			return false
+		}
+		starS++
+		p, i = star+1, starS
+	}
+
+	// the rest of pattern must match empty string
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// matchClass matches byte c against class starting at pattern[p] == '['.
+// It returns whether c matched, position after class and false if class is not closed.
+func matchClass(pattern string, p int, c byte) (bool, int, bool) {
+	p++
+	negate := false
+	if p < len(pattern) && pattern[p] == '^' {
+		negate = true
+		p++
+	}
+
+	matched := false
+	for first := true; p < len(pattern); first = false {
+		if pattern[p] == ']' && !first {
+			return matched != negate, p + 1, true
+		}
+
+		lo := pattern[p]
+		if lo == '\\' && p+1 < len(pattern) {
+			p++
+			lo = pattern[p]
+		}
+		p++
+
+		hi := lo
+		if p+1 < len(pattern) && pattern[p] == '-' && pattern[p+1] != ']' {
+			hi = pattern[p+1]
+			if hi == '\\' && p+2 < len(pattern) {
+				p++
+				hi = pattern[p+1]
+			}
+			p += 2
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	return false, 0, false
+}
@@ -0,0 +1,49 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "*", s: "", want: true},
+		{pattern: "*", s: "anything", want: true},
+		{pattern: "", s: "", want: true},
+		{pattern: "", s: "a", want: false},
+		{pattern: "key", s: "key", want: true},
+		{pattern: "key", s: "keys", want: false},
+		{pattern: "user:*", s: "user:42", want: true},
+		{pattern: "user:*", s: "users:42", want: false},
+		{pattern: "*:name", s: "user:42:name", want: true},
+		{pattern: "a*b*c", s: "axxbyyc", want: true},
+		{pattern: "a*b*c", s: "axxbyy", want: false},
+		{pattern: "h?llo", s: "hello", want: true},
+		{pattern: "h?llo", s: "hllo", want: false},
+		{pattern: "h[ae]llo", s: "hallo", want: true},
+		{pattern: "h[ae]llo", s: "hillo", want: false},
+		{pattern: "h[^e]llo", s: "hallo", want: true},
+		{pattern: "h[^e]llo", s: "hello", want: false},
+		{pattern: "id[0-9]", s: "id7", want: true},
+		{pattern: "id[0-9]", s: "idx", want: false},
+		{pattern: "id[9-0]", s: "id5", want: true},
+		{pattern: "[]]", s: "]", want: true},
+		{pattern: "a[-]b", s: "a-b", want: true},
+		{pattern: `a\*b`, s: "a*b", want: true},
+		{pattern: `a\*b`, s: "axb", want: false},
+		{pattern: `a\`, s: `a\`, want: true},
+		{pattern: "a[bc", s: "a[bc", want: true},
+		{pattern: "a[bc", s: "ab", want: false},
+		{pattern: "*[0-9]", s: "key10", want: true},
+		{pattern: "**a", s: "bba", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.s, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.s); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+			}
+		})
+	}
+}
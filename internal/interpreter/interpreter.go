@@ -8,6 +8,7 @@ import (
 
 type Interpreter interface {
 	Interpret(query string) (*Result, error)
+	InterpretArgs(args []string) (*Result, error)
 }
 
 type interpreter struct {
@@ -26,3 +27,12 @@ func (i *interpreter) Interpret(query string) (*Result, error) {
 
 	return &Result{Command: *cmd}, nil
 }
+
+func (i *interpreter) InterpretArgs(args []string) (*Result, error) {
+	cmd, err := i.parser.ParseArgs(args)
+	if err != nil {
+		return nil, fmt.Errorf("interpreter fails: %w", err)
+	}
+
+	return &Result{Command: *cmd}, nil
+}
@@ -3,12 +3,84 @@ package parser
 type CommandType string
 
 const (
-    SetCommandType CommandType = "SET"
-    GetCommandType CommandType = "GET"
-    DelCommandType CommandType = "DEL"
+	SetCommandType         CommandType = "SET"
+	GetCommandType         CommandType = "GET"
+	DelCommandType         CommandType = "DEL"
+	ExpireCommandType      CommandType = "EXPIRE"
+	TTLCommandType         CommandType = "TTL"
+	PersistCommandType     CommandType = "PERSIST"
+	MultiCommandType       CommandType = "MULTI"
+	ExecCommandType        CommandType = "EXEC"
+	DiscardCommandType     CommandType = "DISCARD"
+	WatchCommandType       CommandType = "WATCH"
+	UnwatchCommandType     CommandType = "UNWATCH"
+	SnapshotCommandType    CommandType = "SNAPSHOT"
+	AuthCommandType        CommandType = "AUTH"
+	InfoCommandType        CommandType = "INFO"
+	ScanCommandType        CommandType = "SCAN"
+	KeysCommandType        CommandType = "KEYS"
+	RangeCommandType       CommandType = "RANGE"
+	RevRangeCommandType    CommandType = "REVRANGE"
+	PrefixCommandType      CommandType = "PREFIX"
+	RevPrefixCommandType   CommandType = "REVPREFIX"
+	IncrCommandType        CommandType = "INCR"
+	DecrCommandType        CommandType = "DECR"
+	IncrByCommandType      CommandType = "INCRBY"
+	IncrByFloatCommandType CommandType = "INCRBYFLOAT"
+	SetNXCommandType       CommandType = "SETNX"
+	GetSetCommandType      CommandType = "GETSET"
+	CASCommandType         CommandType = "CAS"
+	MGetCommandType        CommandType = "MGET"
+	MSetCommandType        CommandType = "MSET"
+	MDelCommandType        CommandType = "MDEL"
 )
 
+// Options of SET command
+const (
+	// expire time in seconds
+	SetOptionEX = "EX"
+	// expire time in milliseconds
+	SetOptionPX = "PX"
+	// set only if key doesn't exist
+	SetOptionNX = "NX"
+	// set only if key exists
+	SetOptionXX = "XX"
+	// return previous value of key
+	SetOptionGet = "GET"
+)
+
+// Options of SCAN command
+const (
+	// glob-style pattern of returned keys
+	ScanOptionMatch = "MATCH"
+	// number of keys examined by one call
+	ScanOptionCount = "COUNT"
+)
+
+// RangeOptionLimit limits number of keys returned by RANGE and PREFIX commands.
+const RangeOptionLimit = "LIMIT"
+
 type Command struct {
-    CommandType CommandType
-    Arguments []string
-}
\ No newline at end of file
+	CommandType CommandType
+	Arguments   []string
+}
+
+// Keys returns keys which command touches.
+func (c Command) Keys() []string {
+	switch c.CommandType {
+	case SetCommandType, GetCommandType, ExpireCommandType, TTLCommandType, PersistCommandType,
+		IncrCommandType, DecrCommandType, IncrByCommandType, IncrByFloatCommandType,
+		SetNXCommandType, GetSetCommandType, CASCommandType:
+		return c.Arguments[:1]
+	case DelCommandType, MDelCommandType, MGetCommandType, WatchCommandType:
+		return c.Arguments
+	case MSetCommandType:
+		keys := make([]string, 0, len(c.Arguments)/2)
+		for i := 0; i < len(c.Arguments); i += 2 {
+			keys = append(keys, c.Arguments[i])
+		}
+		return keys
+	default:
+		return nil
+	}
+}
@@ -3,10 +3,37 @@ package parser
 import "errors"
 
 var (
-	ErrNoTokensInQuery                = errors.New("parser error: no tokens in query")
-	ErrNoEnoughArgumentsForSetCommand = errors.New("parser error: no enough arguments for set command")
-	ErrNoEnoughArgumentsForGetCommand = errors.New("parser error: no enough arguments for get command")
-	ErrNoEnoughArgumentsForDelCommand = errors.New("parser error: no enough arguments for del command")
-	ErrUnknownCommandType             = errors.New("parser error: unknown command type")
-	ErrInvalidArgumentFormat          = errors.New("parser error: invalid argument format")
+	ErrNoTokensInQuery                        = errors.New("parser error: no tokens in query")
+	ErrNoEnoughArgumentsForSetCommand         = errors.New("parser error: no enough arguments for set command")
+	ErrNoEnoughArgumentsForGetCommand         = errors.New("parser error: no enough arguments for get command")
+	ErrNoEnoughArgumentsForDelCommand         = errors.New("parser error: no enough arguments for del command")
+	ErrNoEnoughArgumentsForExpireCommand      = errors.New("parser error: no enough arguments for expire command")
+	ErrNoEnoughArgumentsForTTLCommand         = errors.New("parser error: no enough arguments for ttl command")
+	ErrNoEnoughArgumentsForPersistCommand     = errors.New("parser error: no enough arguments for persist command")
+	ErrNoEnoughArgumentsForWatchCommand       = errors.New("parser error: no enough arguments for watch command")
+	ErrNoEnoughArgumentsForAuthCommand        = errors.New("parser error: no enough arguments for auth command")
+	ErrNoEnoughArgumentsForScanCommand        = errors.New("parser error: no enough arguments for scan command")
+	ErrNoEnoughArgumentsForKeysCommand        = errors.New("parser error: no enough arguments for keys command")
+	ErrNoEnoughArgumentsForRangeCommand       = errors.New("parser error: no enough arguments for range command")
+	ErrNoEnoughArgumentsForPrefixCommand      = errors.New("parser error: no enough arguments for prefix command")
+	ErrNoEnoughArgumentsForIncrCommand        = errors.New("parser error: no enough arguments for incr command")
+	ErrNoEnoughArgumentsForDecrCommand        = errors.New("parser error: no enough arguments for decr command")
+	ErrNoEnoughArgumentsForIncrByCommand      = errors.New("parser error: no enough arguments for incrby command")
+	ErrNoEnoughArgumentsForIncrByFloatCommand = errors.New("parser error: no enough arguments for incrbyfloat command")
+	ErrNoEnoughArgumentsForSetNXCommand       = errors.New("parser error: no enough arguments for setnx command")
+	ErrNoEnoughArgumentsForGetSetCommand      = errors.New("parser error: no enough arguments for getset command")
+	ErrNoEnoughArgumentsForCASCommand         = errors.New("parser error: no enough arguments for cas command")
+	ErrNoEnoughArgumentsForMGetCommand        = errors.New("parser error: no enough arguments for mget command")
+	ErrNoEnoughArgumentsForMSetCommand        = errors.New("parser error: no enough arguments for mset command")
+	ErrNoEnoughArgumentsForMDelCommand        = errors.New("parser error: no enough arguments for mdel command")
+	ErrTooManyArguments                       = errors.New("parser error: too many arguments")
+	ErrUnknownCommandType                     = errors.New("parser error: unknown command type")
+	ErrInvalidArgumentFormat                  = errors.New("parser error: invalid argument format")
+	ErrInvalidExpireTime                      = errors.New("parser error: invalid expire time")
+	ErrInvalidCursor                          = errors.New("parser error: invalid cursor")
+	ErrInvalidCount                           = errors.New("parser error: invalid count")
+	ErrInvalidLimit                           = errors.New("parser error: invalid limit")
+	ErrInvalidIncrement                       = errors.New("parser error: invalid increment")
+	ErrConflictingOptions                     = errors.New("parser error: conflicting options")
+	ErrUnknownOption                          = errors.New("parser error: unknown option")
 )
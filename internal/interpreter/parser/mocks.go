@@ -96,3 +96,65 @@ func (_c *MockParser_Parse_Call) RunAndReturn(run func(s string) (*Command, erro
 	_c.Call.Return(run)
 	return _c
 }
+
+// ParseArgs provides a mock function for the type MockParser
+func (_mock *MockParser) ParseArgs(strings []string) (*Command, error) {
+	ret := _mock.Called(strings)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ParseArgs")
+	}
+
+	var r0 *Command
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func([]string) (*Command, error)); ok {
+		return returnFunc(strings)
+	}
+	if returnFunc, ok := ret.Get(0).(func([]string) *Command); ok {
+		r0 = returnFunc(strings)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Command)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = returnFunc(strings)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockParser_ParseArgs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ParseArgs'
+type MockParser_ParseArgs_Call struct {
+	*mock.Call
+}
+
+// ParseArgs is a helper method to define mock.On call
+//   - strings []string
+func (_e *MockParser_Expecter) ParseArgs(strings interface{}) *MockParser_ParseArgs_Call {
+	return &MockParser_ParseArgs_Call{Call: _e.mock.On("ParseArgs", strings)}
+}
+
+func (_c *MockParser_ParseArgs_Call) Run(run func(strings []string)) *MockParser_ParseArgs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 []string
+		if args[0] != nil {
+			arg0 = args[0].([]string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockParser_ParseArgs_Call) Return(command *Command, err error) *MockParser_ParseArgs_Call {
+	_c.Call.Return(command, err)
+	return _c
+}
+
+func (_c *MockParser_ParseArgs_Call) RunAndReturn(run func(strings []string) (*Command, error)) *MockParser_ParseArgs_Call {
+	_c.Call.Return(run)
+	return _c
+}
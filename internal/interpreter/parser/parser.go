@@ -1,16 +1,29 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// MaxExpireTime limits relative expire time of EX, PX and EXPIRE. Expiration time is kept
+// as unix time in nanoseconds, so it must not reach 2262, and longer time would overflow time.Duration.
+const MaxExpireTime = 100 * 365 * 24 * time.Hour
+
 var (
 	regexpArgument = regexp.MustCompile(`\w+`)
+	// patterns of KEYS and SCAN MATCH may consist of glob metacharacters only, e.g. *
+	regexpPattern = regexp.MustCompile(`[\w*?\[\]]`)
 )
 
 type Parser interface {
 	Parse(string) (*Command, error)
+	ParseArgs([]string) (*Command, error)
 }
 
 func NewParser() Parser {
@@ -19,71 +32,301 @@ func NewParser() Parser {
 
 type parser struct{}
 
+// Parse parses text query where arguments are separated by whitespaces and may be quoted.
 func (p *parser) Parse(query string) (*Command, error) {
-	command, err := p.parse(query)
+	tokens, err := tokenize(query)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, arg := range command.Arguments {
-		err := p.validateArgument(arg)
-		if err != nil {
-			return nil, err
+	args := make([]string, 0, len(tokens))
+	for i, tok := range tokens {
+		// bare words must look like words, quoted strings may contain anything
+		if i > 0 && !tok.quoted {
+			if err := p.validateArgument(tok, p.isPattern(tokens, i)); err != nil {
+				return nil, err
+			}
 		}
+		args = append(args, tok.value)
 	}
 
-	return command, nil
+	return p.parse(args)
 }
 
-func (p *parser) parse(query string) (*Command, error) {
-	parts := strings.Fields(query)
+// ParseArgs parses query already split into command name and arguments, e.g. by binary protocol.
+// Arguments are binary safe, so they are not validated as bare words.
+func (p *parser) ParseArgs(args []string) (*Command, error) {
+	return p.parse(args)
+}
 
-	tokens := make([]string, 0, len(parts))
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if len(part) > 0 {
-			tokens = append(tokens, part)
-		}
-	}
+// IsKnownCommand reports whether parser knows command name, so list of commands is kept in one place.
+func IsKnownCommand(name string) bool {
+	_, err := (&parser{}).parse([]string{name})
+	return !errors.Is(err, ErrUnknownCommandType)
+}
 
+func (p *parser) parse(tokens []string) (*Command, error) {
 	if len(tokens) == 0 {
 		return nil, ErrNoTokensInQuery
 	}
 
-	switch CommandType(tokens[0]) {
+	commandType := CommandType(strings.ToUpper(tokens[0]))
+	args := tokens[1:]
+	switch commandType {
 	case SetCommandType:
-		if len(tokens) < 3 {
-			return nil, ErrNoEnoughArgumentsForSetCommand
-		}
-		return &Command{
-			CommandType: SetCommandType,
-			Arguments:   tokens[1:3],
-		}, nil
+		return p.parseSet(tokens)
 	case GetCommandType:
-		if len(tokens) < 2 {
-			return nil, ErrNoEnoughArgumentsForGetCommand
-		}
-		return &Command{
-			CommandType: GetCommandType,
-			Arguments:   tokens[1:2],
-		}, nil
+		return p.parseFixed(commandType, args, 1, ErrNoEnoughArgumentsForGetCommand)
 	case DelCommandType:
-		if len(tokens) < 2 {
-			return nil, ErrNoEnoughArgumentsForDelCommand
+		return p.parseVariadic(commandType, args, 1, ErrNoEnoughArgumentsForDelCommand)
+	case MDelCommandType:
+		return p.parseVariadic(commandType, args, 1, ErrNoEnoughArgumentsForMDelCommand)
+	case MGetCommandType:
+		return p.parseVariadic(commandType, args, 1, ErrNoEnoughArgumentsForMGetCommand)
+	case MSetCommandType:
+		// every key must have value
+		if len(args)%2 != 0 {
+			return nil, ErrNoEnoughArgumentsForMSetCommand
+		}
+		return p.parseVariadic(commandType, args, 2, ErrNoEnoughArgumentsForMSetCommand)
+	case ExpireCommandType:
+		if len(args) == 2 {
+			if !p.isExpireTime(args[1], SetOptionEX, true) {
+				return nil, ErrInvalidExpireTime
+			}
+		}
+		return p.parseFixed(commandType, args, 2, ErrNoEnoughArgumentsForExpireCommand)
+	case TTLCommandType:
+		return p.parseFixed(commandType, args, 1, ErrNoEnoughArgumentsForTTLCommand)
+	case PersistCommandType:
+		return p.parseFixed(commandType, args, 1, ErrNoEnoughArgumentsForPersistCommand)
+	case MultiCommandType, ExecCommandType, DiscardCommandType, UnwatchCommandType, SnapshotCommandType:
+		return p.parseFixed(commandType, args, 0, nil)
+	case WatchCommandType:
+		return p.parseVariadic(commandType, args, 1, ErrNoEnoughArgumentsForWatchCommand)
+	case AuthCommandType:
+		return p.parseFixed(commandType, args, 2, ErrNoEnoughArgumentsForAuthCommand)
+	case InfoCommandType:
+		// section is optional, all sections are reported without it
+		if len(args) > 1 {
+			return nil, ErrTooManyArguments
 		}
 		return &Command{
-			CommandType: DelCommandType,
-			Arguments:   tokens[1:2],
+			CommandType: InfoCommandType,
+			Arguments:   args,
 		}, nil
+	case ScanCommandType:
+		return p.parseScan(tokens)
+	case KeysCommandType:
+		return p.parseFixed(commandType, args, 1, ErrNoEnoughArgumentsForKeysCommand)
+	case RangeCommandType, RevRangeCommandType:
+		if len(args) < 2 {
+			return nil, ErrNoEnoughArgumentsForRangeCommand
+		}
+		return p.parseLimit(commandType, args[:2], args[2:], ErrNoEnoughArgumentsForRangeCommand)
+	case PrefixCommandType, RevPrefixCommandType:
+		if len(args) < 1 {
+			return nil, ErrNoEnoughArgumentsForPrefixCommand
+		}
+		return p.parseLimit(commandType, args[:1], args[1:], ErrNoEnoughArgumentsForPrefixCommand)
+	case IncrCommandType:
+		return p.parseFixed(commandType, args, 1, ErrNoEnoughArgumentsForIncrCommand)
+	case DecrCommandType:
+		return p.parseFixed(commandType, args, 1, ErrNoEnoughArgumentsForDecrCommand)
+	case IncrByCommandType:
+		if len(args) == 2 {
+			if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+				return nil, ErrInvalidIncrement
+			}
+		}
+		return p.parseFixed(commandType, args, 2, ErrNoEnoughArgumentsForIncrByCommand)
+	case IncrByFloatCommandType:
+		if len(args) == 2 && !p.isFiniteFloat(args[1]) {
+			return nil, ErrInvalidIncrement
+		}
+		return p.parseFixed(commandType, args, 2, ErrNoEnoughArgumentsForIncrByFloatCommand)
+	case SetNXCommandType:
+		return p.parseFixed(commandType, args, 2, ErrNoEnoughArgumentsForSetNXCommand)
+	case GetSetCommandType:
+		return p.parseFixed(commandType, args, 2, ErrNoEnoughArgumentsForGetSetCommand)
+	case CASCommandType:
+		return p.parseFixed(commandType, args, 3, ErrNoEnoughArgumentsForCASCommand)
 	default:
 		return nil, ErrUnknownCommandType
 	}
 }
 
-func (p *parser) validateArgument(arg string) error {
-	if regexpArgument.MatchString(arg) {
+// parseFixed parses command which takes exactly n arguments.
+func (p *parser) parseFixed(commandType CommandType, args []string, n int, errNoEnough error) (*Command, error) {
+	if len(args) < n {
+		return nil, errNoEnough
+	}
+	if len(args) > n {
+		return nil, ErrTooManyArguments
+	}
+
+	return &Command{
+		CommandType: commandType,
+		Arguments:   args,
+	}, nil
+}
+
+// parseVariadic parses command which takes at least min arguments.
+func (p *parser) parseVariadic(commandType CommandType, args []string, min int, errNoEnough error) (*Command, error) {
+	if len(args) < min {
+		return nil, errNoEnough
+	}
+
+	return &Command{
+		CommandType: commandType,
+		Arguments:   args,
+	}, nil
+}
+
+// parseSet parses SET key value [EX seconds|PX milliseconds] [NX|XX] [GET], options are kept upper cased
+// in order of query.
+func (p *parser) parseSet(tokens []string) (*Command, error) {
+	if len(tokens) < 3 {
+		return nil, ErrNoEnoughArgumentsForSetCommand
+	}
+
+	args := []string{tokens[1], tokens[2]}
+	var expire, condition bool
+	for i := 3; i < len(tokens); i++ {
+		option := strings.ToUpper(tokens[i])
+		switch option {
+		case SetOptionEX, SetOptionPX:
+			if expire {
+				return nil, ErrConflictingOptions
+			}
+			if i+1 == len(tokens) {
+				return nil, ErrNoEnoughArgumentsForSetCommand
+			}
+			if !p.isExpireTime(tokens[i+1], option, false) {
+				return nil, ErrInvalidExpireTime
+			}
+			expire = true
+			i++
+			args = append(args, option, tokens[i])
+		case SetOptionNX, SetOptionXX:
+			if condition {
+				return nil, ErrConflictingOptions
+			}
+			condition = true
+			args = append(args, option)
+		case SetOptionGet:
+			args = append(args, option)
+		default:
+			return nil, ErrUnknownOption
+		}
+	}
+
+	return &Command{
+		CommandType: SetCommandType,
+		Arguments:   args,
+	}, nil
+}
+
+// parseLimit parses optional LIMIT n after arguments of range commands, option is kept upper cased.
+func (p *parser) parseLimit(commandType CommandType, args []string, options []string, errNoEnough error) (*Command, error) {
+	if len(options) == 0 {
+		return &Command{CommandType: commandType, Arguments: args}, nil
+	}
+	if strings.ToUpper(options[0]) != RangeOptionLimit {
+		return nil, ErrUnknownOption
+	}
+	if len(options) < 2 {
+		return nil, errNoEnough
+	}
+	if !p.isPositiveInteger(options[1]) {
+		return nil, ErrInvalidLimit
+	}
+	if len(options) > 2 {
+		return nil, ErrTooManyArguments
+	}
+
+	return &Command{
+		CommandType: commandType,
+		Arguments:   append(slices.Clip(args), RangeOptionLimit, options[1]),
+	}, nil
+}
+
+// parseScan parses SCAN cursor [MATCH pattern] [COUNT n], options are kept upper cased after cursor.
+func (p *parser) parseScan(tokens []string) (*Command, error) {
+	if len(tokens) < 2 {
+		return nil, ErrNoEnoughArgumentsForScanCommand
+	}
+	if _, err := strconv.ParseUint(tokens[1], 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	args := []string{tokens[1]}
+	for i := 2; i < len(tokens); i += 2 {
+		option := strings.ToUpper(tokens[i])
+		if option != ScanOptionMatch && option != ScanOptionCount {
+			return nil, ErrUnknownOption
+		}
+		if i+1 == len(tokens) {
+			return nil, ErrNoEnoughArgumentsForScanCommand
+		}
+		if option == ScanOptionCount && !p.isPositiveInteger(tokens[i+1]) {
+			return nil, ErrInvalidCount
+		}
+		args = append(args, option, tokens[i+1])
+	}
+
+	return &Command{
+		CommandType: ScanCommandType,
+		Arguments:   args,
+	}, nil
+}
+
+func (p *parser) isPositiveInteger(arg string) bool {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	return err == nil && n > 0
+}
+
+// isExpireTime reports whether arg is expire time in seconds (EX) or milliseconds (PX) not longer than MaxExpireTime.
+// Negative time of EXPIRE deletes key, it is limited the same way.
+func (p *parser) isExpireTime(arg string, unit string, allowNegative bool) bool {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || (n <= 0 && !allowNegative) {
+		return false
+	}
+
+	limit := int64(MaxExpireTime / time.Second)
+	if unit == SetOptionPX {
+		limit = int64(MaxExpireTime / time.Millisecond)
+	}
+	return -limit <= n && n <= limit
+}
+
+func (p *parser) isFiniteFloat(arg string) bool {
+	f, err := strconv.ParseFloat(arg, 64)
+	return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+}
+
+// isPattern reports whether i-th token is glob pattern of KEYS or value of SCAN MATCH option.
+func (p *parser) isPattern(tokens []token, i int) bool {
+	switch CommandType(strings.ToUpper(tokens[0].value)) {
+	case KeysCommandType:
+		return i == 1
+	case ScanCommandType:
+		// options follow cursor by pairs, so their values have odd indexes
+		return i >= 3 && i%2 == 1 && strings.ToUpper(tokens[i-1].value) == ScanOptionMatch
+	default:
+		return false
+	}
+}
+
+func (p *parser) validateArgument(tok token, pattern bool) error {
+	re := regexpArgument
+	if pattern {
+		re = regexpPattern
+	}
+	if re.MatchString(tok.value) {
 		return nil
 	}
 
-	return ErrInvalidArgumentFormat
+	return &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("argument %q must contain word characters or be quoted", tok.value)}
 }
@@ -76,21 +76,513 @@ func TestParser_Parse(t *testing.T) {
 		{
 			name:    "extra arguments for SET",
 			input:   "SET key value extra",
-			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"key", "value"}},
-			wantErr: nil,
+			wantCmd: nil,
+			wantErr: ErrUnknownOption,
 		},
 		{
 			name:    "extra arguments for GET",
 			input:   "GET key extra",
+			wantCmd: nil,
+			wantErr: ErrTooManyArguments,
+		},
+		{
+			name:    "many keys for DEL",
+			input:   "DEL key other",
+			wantCmd: &Command{CommandType: DelCommandType, Arguments: []string{"key", "other"}},
+			wantErr: nil,
+		},
+		{
+			name:    "extra arguments for EXPIRE",
+			input:   "EXPIRE key 10 20",
+			wantCmd: nil,
+			wantErr: ErrTooManyArguments,
+		},
+		{
+			name:    "extra arguments for MULTI",
+			input:   "MULTI now",
+			wantCmd: nil,
+			wantErr: ErrTooManyArguments,
+		},
+		{
+			name:    "extra arguments for RANGE",
+			input:   "RANGE a z LIMIT 10 more",
+			wantCmd: nil,
+			wantErr: ErrTooManyArguments,
+		},
+		{
+			name:    "valid MGET command",
+			input:   "mget a b c",
+			wantCmd: &Command{CommandType: MGetCommandType, Arguments: []string{"a", "b", "c"}},
+			wantErr: nil,
+		},
+		{
+			name:    "MGET command not enough arguments",
+			input:   "MGET",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForMGetCommand,
+		},
+		{
+			name:    "valid MSET command",
+			input:   "MSET a 1 b 2",
+			wantCmd: &Command{CommandType: MSetCommandType, Arguments: []string{"a", "1", "b", "2"}},
+			wantErr: nil,
+		},
+		{
+			name:    "MSET command key without value",
+			input:   "MSET a 1 b",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForMSetCommand,
+		},
+		{
+			name:    "MSET command not enough arguments",
+			input:   "MSET",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForMSetCommand,
+		},
+		{
+			name:    "valid MDEL command",
+			input:   "MDEL a b",
+			wantCmd: &Command{CommandType: MDelCommandType, Arguments: []string{"a", "b"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SET command with EX",
+			input:   "SET session token ex 60",
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"session", "token", SetOptionEX, "60"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SET command with PX",
+			input:   "SET session token PX 1500",
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"session", "token", SetOptionPX, "1500"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SET command with conditions",
+			input:   "SET lock owner px 100 nx get",
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"lock", "owner", SetOptionPX, "100", SetOptionNX, SetOptionGet}},
+			wantErr: nil,
+		},
+		{
+			name:    "SET command with conflicting conditions",
+			input:   "SET lock owner NX XX",
+			wantCmd: nil,
+			wantErr: ErrConflictingOptions,
+		},
+		{
+			name:    "SET command with conflicting expire times",
+			input:   "SET lock owner EX 1 PX 1000",
+			wantCmd: nil,
+			wantErr: ErrConflictingOptions,
+		},
+		{
+			name:    "SET command with EX without time",
+			input:   "SET session token EX",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForSetCommand,
+		},
+		{
+			name:    "SET command with invalid EX time",
+			input:   "SET session token EX soon",
+			wantCmd: nil,
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "SET command with non positive PX time",
+			input:   "SET session token PX 0",
+			wantCmd: nil,
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "SET command with overflowing EX time",
+			input:   "SET session token EX 9223372036",
+			wantCmd: nil,
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "SET command with overflowing PX time",
+			input:   "SET session token PX 9223372036854",
+			wantCmd: nil,
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "SET command with the longest EX time",
+			input:   "SET session token EX 3153600000",
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"session", "token", "EX", "3153600000"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid EXPIRE command",
+			input:   "EXPIRE session 60",
+			wantCmd: &Command{CommandType: ExpireCommandType, Arguments: []string{"session", "60"}},
+			wantErr: nil,
+		},
+		{
+			name:    "EXPIRE command not enough arguments",
+			input:   "EXPIRE session",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForExpireCommand,
+		},
+		{
+			name:    "EXPIRE command invalid time",
+			input:   "EXPIRE session 1.5",
+			wantCmd: nil,
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "EXPIRE command overflowing time",
+			input:   "EXPIRE session -9223372036",
+			wantCmd: nil,
+			wantErr: ErrInvalidExpireTime,
+		},
+		{
+			name:    "valid TTL command",
+			input:   "TTL session",
+			wantCmd: &Command{CommandType: TTLCommandType, Arguments: []string{"session"}},
+			wantErr: nil,
+		},
+		{
+			name:    "TTL command not enough arguments",
+			input:   "TTL",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForTTLCommand,
+		},
+		{
+			name:    "valid PERSIST command",
+			input:   "PERSIST session",
+			wantCmd: &Command{CommandType: PersistCommandType, Arguments: []string{"session"}},
+			wantErr: nil,
+		},
+		{
+			name:    "PERSIST command not enough arguments",
+			input:   "PERSIST",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForPersistCommand,
+		},
+		{
+			name:    "double quoted argument with spaces",
+			input:   `SET greeting "hello world"`,
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"greeting", "hello world"}},
+			wantErr: nil,
+		},
+		{
+			name:    "double quoted argument with escapes",
+			input:   `SET "my key" "say \"hi\"\n\ttab \\ \x00\xfF"`,
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"my key", "say \"hi\"\n\ttab \\ \x00\xff"}},
+			wantErr: nil,
+		},
+		{
+			name:    "single quoted argument",
+			input:   `SET key 'it\'s "raw" \n'`,
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"key", `it's "raw" \n`}},
+			wantErr: nil,
+		},
+		{
+			name:    "quoted empty argument",
+			input:   `SET key ""`,
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"key", ""}},
+			wantErr: nil,
+		},
+		{
+			name:    "quoted punctuation argument",
+			input:   `SET key "***"`,
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"key", "***"}},
+			wantErr: nil,
+		},
+		{
+			name:    "bare punctuation argument",
+			input:   "SET key ***",
+			wantCmd: nil,
+			wantErr: ErrInvalidArgumentFormat,
+		},
+		{
+			name:    "unterminated quoted argument",
+			input:   `SET key "hello`,
+			wantCmd: nil,
+			wantErr: ErrInvalidArgumentFormat,
+		},
+		{
+			name:    "unknown escape sequence",
+			input:   `SET key "\q"`,
+			wantCmd: nil,
+			wantErr: ErrInvalidArgumentFormat,
+		},
+		{
+			name:    "invalid hex escape",
+			input:   `SET key "\xZZ"`,
+			wantCmd: nil,
+			wantErr: ErrInvalidArgumentFormat,
+		},
+		{
+			name:    "closing quote followed by word",
+			input:   `SET key "hello"world`,
+			wantCmd: nil,
+			wantErr: ErrInvalidArgumentFormat,
+		},
+		{
+			name:    "lowercase command",
+			input:   "get key",
 			wantCmd: &Command{CommandType: GetCommandType, Arguments: []string{"key"}},
 			wantErr: nil,
 		},
 		{
-			name:    "extra arguments for DEL",
-			input:   "DEL key extra",
-			wantCmd: &Command{CommandType: DelCommandType, Arguments: []string{"key"}},
+			name:    "valid MULTI command",
+			input:   "MULTI",
+			wantCmd: &Command{CommandType: MultiCommandType, Arguments: []string{}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid EXEC command",
+			input:   "exec",
+			wantCmd: &Command{CommandType: ExecCommandType, Arguments: []string{}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid DISCARD command",
+			input:   "DISCARD",
+			wantCmd: &Command{CommandType: DiscardCommandType, Arguments: []string{}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid WATCH command",
+			input:   "WATCH key1 key2",
+			wantCmd: &Command{CommandType: WatchCommandType, Arguments: []string{"key1", "key2"}},
+			wantErr: nil,
+		},
+		{
+			name:    "WATCH command not enough arguments",
+			input:   "WATCH",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForWatchCommand,
+		},
+		{
+			name:    "valid UNWATCH command",
+			input:   "UNWATCH",
+			wantCmd: &Command{CommandType: UnwatchCommandType, Arguments: []string{}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SNAPSHOT command",
+			input:   "SNAPSHOT",
+			wantCmd: &Command{CommandType: SnapshotCommandType, Arguments: []string{}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid AUTH command",
+			input:   `AUTH admin "p@ss word"`,
+			wantCmd: &Command{CommandType: AuthCommandType, Arguments: []string{"admin", "p@ss word"}},
+			wantErr: nil,
+		},
+		{
+			name:    "AUTH command not enough arguments",
+			input:   "AUTH admin",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForAuthCommand,
+		},
+		{
+			name:    "valid INFO command",
+			input:   "INFO",
+			wantCmd: &Command{CommandType: InfoCommandType, Arguments: []string{}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid INFO command with section",
+			input:   "info memory",
+			wantCmd: &Command{CommandType: InfoCommandType, Arguments: []string{"memory"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SCAN command",
+			input:   "SCAN 0",
+			wantCmd: &Command{CommandType: ScanCommandType, Arguments: []string{"0"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SCAN command with options",
+			input:   `scan 42 count 10 match "user:*"`,
+			wantCmd: &Command{CommandType: ScanCommandType, Arguments: []string{"42", "COUNT", "10", "MATCH", "user:*"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SCAN command with bare pattern",
+			input:   "SCAN 0 MATCH * COUNT 10",
+			wantCmd: &Command{CommandType: ScanCommandType, Arguments: []string{"0", "MATCH", "*", "COUNT", "10"}},
+			wantErr: nil,
+		},
+		{
+			name:    "SCAN command bare metacharacters out of pattern",
+			input:   "SCAN 0 COUNT *",
+			wantCmd: nil,
+			wantErr: ErrInvalidArgumentFormat,
+		},
+		{
+			name:    "SCAN command not enough arguments",
+			input:   "SCAN",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForScanCommand,
+		},
+		{
+			name:    "SCAN command option without value",
+			input:   "SCAN 0 MATCH",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForScanCommand,
+		},
+		{
+			name:    "SCAN command invalid cursor",
+			input:   "SCAN -1",
+			wantCmd: nil,
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "SCAN command invalid count",
+			input:   "SCAN 0 COUNT 0",
+			wantCmd: nil,
+			wantErr: ErrInvalidCount,
+		},
+		{
+			name:    "SCAN command unknown option",
+			input:   "SCAN 0 TYPE string",
+			wantCmd: nil,
+			wantErr: ErrUnknownOption,
+		},
+		{
+			name:    "valid KEYS command",
+			input:   `KEYS "*"`,
+			wantCmd: &Command{CommandType: KeysCommandType, Arguments: []string{"*"}},
+			wantErr: nil,
+		},
+		{
+			name:    "KEYS command with bare pattern",
+			input:   "KEYS *",
+			wantCmd: &Command{CommandType: KeysCommandType, Arguments: []string{"*"}},
+			wantErr: nil,
+		},
+		{
+			name:    "KEYS command with bare pattern of metacharacters",
+			input:   "KEYS [?]*",
+			wantCmd: &Command{CommandType: KeysCommandType, Arguments: []string{"[?]*"}},
+			wantErr: nil,
+		},
+		{
+			name:    "KEYS command not enough arguments",
+			input:   "KEYS",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForKeysCommand,
+		},
+		{
+			name:    "valid RANGE command",
+			input:   "RANGE user:100 user:200",
+			wantCmd: &Command{CommandType: RangeCommandType, Arguments: []string{"user:100", "user:200"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid REVRANGE command with limit",
+			input:   "revrange a z limit 10",
+			wantCmd: &Command{CommandType: RevRangeCommandType, Arguments: []string{"a", "z", "LIMIT", "10"}},
+			wantErr: nil,
+		},
+		{
+			name:    "RANGE command not enough arguments",
+			input:   "RANGE a",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForRangeCommand,
+		},
+		{
+			name:    "RANGE command invalid limit",
+			input:   "RANGE a z LIMIT -1",
+			wantCmd: nil,
+			wantErr: ErrInvalidLimit,
+		},
+		{
+			name:    "valid PREFIX command with limit",
+			input:   "PREFIX user: LIMIT 5",
+			wantCmd: &Command{CommandType: PrefixCommandType, Arguments: []string{"user:", "LIMIT", "5"}},
 			wantErr: nil,
 		},
+		{
+			name:    "valid REVPREFIX command",
+			input:   "REVPREFIX user:",
+			wantCmd: &Command{CommandType: RevPrefixCommandType, Arguments: []string{"user:"}},
+			wantErr: nil,
+		},
+		{
+			name:    "PREFIX command limit without value",
+			input:   "PREFIX user: LIMIT",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForPrefixCommand,
+		},
+		{
+			name:    "PREFIX command unknown option",
+			input:   "PREFIX user: COUNT 5",
+			wantCmd: nil,
+			wantErr: ErrUnknownOption,
+		},
+		{
+			name:    "valid SETNX command",
+			input:   "SETNX lock owner",
+			wantCmd: &Command{CommandType: SetNXCommandType, Arguments: []string{"lock", "owner"}},
+			wantErr: nil,
+		},
+		{
+			name:    "GETSET command not enough arguments",
+			input:   "GETSET lock",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForGetSetCommand,
+		},
+		{
+			name:    "valid CAS command",
+			input:   "cas lock old new",
+			wantCmd: &Command{CommandType: CASCommandType, Arguments: []string{"lock", "old", "new"}},
+			wantErr: nil,
+		},
+		{
+			name:    "CAS command not enough arguments",
+			input:   "CAS lock old",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForCASCommand,
+		},
+		{
+			name:    "valid INCR command",
+			input:   "incr counter",
+			wantCmd: &Command{CommandType: IncrCommandType, Arguments: []string{"counter"}},
+			wantErr: nil,
+		},
+		{
+			name:    "DECR command not enough arguments",
+			input:   "DECR",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForDecrCommand,
+		},
+		{
+			name:    "valid INCRBY command",
+			input:   "INCRBY counter -5",
+			wantCmd: &Command{CommandType: IncrByCommandType, Arguments: []string{"counter", "-5"}},
+			wantErr: nil,
+		},
+		{
+			name:    "INCRBY command invalid increment",
+			input:   "INCRBY counter 1.5",
+			wantCmd: nil,
+			wantErr: ErrInvalidIncrement,
+		},
+		{
+			name:    "valid INCRBYFLOAT command",
+			input:   "INCRBYFLOAT counter 0.5",
+			wantCmd: &Command{CommandType: IncrByFloatCommandType, Arguments: []string{"counter", "0.5"}},
+			wantErr: nil,
+		},
+		{
+			name:    "INCRBYFLOAT command infinite increment",
+			input:   "INCRBYFLOAT counter inf",
+			wantCmd: nil,
+			wantErr: ErrInvalidIncrement,
+		},
+		{
+			name:    "INCRBYFLOAT command not enough arguments",
+			input:   "INCRBYFLOAT counter",
+			wantCmd: nil,
+			wantErr: ErrNoEnoughArgumentsForIncrByFloatCommand,
+		},
 	}
 
 	parser := NewParser()
@@ -106,3 +598,141 @@ func TestParser_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_ParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantCmd *Command
+		wantErr error
+	}{
+		{
+			name:    "valid SET command with spaces in value",
+			args:    []string{"set", "greeting", "hello world"},
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"greeting", "hello world"}},
+			wantErr: nil,
+		},
+		{
+			name:    "valid SET command with EX",
+			args:    []string{"SET", "session", "token", "EX", "10"},
+			wantCmd: &Command{CommandType: SetCommandType, Arguments: []string{"session", "token", SetOptionEX, "10"}},
+			wantErr: nil,
+		},
+		{
+			name:    "no args",
+			args:    nil,
+			wantCmd: nil,
+			wantErr: ErrNoTokensInQuery,
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"FLUSHALL"},
+			wantCmd: nil,
+			wantErr: ErrUnknownCommandType,
+		},
+	}
+
+	parser := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCmd, gotErr := parser.ParseArgs(tt.args)
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("ParseArgs() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotCmd, tt.wantCmd) {
+				t.Errorf("ParseArgs() gotCmd = %v, want %v", gotCmd, tt.wantCmd)
+			}
+		})
+	}
+}
+
+func TestParser_SyntaxErrorPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos int
+	}{
+		{
+			name:    "unterminated quote points to opening quote",
+			input:   `SET key "hello`,
+			wantPos: 9,
+		},
+		{
+			name:    "unknown escape points to backslash",
+			input:   `SET key "ab\q"`,
+			wantPos: 12,
+		},
+		{
+			name:    "invalid hex escape points to backslash",
+			input:   `SET key "\x1"`,
+			wantPos: 10,
+		},
+		{
+			name:    "text after closing quote",
+			input:   `SET 'key'x value`,
+			wantPos: 10,
+		},
+		{
+			name:    "invalid bare argument",
+			input:   "SET key  ***",
+			wantPos: 10,
+		},
+	}
+
+	parser := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.Parse(tt.input)
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse() error = %v, want *SyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.wantPos {
+				t.Errorf("Parse() error position = %d, want %d (%v)", syntaxErr.Pos, tt.wantPos, err)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "bare arguments",
+			input: "GET key",
+			want:  []string{"GET", "key"},
+		},
+		{
+			name:  "quoted argument with escaped line break",
+			input: `SET key "line1\nline2"`,
+			want:  []string{"SET", "key", "line1\nline2"},
+		},
+		{
+			name:  "empty query",
+			input: "   ",
+			want:  []string{},
+		},
+		{
+			name:    "unterminated quote",
+			input:   `SET key "value`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Split() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type token struct {
+	value string
+	// quoted token may contain any bytes, so it's not validated as bare word
+	quoted bool
+	// 1-based byte offset of token in query
+	pos int
+}
+
+// SyntaxError describes where query can't be tokenized or has invalid argument.
+type SyntaxError struct {
+	// 1-based byte offset in query
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d: %s", ErrInvalidArgumentFormat.Error(), e.Pos, e.Msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return ErrInvalidArgumentFormat
+}
+
+// Split splits text query into command name and arguments the same way as Parse does,
+// e.g. to send query by binary protocol.
+func Split(query string) ([]string, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		args = append(args, tok.value)
+	}
+
+	return args, nil
+}
+
+// tokenize splits query into tokens separated by whitespaces.
+// Tokens may be quoted: double-quoted tokens support escapes \" \\ \n \r \t \a \b \0 and \xHH,
+// single-quoted tokens are taken literally except \' and \\.
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+
+	i := 0
+	for i < len(query) {
+		if isSpace(query[i]) {
+			i++
+			continue
+		}
+
+		var (
+			tok token
+			err error
+		)
+		switch query[i] {
+		case '"':
+			tok, i, err = readDoubleQuoted(query, i)
+		case '\'':
+			tok, i, err = readSingleQuoted(query, i)
+		default:
+			tok, i = readBare(query, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens, nil
+}
+
+func readBare(query string, start int) (token, int) {
+	i := start
+	for i < len(query) && !isSpace(query[i]) {
+		i++
+	}
+
+	return token{value: query[start:i], pos: start + 1}, i
+}
+
+func readDoubleQuoted(query string, start int) (token, int, error) {
+	var sb strings.Builder
+
+	i := start + 1
+	for i < len(query) {
+		c := query[i]
+		switch c {
+		case '"':
+			if err := checkClosingQuote(query, i); err != nil {
+				return token{}, 0, err
+			}
+			return token{value: sb.String(), quoted: true, pos: start + 1}, i + 1, nil
+		case '\\':
+			if i+1 >= len(query) {
+				return token{}, 0, &SyntaxError{Pos: start + 1, Msg: "unterminated quoted string"}
+			}
+
+			escape := query[i+1]
+			switch escape {
+			case '"', '\\', '\'':
+				sb.WriteByte(escape)
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'a':
+				sb.WriteByte('\a')
+			case 'b':
+				sb.WriteByte('\b')
+			case '0':
+				sb.WriteByte(0)
+			case 'x':
+				if i+3 >= len(query) || !isHexDigit(query[i+2]) || !isHexDigit(query[i+3]) {
+					return token{}, 0, &SyntaxError{Pos: i + 1, Msg: "invalid hex escape, expected \\xHH"}
+				}
+				sb.WriteByte(hexValue(query[i+2])<<4 | hexValue(query[i+3]))
+				i += 2
+			default:
+				return token{}, 0, &SyntaxError{Pos: i + 1, Msg: fmt.Sprintf("unknown escape sequence \\%c", escape)}
+			}
+			i += 2
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+
+	return token{}, 0, &SyntaxError{Pos: start + 1, Msg: "unterminated quoted string"}
+}
+
+func readSingleQuoted(query string, start int) (token, int, error) {
+	var sb strings.Builder
+
+	i := start + 1
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == '\'':
+			if err := checkClosingQuote(query, i); err != nil {
+				return token{}, 0, err
+			}
+			return token{value: sb.String(), quoted: true, pos: start + 1}, i + 1, nil
+		case c == '\\' && i+1 < len(query) && (query[i+1] == '\'' || query[i+1] == '\\'):
+			sb.WriteByte(query[i+1])
+			i += 2
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+
+	return token{}, 0, &SyntaxError{Pos: start + 1, Msg: "unterminated quoted string"}
+}
+
+// checkClosingQuote forbids tokens like "foo"bar, which are ambiguous.
+func checkClosingQuote(query string, i int) error {
+	if i+1 < len(query) && !isSpace(query[i+1]) {
+		return &SyntaxError{Pos: i + 2, Msg: "closing quote must be followed by a space"}
+	}
+
+	return nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,381 @@
+// Package metrics collects server metrics and exposes them in Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is a content type of Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultLatencyBuckets are upper bounds of latency histogram in seconds, from 10µs to 1s.
+var DefaultLatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// sample is one line of metric family.
+type sample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+type collector interface {
+	samples() []sample
+}
+
+type family struct {
+	name      string
+	help      string
+	typ       string
+	collector collector
+}
+
+// Registry keeps metrics in order of registration, names must be unique.
+type Registry struct {
+	mx       sync.Mutex
+	families []family
+	names    map[string]struct{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+// register panics on duplicate name, it is a programming error like in flag package.
+func (r *Registry) register(name string, help string, typ string, c collector) {
+	defer r.mx.Unlock()
+	r.mx.Lock()
+
+	if _, ok := r.names[name]; ok {
+		panic(fmt.Sprintf("metrics: %s is registered twice", name))
+	}
+	r.names[name] = struct{}{}
+	r.families = append(r.families, family{name: name, help: help, typ: typ, collector: c})
+}
+
+// NewCounter registers counter without labels.
+func (r *Registry) NewCounter(name string, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, typeCounter, c)
+	return c
+}
+
+// NewCounterVec registers counters partitioned by label.
+func (r *Registry) NewCounterVec(name string, help string, label string) *CounterVec {
+	v := &CounterVec{label: label}
+	r.register(name, help, typeCounter, v)
+	return v
+}
+
+// NewCounterFunc registers counter which value is taken from fn on every scrape.
+func (r *Registry) NewCounterFunc(name string, help string, fn func() float64) {
+	r.register(name, help, typeCounter, valueFunc(fn))
+}
+
+// NewGaugeVec registers gauges partitioned by label.
+func (r *Registry) NewGaugeVec(name string, help string, label string) *GaugeVec {
+	v := &GaugeVec{label: label}
+	r.register(name, help, typeGauge, v)
+	return v
+}
+
+// NewGaugeFunc registers gauge which value is taken from fn on every scrape.
+func (r *Registry) NewGaugeFunc(name string, help string, fn func() float64) {
+	r.register(name, help, typeGauge, valueFunc(fn))
+}
+
+// NewHistogramVec registers histograms partitioned by label, buckets are sorted upper bounds.
+func (r *Registry) NewHistogramVec(name string, help string, label string, buckets []float64) *HistogramVec {
+	v := &HistogramVec{label: label, buckets: buckets}
+	r.register(name, help, typeHistogram, v)
+	return v
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mx.Lock()
+	families := append([]family(nil), r.families...)
+	r.mx.Unlock()
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range f.collector.samples() {
+			fmt.Fprintf(cw, "%s%s%s %s\n", f.name, s.suffix, s.labels, formatValue(s.value))
+		}
+	}
+
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves metrics to Prometheus scraper.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+func (c *Counter) samples() []sample {
+	return []sample{{value: float64(c.Value())}}
+}
+
+// CounterVec creates counter for every value of label on first use.
+type CounterVec struct {
+	label    string
+	counters sync.Map
+}
+
+func (v *CounterVec) With(value string) *Counter {
+	if c, ok := v.counters.Load(value); ok {
+		return c.(*Counter)
+	}
+	c, _ := v.counters.LoadOrStore(value, &Counter{})
+	return c.(*Counter)
+}
+
+func (v *CounterVec) samples() []sample {
+	var samples []sample
+	for _, value := range sortedKeys(&v.counters) {
+		c, _ := v.counters.Load(value)
+		samples = append(samples, sample{labels: formatLabel(v.label, value, ""), value: float64(c.(*Counter).Value())})
+	}
+	return samples
+}
+
+// Gauge is a value which may go up and down.
+type Gauge struct {
+	value atomic.Int64
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Set(n int64) {
+	g.value.Store(n)
+}
+
+func (g *Gauge) Value() int64 {
+	return g.value.Load()
+}
+
+// GaugeVec creates gauge for every value of label on first use.
+type GaugeVec struct {
+	label  string
+	gauges sync.Map
+}
+
+func (v *GaugeVec) With(value string) *Gauge {
+	if g, ok := v.gauges.Load(value); ok {
+		return g.(*Gauge)
+	}
+	g, _ := v.gauges.LoadOrStore(value, &Gauge{})
+	return g.(*Gauge)
+}
+
+func (v *GaugeVec) samples() []sample {
+	var samples []sample
+	for _, value := range sortedKeys(&v.gauges) {
+		g, _ := v.gauges.Load(value)
+		samples = append(samples, sample{labels: formatLabel(v.label, value, ""), value: float64(g.(*Gauge).Value())})
+	}
+	return samples
+}
+
+type valueFunc func() float64
+
+func (fn valueFunc) samples() []sample {
+	return []sample{{value: fn()}}
+}
+
+// Histogram counts observations in buckets, sum is kept as float bits to be updated without lock.
+type Histogram struct {
+	buckets []float64
+	// counts[i] is number of observations in bucket i, the last one is +Inf bucket
+	counts  []atomic.Uint64
+	sumBits atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)+1),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	h.counts[i].Add(1)
+
+	for {
+		old := h.sumBits.Load()
+		sum := math.Float64frombits(old) + value
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(sum)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) Count() uint64 {
+	var count uint64
+	for i := range h.counts {
+		count += h.counts[i].Load()
+	}
+	return count
+}
+
+// samples returns cumulative buckets, sum may be a bit behind them on concurrent observations.
+func (h *Histogram) samples(label string, value string) []sample {
+	samples := make([]sample, 0, len(h.buckets)+3)
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		samples = append(samples, sample{
+			suffix: "_bucket",
+			labels: formatLabel(label, value, formatValue(bound)),
+			value:  float64(cumulative),
+		})
+	}
+	cumulative += h.counts[len(h.buckets)].Load()
+	samples = append(samples,
+		sample{suffix: "_bucket", labels: formatLabel(label, value, "+Inf"), value: float64(cumulative)},
+		sample{suffix: "_sum", labels: formatLabel(label, value, ""), value: math.Float64frombits(h.sumBits.Load())},
+		sample{suffix: "_count", labels: formatLabel(label, value, ""), value: float64(cumulative)},
+	)
+	return samples
+}
+
+// HistogramVec creates histogram for every value of label on first use.
+type HistogramVec struct {
+	label      string
+	buckets    []float64
+	histograms sync.Map
+}
+
+func (v *HistogramVec) With(value string) *Histogram {
+	if h, ok := v.histograms.Load(value); ok {
+		return h.(*Histogram)
+	}
+	h, _ := v.histograms.LoadOrStore(value, newHistogram(v.buckets))
+	return h.(*Histogram)
+}
+
+func (v *HistogramVec) samples() []sample {
+	var samples []sample
+	for _, value := range sortedKeys(&v.histograms) {
+		h, _ := v.histograms.Load(value)
+		samples = append(samples, h.(*Histogram).samples(v.label, value)...)
+	}
+	return samples
+}
+
+func sortedKeys(m *sync.Map) []string {
+	var keys []string
+	m.Range(func(key, _ any) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabel formats label pair and le label of histogram bucket if it is set.
+func formatLabel(label string, value string, le string) string {
+	var pairs []string
+	if label != "" {
+		pairs = append(pairs, label+`="`+escapeLabelValue(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	registry := NewRegistry()
+
+	commands := registry.NewCounterVec("commands_total", "Number of commands.", "command")
+	commands.With("SET").Add(2)
+	commands.With("GET").Inc()
+
+	errors := registry.NewCounter("errors_total", "Number of errors,\nmultiline help.")
+	errors.Inc()
+
+	connections := registry.NewGaugeVec("connections", "Open connections.", "server")
+	connections.With("tcp").Inc()
+	connections.With("tcp").Inc()
+	connections.With("tcp").Dec()
+	connections.With(`quoted "name"`).Set(5)
+
+	registry.NewGaugeFunc("keys", "Number of keys.", func() float64 { return 42 })
+	registry.NewCounterFunc("evictions_total", "Evicted keys.", func() float64 { return 3 })
+
+	latency := registry.NewHistogramVec("latency_seconds", "Latency.", "command", []float64{0.1, 1})
+	latency.With("GET").Observe(0.05)
+	latency.With("GET").Observe(0.1)
+	latency.With("GET").Observe(0.5)
+	latency.With("GET").Observe(2)
+
+	var b strings.Builder
+	n, err := registry.WriteTo(&b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+
+	want := `# HELP commands_total Number of commands.
+# TYPE commands_total counter
+commands_total{command="GET"} 1
+commands_total{command="SET"} 2
+# HELP errors_total Number of errors,\nmultiline help.
+# TYPE errors_total counter
+errors_total 1
+# HELP connections Open connections.
+# TYPE connections gauge
+connections{server="quoted \"name\""} 5
+connections{server="tcp"} 1
+# HELP keys Number of keys.
+# TYPE keys gauge
+keys 42
+# HELP evictions_total Evicted keys.
+# TYPE evictions_total counter
+evictions_total 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{command="GET",le="0.1"} 2
+latency_seconds_bucket{command="GET",le="1"} 3
+latency_seconds_bucket{command="GET",le="+Inf"} 4
+latency_seconds_sum{command="GET"} 2.65
+latency_seconds_count{command="GET"} 4
+`
+	assert.Equal(t, want, b.String())
+	assert.Equal(t, uint64(4), latency.With("GET").Count())
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("total", "")
+
+	assert.Panics(t, func() {
+		registry.NewGaugeFunc("total", "", func() float64 { return 0 })
+	})
+}
+
+func TestHistogram_ConcurrentObserve(t *testing.T) {
+	h := newHistogram(DefaultLatencyBuckets)
+
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 1000; j++ {
+				h.Observe(0.001)
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	samples := h.samples("", "")
+	assert.Equal(t, uint64(4000), h.Count())
+	assert.InDelta(t, 4.0, samples[len(samples)-2].value, 1e-9, "sum")
+}
+
+func TestServer(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("requests_total", "Number of requests.").Add(7)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer("", registry, zap.NewNop()).Serve(ctx, ln)
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + Path)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), "\nrequests_total 7\n")
+
+	resp, err = http.Post("http://"+ln.Addr().String()+Path, "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	cancel()
+	require.NoError(t, <-served)
+}
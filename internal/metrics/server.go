@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Path is where metrics are served
+	Path = "/metrics"
+
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Server serves metrics of registry over HTTP.
+type Server struct {
+	address  string
+	registry *Registry
+	logger   *zap.Logger
+}
+
+func NewServer(address string, registry *Registry, logger *zap.Logger) *Server {
+	return &Server{
+		address:  address,
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// Start serves metrics until context is done.
+func (s *Server) Start(ctx context.Context) error {
+	lc := net.ListenConfig{}
+	ln, err := lc.Listen(ctx, "tcp", s.address)
+	if err != nil {
+		s.logger.Error("metrics server failed to listen", zap.String("address", s.address), zap.Error(err))
+		return err
+	}
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves metrics on listener until context is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s.registry)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ErrorLog:          zap.NewStdLog(s.logger),
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	})
+	defer stop()
+
+	s.logger.Info("metrics server listening", zap.String("address", ln.Addr().String()), zap.String("path", Path))
+
+	err := server.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		s.logger.Info("metrics server stopped")
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+)
+
+// Certificates keeps server certificate and CA of client certificates loaded from files of config.
+// They may be reloaded without restarting server, new connections use reloaded ones.
+type Certificates struct {
+	config config.ConfigNetwork
+
+	mx        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// NewCertificates loads certificates, it returns nil if TLS is not configured.
+func NewCertificates(cfg config.ConfigNetwork) (*Certificates, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientAuth {
+			return nil, errors.New("tls error: client authentication requires tls certificate and key")
+		}
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("tls error: both certificate and key must be set")
+	}
+	if cfg.TLSClientAuth && cfg.TLSCAFile == "" {
+		return nil, errors.New("tls error: client authentication requires CA")
+	}
+
+	c := &Certificates{config: cfg}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload reads certificates from files again, previous ones are kept if new ones can't be loaded.
+func (c *Certificates) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.config.TLSCertFile, c.config.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("tls error: can't load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if c.config.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.config.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("tls error: can't read CA: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("tls error: no certificates found in CA file")
+		}
+	}
+
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	c.cert = &cert
+	c.clientCAs = clientCAs
+
+	return nil
+}
+
+// TLSConfig returns config which takes current certificates for every new connection.
+func (c *Certificates) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.mx.RLock()
+			defer c.mx.RUnlock()
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*c.cert},
+				ClientCAs:    c.clientCAs,
+			}
+			switch {
+			case c.config.TLSClientAuth:
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			case c.clientCAs != nil:
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+
+			return cfg, nil
+		},
+	}
+}
+
+// ClientTLSConfig returns config of connections to other servers, e.g. of slave to master.
+// Server certificate is verified by CA, own certificate is sent if server asks for it.
+// Config keeps certificates loaded at the moment of call, so it is taken for every new connection.
+func (c *Certificates) ClientTLSConfig() *tls.Config {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*c.cert},
+		RootCAs:      c.clientCAs,
+	}
+}
+
+// tlsHandshake completes TLS handshake of server connection, so its errors aren't mixed with errors of protocol.
+func tlsHandshake(conn net.Conn) error {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("tls handshake: %w", err)
+	}
+	return nil
+}
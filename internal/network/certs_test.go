@@ -0,0 +1,259 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a self-signed CA which issues certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "simple-kv test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue writes certificate for 127.0.0.1 and its key to files of directory.
+func (ca *testCA) issue(t *testing.T, dir string, name string, usage x509.ExtKeyUsage) (certFile string, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func (ca *testCA) writeTo(t *testing.T, dir string) string {
+	t.Helper()
+
+	file := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(file, ca.pem, 0o600))
+
+	return file
+}
+
+func (ca *testCA) clientConfig(t *testing.T, certFile string, keyFile string) *tls.Config {
+	t.Helper()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: roots}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		require.NoError(t, err)
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg
+}
+
+// queryTLS sends one query over TLS and returns reply and common name of server certificate.
+func queryTLS(address string, cfg *tls.Config, query string) (string, string, error) {
+	conn, err := tls.Dial("tcp", address, cfg)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	if _, err := conn.Write([]byte(MessageHello)); err != nil {
+		return "", "", err
+	}
+	buf := make([]byte, 64)
+	if _, err := conn.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	if _, err := conn.Write([]byte(query + "\n")); err != nil {
+		return "", "", err
+	}
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(buf[:n]), conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestTcpServer_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	certs, err := NewCertificates(config.ConfigNetwork{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	address, _ := startTcpServer(t, ctx, WithTLS(certs))
+
+	reply, name, err := queryTLS(address, ca.clientConfig(t, "", ""), "SET key value")
+	require.NoError(t, err)
+	assert.Equal(t, "OK\n", reply)
+	assert.Equal(t, "server", name)
+
+	// plain text client can't talk to server
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte(MessageHello))
+	buf := make([]byte, 16)
+	n, _ := conn.Read(buf)
+	assert.NotEqual(t, MessageHi, string(buf[:n]))
+}
+
+func TestTcpServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+
+	// client certificate of another CA isn't trusted
+	otherCert, otherKey := newTestCA(t).issue(t, dir, "other", x509.ExtKeyUsageClientAuth)
+
+	certs, err := NewCertificates(config.ConfigNetwork{
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSCAFile:     ca.writeTo(t, dir),
+		TLSClientAuth: true,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	address, _ := startTcpServer(t, ctx, WithTLS(certs))
+
+	_, _, err = queryTLS(address, ca.clientConfig(t, "", ""), "GET key")
+	assert.Error(t, err)
+
+	_, _, err = queryTLS(address, ca.clientConfig(t, otherCert, otherKey), "GET key")
+	assert.Error(t, err)
+
+	reply, _, err := queryTLS(address, ca.clientConfig(t, clientCert, clientKey), "GET key")
+	require.NoError(t, err)
+	assert.Equal(t, "(nil)\n", reply)
+}
+
+func TestCertificates_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "first", x509.ExtKeyUsageServerAuth)
+
+	certs, err := NewCertificates(config.ConfigNetwork{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	address, _ := startTcpServer(t, ctx, WithTLS(certs))
+
+	_, name, err := queryTLS(address, ca.clientConfig(t, "", ""), "GET key")
+	require.NoError(t, err)
+	assert.Equal(t, "first", name)
+
+	// renewed certificate replaces files
+	secondCert, secondKey := ca.issue(t, dir, "second", x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.Rename(secondCert, certFile))
+	require.NoError(t, os.Rename(secondKey, keyFile))
+	require.NoError(t, certs.Reload())
+
+	_, name, err = queryTLS(address, ca.clientConfig(t, "", ""), "GET key")
+	require.NoError(t, err)
+	assert.Equal(t, "second", name)
+
+	// broken files don't replace working certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	assert.Error(t, certs.Reload())
+
+	_, name, err = queryTLS(address, ca.clientConfig(t, "", ""), "GET key")
+	require.NoError(t, err)
+	assert.Equal(t, "second", name)
+}
+
+func TestNewCertificates(t *testing.T) {
+	certs, err := NewCertificates(config.ConfigNetwork{})
+	require.NoError(t, err)
+	assert.Nil(t, certs, "tls is disabled without certificate")
+
+	tests := []struct {
+		name string
+		cfg  config.ConfigNetwork
+	}{
+		{name: "key without certificate", cfg: config.ConfigNetwork{TLSKeyFile: "server.key"}},
+		{name: "client auth without certificate", cfg: config.ConfigNetwork{TLSClientAuth: true}},
+		{name: "client auth without CA", cfg: config.ConfigNetwork{TLSCertFile: "server.crt", TLSKeyFile: "server.key", TLSClientAuth: true}},
+		{name: "missing files", cfg: config.ConfigNetwork{TLSCertFile: "missing.crt", TLSKeyFile: "missing.key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCertificates(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package network
+
+import (
+	"errors"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/db"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/MitrickX/simple-kv/protocol"
+)
+
+// errorCodes are codes of errors known to clients, server wraps its errors, so they are matched by errors.Is.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{parser.ErrNoTokensInQuery, protocol.CodeNoTokensInQuery},
+	{parser.ErrUnknownCommandType, protocol.CodeUnknownCommandType},
+	{parser.ErrInvalidArgumentFormat, protocol.CodeInvalidArgumentFormat},
+	{parser.ErrInvalidExpireTime, protocol.CodeInvalidExpireTime},
+	{parser.ErrInvalidCursor, protocol.CodeInvalidCursor},
+	{engine.ErrUnknownCursor, protocol.CodeInvalidCursor},
+	{parser.ErrInvalidCount, protocol.CodeInvalidCount},
+	{parser.ErrUnknownOption, protocol.CodeUnknownOption},
+	{parser.ErrInvalidLimit, protocol.CodeInvalidLimit},
+	{parser.ErrInvalidIncrement, protocol.CodeInvalidIncrement},
+	{parser.ErrConflictingOptions, protocol.CodeConflictingOptions},
+	{parser.ErrTooManyArguments, protocol.CodeTooManyArguments},
+	{parser.ErrNoEnoughArgumentsForSetCommand, protocol.CodeNoEnoughArgumentsForSetCommand},
+	{parser.ErrNoEnoughArgumentsForGetCommand, protocol.CodeNoEnoughArgumentsForGetCommand},
+	{parser.ErrNoEnoughArgumentsForDelCommand, protocol.CodeNoEnoughArgumentsForDelCommand},
+	{parser.ErrNoEnoughArgumentsForExpireCommand, protocol.CodeNoEnoughArgumentsForExpireCommand},
+	{parser.ErrNoEnoughArgumentsForTTLCommand, protocol.CodeNoEnoughArgumentsForTTLCommand},
+	{parser.ErrNoEnoughArgumentsForPersistCommand, protocol.CodeNoEnoughArgumentsForPersistCommand},
+	{parser.ErrNoEnoughArgumentsForWatchCommand, protocol.CodeNoEnoughArgumentsForWatchCommand},
+	{parser.ErrNoEnoughArgumentsForAuthCommand, protocol.CodeNoEnoughArgumentsForAuthCommand},
+	{parser.ErrNoEnoughArgumentsForScanCommand, protocol.CodeNoEnoughArgumentsForScanCommand},
+	{parser.ErrNoEnoughArgumentsForKeysCommand, protocol.CodeNoEnoughArgumentsForKeysCommand},
+	{parser.ErrNoEnoughArgumentsForRangeCommand, protocol.CodeNoEnoughArgumentsForRangeCommand},
+	{parser.ErrNoEnoughArgumentsForPrefixCommand, protocol.CodeNoEnoughArgumentsForPrefixCommand},
+	{parser.ErrNoEnoughArgumentsForIncrCommand, protocol.CodeNoEnoughArgumentsForIncrCommand},
+	{parser.ErrNoEnoughArgumentsForDecrCommand, protocol.CodeNoEnoughArgumentsForDecrCommand},
+	{parser.ErrNoEnoughArgumentsForIncrByCommand, protocol.CodeNoEnoughArgumentsForIncrByCommand},
+	{parser.ErrNoEnoughArgumentsForIncrByFloatCommand, protocol.CodeNoEnoughArgumentsForIncrByFloatCommand},
+	{parser.ErrNoEnoughArgumentsForSetNXCommand, protocol.CodeNoEnoughArgumentsForSetNXCommand},
+	{parser.ErrNoEnoughArgumentsForGetSetCommand, protocol.CodeNoEnoughArgumentsForGetSetCommand},
+	{parser.ErrNoEnoughArgumentsForCASCommand, protocol.CodeNoEnoughArgumentsForCASCommand},
+	{parser.ErrNoEnoughArgumentsForMGetCommand, protocol.CodeNoEnoughArgumentsForMGetCommand},
+	{parser.ErrNoEnoughArgumentsForMSetCommand, protocol.CodeNoEnoughArgumentsForMSetCommand},
+	{parser.ErrNoEnoughArgumentsForMDelCommand, protocol.CodeNoEnoughArgumentsForMDelCommand},
+	{db.ErrNestedMulti, protocol.CodeNestedMulti},
+	{db.ErrExecWithoutMulti, protocol.CodeExecWithoutMulti},
+	{db.ErrDiscardWithoutMulti, protocol.CodeDiscardWithoutMulti},
+	{db.ErrWatchInsideMulti, protocol.CodeWatchInsideMulti},
+	{db.ErrTransactionAborted, protocol.CodeTransactionAborted},
+	{db.ErrUnknownInfoSection, protocol.CodeUnknownInfoSection},
+	{db.ErrNotInteger, protocol.CodeNotInteger},
+	{db.ErrNotFloat, protocol.CodeNotFloat},
+	{db.ErrIncrementOverflow, protocol.CodeIncrementOverflow},
+	{db.ErrAuthRequired, protocol.CodeAuthRequired},
+	{db.ErrAuthNotEnabled, protocol.CodeAuthNotEnabled},
+	{auth.ErrInvalidCredentials, protocol.CodeInvalidCredentials},
+	{auth.ErrPermissionDenied, protocol.CodePermissionDenied},
+	{engine.ErrOutOfMemory, protocol.CodeOutOfMemory},
+	{storage.ErrReadOnly, protocol.CodeReadOnly},
+	{storage.ErrSnapshotsDisabled, protocol.CodeSnapshotsDisabled},
+	{storage.ErrSnapshotInProgress, protocol.CodeSnapshotInProgress},
+	{storage.ErrNotOrdered, protocol.CodeNotOrdered},
+	{protocol.ErrMessageTooLarge, protocol.CodeMessageTooLarge},
+	{protocol.ErrProtocol, protocol.CodeProtocol},
+}
+
+// frameResponse converts response to its form in framing protocol, errors get codes to be matched by clients.
+func frameResponse(response db.Response) protocol.Response {
+	switch response.Type {
+	case db.StatusResponseType:
+		return protocol.Response{Type: protocol.StatusResponse, Status: response.Status}
+	case db.ValueResponseType:
+		return protocol.Response{Type: protocol.ValueResponse, Value: response.Value}
+	case db.NilResponseType:
+		return protocol.Response{Type: protocol.NilResponse}
+	case db.IntegerResponseType:
+		return protocol.Response{Type: protocol.IntegerResponse, Integer: response.Integer}
+	case db.ArrayResponseType:
+		if response.Array == nil {
+			return protocol.Response{Type: protocol.ArrayResponse}
+		}
+		items := make([]protocol.Response, 0, len(response.Array))
+		for _, item := range response.Array {
+			items = append(items, frameResponse(item))
+		}
+		return protocol.Response{Type: protocol.ArrayResponse, Array: items}
+	case db.MapResponseType:
+		entries := make([]protocol.MapEntry, 0, len(response.Map))
+		for _, entry := range response.Map {
+			entries = append(entries, protocol.MapEntry{Key: entry.Key, Value: frameResponse(entry.Value)})
+		}
+		return protocol.Response{Type: protocol.MapResponse, Map: entries}
+	default:
+		return protocol.Response{
+			Type:    protocol.ErrorResponse,
+			Class:   string(response.Code),
+			Code:    errorCode(response.Err),
+			Message: response.Err.Error(),
+		}
+	}
+}
+
+// errorCode returns code of the first known error in chain of err, empty code if it is unknown.
+func errorCode(err error) string {
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package network
+
+import (
+	"github.com/MitrickX/simple-kv/internal/metrics"
+)
+
+// names of servers in metric labels
+const (
+	serverLabelTcp  = "tcp"
+	serverLabelResp = "resp"
+)
+
+type serverMetrics struct {
+	connections *metrics.GaugeVec
+	rejected    *metrics.CounterVec
+}
+
+// WithMetrics counts open connections and connections rejected on handshake, one option may be shared by servers.
+func WithMetrics(registry *metrics.Registry) ServerOption {
+	m := &serverMetrics{
+		connections: registry.NewGaugeVec("simplekv_active_connections",
+			"Number of open client connections, it is limited by max_connections.", "server"),
+		rejected: registry.NewCounterVec("simplekv_rejected_connections_total",
+			"Number of connections closed because of failed handshake, e.g. TLS error or missing HELLO.", "server"),
+	}
+
+	return func(o *serverOptions) {
+		o.metrics = m
+	}
+}
+
+// Methods are no-op if metrics are disabled.
+
+func (m *serverMetrics) connOpened(server string) {
+	if m != nil {
+		m.connections.With(server).Inc()
+	}
+}
+
+func (m *serverMetrics) connClosed(server string) {
+	if m != nil {
+		m.connections.With(server).Dec()
+	}
+}
+
+func (m *serverMetrics) connRejected(server string) {
+	if m != nil {
+		m.rejected.With(server).Inc()
+	}
+}
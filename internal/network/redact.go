@@ -0,0 +1,38 @@
+package network
+
+import (
+	"strings"
+
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+)
+
+const redacted = "***"
+
+// redactQuery hides credentials of AUTH command, so they don't get into logs.
+func redactQuery(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) > 0 && strings.EqualFold(fields[0], string(parser.AuthCommandType)) {
+		return fields[0] + " " + redacted
+	}
+
+	return query
+}
+
+// redactArgs hides credentials of AUTH command and AUTH option of HELLO, so they don't get into logs.
+func redactArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	if strings.EqualFold(args[0], string(parser.AuthCommandType)) {
+		return []string{args[0], redacted}
+	}
+	if strings.EqualFold(args[0], "HELLO") {
+		for i, arg := range args {
+			if strings.EqualFold(arg, string(parser.AuthCommandType)) {
+				return append(args[:i+1:i+1], redacted)
+			}
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,22 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactQuery(t *testing.T) {
+	assert.Equal(t, "AUTH ***", redactQuery("AUTH admin secret"))
+	assert.Equal(t, "auth ***", redactQuery(`  auth admin "secret"`))
+	assert.Equal(t, "GET auth", redactQuery("GET auth"))
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{"AUTH", "admin", "secret"}
+	assert.Equal(t, []string{"AUTH", "***"}, redactArgs(args))
+	assert.Equal(t, []string{"AUTH", "admin", "secret"}, args, "args are not modified")
+
+	assert.Equal(t, []string{"HELLO", "3", "auth", "***"}, redactArgs([]string{"HELLO", "3", "auth", "admin", "secret"}))
+	assert.Equal(t, []string{"SET", "auth", "value"}, redactArgs([]string{"SET", "auth", "value"}))
+}
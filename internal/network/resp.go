@@ -0,0 +1,351 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/db"
+	"github.com/MitrickX/simple-kv/internal/network/resp"
+	"go.uber.org/zap"
+)
+
+// Name and version of server which are reported to clients, e.g. in HELLO and INFO.
+const (
+	ServerName    = "simple-kv"
+	ServerVersion = "0.1.0"
+)
+
+// RespServer accepts clients speaking Redis serialization protocol (RESP2 and RESP3),
+// so standard Redis clients and tools can work with the database.
+type RespServer struct {
+	config      *config.Config
+	db          *db.DB
+	logger      *zap.Logger
+	connLimiter chan struct{}
+	conns       connections
+	options     serverOptions
+}
+
+func NewRespServer(
+	config *config.Config,
+	db *db.DB,
+	logger *zap.Logger,
+	opts ...ServerOption,
+) *RespServer {
+	return &RespServer{
+		config:      config,
+		db:          db,
+		logger:      logger,
+		connLimiter: make(chan struct{}, config.Network.MaxConnections),
+		options:     newServerOptions(opts),
+	}
+}
+
+func (s *RespServer) Start(ctx context.Context) error {
+	addr := s.config.Network.RespAddress
+	lc := net.ListenConfig{}
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		s.logger.Error("failed to listen", zap.String("address", addr), zap.Error(err))
+		return err
+	}
+	ln = s.options.listener(ln)
+
+	stop := context.AfterFunc(ctx, func() {
+		ln.Close()
+	})
+	defer stop()
+
+	s.logger.Info("resp server listening",
+		zap.String("address", ln.Addr().String()),
+		zap.Int64("idleTimeout", int64(s.config.Network.IdleTimeout)),
+		zap.Int64("maxConnections", int64(s.config.Network.MaxConnections)),
+		zap.Int("maxMessageSize", int(s.config.Network.MaxMessageSize)),
+		zap.Bool("tls", s.options.certs != nil),
+	)
+
+	s.accept(ctx, ln)
+	ln.Close()
+
+	s.logger.Info("resp server is shutting down", zap.Int("connCount", len(s.connLimiter)))
+	if !s.conns.shutdown(time.Duration(s.config.Network.ShutdownTimeout)) {
+		s.logger.Warn("resp server closed connections with unfinished commands cause of shutdown timeout")
+	}
+	s.logger.Info("resp server stopped")
+
+	return nil
+}
+
+// Clients returns number of connected clients.
+func (s *RespServer) Clients() int {
+	return s.conns.count()
+}
+
+func (s *RespServer) accept(ctx context.Context, ln net.Listener) {
+	var delay time.Duration
+	for {
+		// limit number of connections using cond var
+		select {
+		case s.connLimiter <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		conn, err := ln.Accept()
+		if err != nil {
+			<-s.connLimiter
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Error("failed to accept connection", zap.Error(err))
+			if !waitAcceptRetry(ctx, &delay) {
+				return
+			}
+			continue
+		}
+		delay = 0
+
+		s.logger.Info("accepted resp connection", zap.String("remote", conn.RemoteAddr().String()), zap.Int("connCount", len(s.connLimiter)))
+		s.conns.add(conn)
+		s.options.metrics.connOpened(serverLabelResp)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *RespServer) handleConn(conn net.Conn) {
+	defer func() {
+		<-s.connLimiter
+		s.options.metrics.connClosed(serverLabelResp)
+		conn.Close()
+		s.conns.remove(conn)
+
+		s.logger.Info("closed resp connection", zap.String("remote", conn.RemoteAddr().String()))
+
+		if r := recover(); r != nil {
+			s.logger.Error("panic happened", zap.Any("recovery", r))
+		}
+	}()
+
+	s.conns.setReadDeadline(conn, time.Duration(s.config.Network.IdleTimeout))
+	if err := tlsHandshake(conn); err != nil {
+		s.options.metrics.connRejected(serverLabelResp)
+		s.logger.Error("connection error", zap.Error(err))
+		return
+	}
+
+	reader := resp.NewReader(conn, int(s.config.Network.MaxMessageSize))
+	writer := resp.NewWriter(conn)
+	session := s.db.NewSession()
+
+	for {
+		// move idle deadline
+		s.conns.setReadDeadline(conn, time.Duration(s.config.Network.IdleTimeout))
+
+		args, err := reader.ReadCommand()
+		if err != nil {
+			if errors.Is(err, resp.ErrProtocol) || errors.Is(err, resp.ErrMessageTooLarge) {
+				writer.WriteError("ERR " + err.Error())
+				writer.Flush()
+			}
+			// idle connections are interrupted on shutdown
+			if !errors.Is(err, io.EOF) && !s.conns.isClosing() {
+				s.logger.Error("connection error", zap.Error(err))
+			}
+			return
+		}
+
+		s.logger.Debug("input command", zap.Strings("args", redactArgs(args)))
+
+		quit := s.execute(session, writer, args)
+
+		// flush replies of pipelined commands at once
+		if reader.Buffered() == 0 || quit {
+			if err := writer.Flush(); err != nil {
+				s.logger.Error("connection error", zap.Error(err))
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// execute runs command and writes reply, it returns true if client asks to close connection.
+func (s *RespServer) execute(session *db.Session, writer *resp.Writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "HELLO", "AUTH", "QUIT":
+	default:
+		// commands answered by server itself must not bypass authentication
+		if err := session.Authenticated(); err != nil {
+			writeResponse(writer, db.ErrorResponse(err))
+			return false
+		}
+	}
+
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			writer.WriteBulkString(args[1])
+		} else {
+			writer.WriteSimpleString("PONG")
+		}
+	case "ECHO":
+		if len(args) != 2 {
+			writer.WriteError("ERR wrong number of arguments for 'echo' command")
+		} else {
+			writer.WriteBulkString(args[1])
+		}
+	case "HELLO":
+		s.hello(session, writer, args[1:])
+	case "SELECT":
+		if len(args) != 2 || args[1] != "0" {
+			writer.WriteError("ERR DB index is out of range")
+		} else {
+			writer.WriteSimpleString("OK")
+		}
+	case "CLIENT":
+		// clients send their names and library versions, there is nothing to store them in yet
+		writer.WriteSimpleString("OK")
+	case "COMMAND":
+		writer.WriteArrayHeader(0)
+	case "QUIT":
+		writer.WriteSimpleString("OK")
+		return true
+	case "INFO":
+		// Redis clients parse INFO as text of sections
+		response := session.ExecArgs(args)
+		if response.Type == db.MapResponseType {
+			writer.WriteBulkString(formatInfo(response))
+		} else {
+			writeResponse(writer, response)
+		}
+	default:
+		response := session.ExecArgs(args)
+		s.logger.Debug("execute command", zap.Int("type", int(response.Type)), zap.Error(response.Err))
+		writeResponse(writer, response)
+	}
+
+	return false
+}
+
+// hello negotiates protocol version and authenticates: HELLO [protover [AUTH username password] [SETNAME clientname]].
+func (s *RespServer) hello(session *db.Session, writer *resp.Writer, args []string) {
+	version := 0
+	if len(args) > 0 {
+		var err error
+		version, err = strconv.Atoi(args[0])
+		if err != nil || (version != resp.ProtocolVersion2 && version != resp.ProtocolVersion3) {
+			writer.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+	}
+
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				writer.WriteError("ERR syntax error in HELLO option AUTH")
+				return
+			}
+			if response := session.ExecArgs([]string{"AUTH", args[i+1], args[i+2]}); response.Type == db.ErrorResponseType {
+				writeResponse(writer, response)
+				return
+			}
+			i += 2
+		case "SETNAME":
+			// client name is not stored yet
+			i++
+		default:
+			// option isn't echoed, CR or LF in it would break reply line
+			writer.WriteError("ERR syntax error in HELLO option")
+			return
+		}
+	}
+
+	if version != 0 {
+		writer.SetProtocol(version)
+	}
+
+	role := "master"
+	if s.config.Replication.ReplicaType == config.ReplicaTypeSlave {
+		role = "replica"
+	}
+
+	writer.WriteMapHeader(6)
+	writer.WriteBulkString("server")
+	writer.WriteBulkString(ServerName)
+	writer.WriteBulkString("version")
+	writer.WriteBulkString(ServerVersion)
+	writer.WriteBulkString("proto")
+	writer.WriteInteger(int64(writer.Protocol()))
+	writer.WriteBulkString("mode")
+	writer.WriteBulkString("standalone")
+	writer.WriteBulkString("role")
+	writer.WriteBulkString(role)
+	writer.WriteBulkString("modules")
+	writer.WriteArrayHeader(0)
+}
+
+// writeResponse serializes typed response of db as RESP reply.
+func writeResponse(writer *resp.Writer, response db.Response) {
+	switch response.Type {
+	case db.StatusResponseType:
+		writer.WriteSimpleString(response.Status)
+	case db.ValueResponseType:
+		writer.WriteBulkString(response.Value)
+	case db.NilResponseType:
+		writer.WriteNull()
+	case db.IntegerResponseType:
+		writer.WriteInteger(response.Integer)
+	case db.ErrorResponseType:
+		writer.WriteError(string(response.Code) + " " + response.Err.Error())
+	case db.ArrayResponseType:
+		if response.Array == nil {
+			writer.WriteNullArray()
+			return
+		}
+		writer.WriteArrayHeader(len(response.Array))
+		for _, item := range response.Array {
+			writeResponse(writer, item)
+		}
+	case db.MapResponseType:
+		writer.WriteMapHeader(len(response.Map))
+		for _, entry := range response.Map {
+			writer.WriteBulkString(entry.Key)
+			writeResponse(writer, entry.Value)
+		}
+	}
+}
+
+// formatInfo formats sections of INFO like Redis does: "# Section" header followed by "field:value" lines.
+func formatInfo(response db.Response) string {
+	var b strings.Builder
+	for i, section := range response.Map {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(section.Key[:1]) + section.Key[1:] + "\r\n")
+		for _, field := range section.Value.Map {
+			b.WriteString(field.Key + ":")
+			switch field.Value.Type {
+			case db.IntegerResponseType:
+				b.WriteString(strconv.FormatInt(field.Value.Integer, 10))
+			case db.ValueResponseType:
+				b.WriteString(field.Value.Value)
+			case db.StatusResponseType:
+				b.WriteString(field.Value.Status)
+			}
+			b.WriteString("\r\n")
+		}
+	}
+	return b.String()
+}
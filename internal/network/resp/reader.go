@@ -0,0 +1,127 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrProtocol        = errors.New("resp error: protocol error")
+	ErrMessageTooLarge = errors.New("resp error: message is too large")
+)
+
+// Reader decodes commands sent by clients: arrays of bulk strings or inline commands.
+type Reader struct {
+	r              *bufio.Reader
+	maxMessageSize int
+}
+
+func NewReader(r io.Reader, maxMessageSize int) *Reader {
+	return &Reader{
+		r:              bufio.NewReader(r),
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// ReadCommand reads next command and returns its name with arguments.
+// Empty inline lines are skipped.
+func (r *Reader) ReadCommand() ([]string, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] != '*' {
+			// inline command, e.g. typed in telnet
+			args := strings.Fields(line)
+			if len(args) == 0 {
+				continue
+			}
+			return args, nil
+		}
+
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+		}
+		if count == 0 {
+			continue
+		}
+
+		return r.readArray(count, len(line))
+	}
+}
+
+func (r *Reader) readArray(count int, size int) ([]string, error) {
+	if count > r.maxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got '%s'", ErrProtocol, line)
+		}
+
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+		}
+
+		size += len(line) + length
+		if size > r.maxMessageSize {
+			return nil, ErrMessageTooLarge
+		}
+
+		buf := make([]byte, length+2)
+		if _, err := io.ReadFull(r.r, buf); err != nil {
+			return nil, err
+		}
+		if buf[length] != '\r' || buf[length+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string is not terminated by CRLF", ErrProtocol)
+		}
+
+		args = append(args, string(buf[:length]))
+	}
+
+	return args, nil
+}
+
+// Buffered returns number of bytes already received but not yet read, it's not zero if client pipelines commands.
+func (r *Reader) Buffered() int {
+	return r.r.Buffered()
+}
+
+// readLine reads line terminated by CRLF (or LF for inline commands) without terminator.
+func (r *Reader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+
+		line = append(line, chunk...)
+		if len(line) > r.maxMessageSize {
+			return "", ErrMessageTooLarge
+		}
+
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader_ReadCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxSize  int
+		wantArgs [][]string
+		wantErr  error
+	}{
+		{
+			name:     "array of bulk strings",
+			input:    "*3\r\n$3\r\nSET\r\n$5\r\nhello\r\n$11\r\nhello world\r\n",
+			wantArgs: [][]string{{"SET", "hello", "hello world"}},
+		},
+		{
+			name:     "binary safe bulk string",
+			input:    "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$4\r\na\r\nb\r\n",
+			wantArgs: [][]string{{"SET", "k", "a\r\nb"}},
+		},
+		{
+			name:     "pipelined commands",
+			input:    "*2\r\n$3\r\nGET\r\n$1\r\na\r\n*2\r\n$3\r\nGET\r\n$1\r\nb\r\n",
+			wantArgs: [][]string{{"GET", "a"}, {"GET", "b"}},
+		},
+		{
+			name:     "inline command",
+			input:    "\r\nGET  key\r\nPING\n",
+			wantArgs: [][]string{{"GET", "key"}, {"PING"}},
+		},
+		{
+			name:    "invalid multibulk length",
+			input:   "*x\r\n",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "missing bulk string prefix",
+			input:   "*1\r\n+GET\r\n",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "bulk string without CRLF",
+			input:   "*1\r\n$3\r\nGETxx",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "too large message",
+			input:   "*2\r\n$3\r\nGET\r\n$100\r\n",
+			maxSize: 64,
+			wantErr: ErrMessageTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = 4096
+			}
+			r := NewReader(strings.NewReader(tt.input), maxSize)
+
+			var gotArgs [][]string
+			for {
+				args, err := r.ReadCommand()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("ReadCommand() error = %v, wantErr %v", err, tt.wantErr)
+					}
+					return
+				}
+				gotArgs = append(gotArgs, args)
+			}
+
+			if tt.wantErr != nil {
+				t.Fatalf("ReadCommand() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("ReadCommand() = %q, want %q", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol int
+		write    func(w *Writer)
+		want     string
+	}{
+		{
+			name:  "simple string",
+			write: func(w *Writer) { w.WriteSimpleString("OK") },
+			want:  "+OK\r\n",
+		},
+		{
+			name:  "error",
+			write: func(w *Writer) { w.WriteError("ERR unknown command") },
+			want:  "-ERR unknown command\r\n",
+		},
+		{
+			name:  "integer",
+			write: func(w *Writer) { w.WriteInteger(-2) },
+			want:  ":-2\r\n",
+		},
+		{
+			name:  "bulk string",
+			write: func(w *Writer) { w.WriteBulkString("a\r\nb") },
+			want:  "$4\r\na\r\nb\r\n",
+		},
+		{
+			name:     "null RESP2",
+			protocol: ProtocolVersion2,
+			write:    func(w *Writer) { w.WriteNull() },
+			want:     "$-1\r\n",
+		},
+		{
+			name:     "null RESP3",
+			protocol: ProtocolVersion3,
+			write:    func(w *Writer) { w.WriteNull() },
+			want:     "_\r\n",
+		},
+		{
+			name:     "null array RESP2",
+			protocol: ProtocolVersion2,
+			write:    func(w *Writer) { w.WriteNullArray() },
+			want:     "*-1\r\n",
+		},
+		{
+			name:     "map RESP2",
+			protocol: ProtocolVersion2,
+			write: func(w *Writer) {
+				w.WriteMapHeader(1)
+				w.WriteBulkString("proto")
+				w.WriteInteger(2)
+			},
+			want: "*2\r\n$5\r\nproto\r\n:2\r\n",
+		},
+		{
+			name:     "map RESP3",
+			protocol: ProtocolVersion3,
+			write: func(w *Writer) {
+				w.WriteMapHeader(1)
+				w.WriteBulkString("proto")
+				w.WriteInteger(3)
+			},
+			want: "%1\r\n$5\r\nproto\r\n:3\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			if tt.protocol != 0 {
+				w.SetProtocol(tt.protocol)
+			}
+
+			tt.write(w)
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+const (
+	ProtocolVersion2 = 2
+	ProtocolVersion3 = 3
+)
+
+// Writer encodes replies. Replies are buffered until Flush is called.
+type Writer struct {
+	w        *bufio.Writer
+	protocol int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:        bufio.NewWriter(w),
+		protocol: ProtocolVersion2,
+	}
+}
+
+// SetProtocol switches between RESP2 and RESP3, client selects version with HELLO command.
+func (w *Writer) SetProtocol(version int) {
+	w.protocol = version
+}
+
+func (w *Writer) Protocol() int {
+	return w.protocol
+}
+
+func (w *Writer) WriteSimpleString(s string) error {
+	return w.writeLine('+', s)
+}
+
+func (w *Writer) WriteError(s string) error {
+	return w.writeLine('-', s)
+}
+
+func (w *Writer) WriteInteger(n int64) error {
+	return w.writeLine(':', strconv.FormatInt(n, 10))
+}
+
+func (w *Writer) WriteBulkString(s string) error {
+	if err := w.writeLine('$', strconv.Itoa(len(s))); err != nil {
+		return err
+	}
+	if _, err := w.w.WriteString(s); err != nil {
+		return err
+	}
+	_, err := w.w.WriteString("\r\n")
+	return err
+}
+
+func (w *Writer) WriteNull() error {
+	if w.protocol == ProtocolVersion3 {
+		_, err := w.w.WriteString("_\r\n")
+		return err
+	}
+	_, err := w.w.WriteString("$-1\r\n")
+	return err
+}
+
+// WriteNullArray writes null reply in place of array, e.g. for aborted transaction.
+func (w *Writer) WriteNullArray() error {
+	if w.protocol == ProtocolVersion3 {
+		_, err := w.w.WriteString("_\r\n")
+		return err
+	}
+	_, err := w.w.WriteString("*-1\r\n")
+	return err
+}
+
+func (w *Writer) WriteArrayHeader(n int) error {
+	return w.writeLine('*', strconv.Itoa(n))
+}
+
+// WriteMapHeader starts map of n key-value pairs, in RESP2 map is sent as flat array.
+func (w *Writer) WriteMapHeader(n int) error {
+	if w.protocol == ProtocolVersion3 {
+		return w.writeLine('%', strconv.Itoa(n))
+	}
+	return w.writeLine('*', strconv.Itoa(2*n))
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) writeLine(prefix byte, s string) error {
+	if err := w.w.WriteByte(prefix); err != nil {
+		return err
+	}
+	if _, err := w.w.WriteString(s); err != nil {
+		return err
+	}
+	_, err := w.w.WriteString("\r\n")
+	return err
+}
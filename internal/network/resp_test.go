@@ -0,0 +1,145 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/db"
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/network/resp"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"go.uber.org/zap"
+)
+
+func TestWriteResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response db.Response
+		want     string
+	}{
+		{name: "status", response: db.StatusResponse(db.StatusQueued), want: "+QUEUED\r\n"},
+		{name: "value", response: db.ValueResponse("none"), want: "$4\r\nnone\r\n"},
+		{name: "nil", response: db.NilResponse(), want: "$-1\r\n"},
+		{name: "integer", response: db.IntegerResponse(1), want: ":1\r\n"},
+		{
+			name:     "error with code",
+			response: db.ErrorResponse(engine.ErrOutOfMemory),
+			want:     "-OOM " + engine.ErrOutOfMemory.Error() + "\r\n",
+		},
+		{
+			name:     "generic error",
+			response: db.ErrorResponse(errors.New("failed")),
+			want:     "-ERR failed\r\n",
+		},
+		{name: "nil array", response: db.NilArrayResponse(), want: "*-1\r\n"},
+		{
+			name:     "array",
+			response: db.ArrayResponse(db.OKResponse(), db.NilResponse()),
+			want:     "*2\r\n+OK\r\n$-1\r\n",
+		},
+		{
+			name:     "map in RESP2",
+			response: db.MapResponse(db.MapEntry{Key: "keys", Value: db.IntegerResponse(3)}),
+			want:     "*2\r\n$4\r\nkeys\r\n:3\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := resp.NewWriter(&buf)
+			writeResponse(writer, tt.response)
+			writer.Flush()
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatInfo(t *testing.T) {
+	response := db.MapResponse(
+		db.MapEntry{Key: "server", Value: db.MapResponse(
+			db.MapEntry{Key: "version", Value: db.ValueResponse("0.1.0")},
+			db.MapEntry{Key: "uptime_in_seconds", Value: db.IntegerResponse(5)},
+		)},
+		db.MapEntry{Key: "keyspace", Value: db.MapResponse(
+			db.MapEntry{Key: "keys", Value: db.IntegerResponse(3)},
+		)},
+	)
+
+	want := "# Server\r\nversion:0.1.0\r\nuptime_in_seconds:5\r\n\r\n# Keyspace\r\nkeys:3\r\n"
+	if got := formatInfo(response); got != want {
+		t.Errorf("formatInfo() = %q, want %q", got, want)
+	}
+}
+
+func TestRespServer_ExecuteRequiresAuth(t *testing.T) {
+	hash, err := auth.HashPassword("secret", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl, err := auth.NewACL(config.ConfigAuth{
+		Users: []config.ConfigUser{
+			{Name: "admin", Password: hash, Commands: []string{auth.AllowAll}, Keys: []string{auth.AllowAll}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := db.NewDB(
+		interpreter.NewInterpreter(parser.NewParser()),
+		storage.NewStorage(engine.NewEngine()),
+		db.WithACL(acl),
+	)
+	server := NewRespServer(&config.Config{}, database, zap.NewNop())
+	session := database.NewSession()
+
+	noAuth := "-NOAUTH " + db.ErrAuthRequired.Error() + "\r\n"
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"PING"}, want: noAuth},
+		{args: []string{"ECHO", "hello"}, want: noAuth},
+		{args: []string{"SELECT", "0"}, want: noAuth},
+		{args: []string{"CLIENT", "SETNAME", "test"}, want: noAuth},
+		{args: []string{"COMMAND"}, want: noAuth},
+		{args: []string{"GET", "key"}, want: noAuth},
+		{args: []string{"AUTH", "admin", "secret"}, want: "+OK\r\n"},
+		{args: []string{"PING"}, want: "+PONG\r\n"},
+		{args: []string{"ECHO", "hello"}, want: "$5\r\nhello\r\n"},
+	}
+
+	for _, step := range steps {
+		var buf bytes.Buffer
+		writer := resp.NewWriter(&buf)
+		server.execute(session, writer, step.args)
+		writer.Flush()
+
+		if got := buf.String(); got != step.want {
+			t.Errorf("execute(%q) = %q, want %q", step.args, got, step.want)
+		}
+	}
+}
+
+func TestRespServer_HelloDoesNotEchoOption(t *testing.T) {
+	database := db.NewDB(interpreter.NewInterpreter(parser.NewParser()), storage.NewStorage(engine.NewEngine()))
+	server := NewRespServer(&config.Config{}, database, zap.NewNop())
+
+	var buf bytes.Buffer
+	writer := resp.NewWriter(&buf)
+	server.execute(database.NewSession(), writer, []string{"HELLO", "3", "x\r\n+OK"})
+	writer.Flush()
+
+	if want := "-ERR syntax error in HELLO option\r\n"; buf.String() != want {
+		t.Errorf("execute(HELLO) = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,121 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// used if shutdown timeout is not configured
+	defaultShutdownTimeout = 10 * time.Second
+	// accept errors are retried with growing delay, so temporary errors like too many open files don't spin loop
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// connections tracks open connections of server to drain them on shutdown.
+type connections struct {
+	mx      sync.Mutex
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+	closing atomic.Bool
+}
+
+func (c *connections) add(conn net.Conn) {
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	if c.conns == nil {
+		c.conns = make(map[net.Conn]struct{})
+	}
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+}
+
+func (c *connections) remove(conn net.Conn) {
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	if _, ok := c.conns[conn]; ok {
+		delete(c.conns, conn)
+		c.wg.Done()
+	}
+}
+
+// count returns number of open connections.
+func (c *connections) count() int {
+	defer c.mx.Unlock()
+	c.mx.Lock()
+
+	return len(c.conns)
+}
+
+// isClosing reports whether server is shutting down, connections must be closed as soon as they are idle.
+func (c *connections) isClosing() bool {
+	return c.closing.Load()
+}
+
+// setReadDeadline moves idle deadline of connection. If server is shutting down, deadline is already expired,
+// so idle connection stops waiting for next query, but query already received is executed.
+func (c *connections) setReadDeadline(conn net.Conn, idleTimeout time.Duration) {
+	conn.SetReadDeadline(time.Now().Add(idleTimeout))
+	// flag is checked after deadline is set, so it can't override deadline set by shutdown
+	if c.closing.Load() {
+		conn.SetReadDeadline(time.Now())
+	}
+}
+
+// shutdown interrupts idle connections and waits until all connections are closed.
+// Connections which are still open after timeout are closed forcibly, false is returned then.
+func (c *connections) shutdown(timeout time.Duration) bool {
+	c.closing.Store(true)
+
+	c.mx.Lock()
+	for conn := range c.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	c.mx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+	}
+
+	c.mx.Lock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.mx.Unlock()
+
+	<-done
+	return false
+}
+
+// waitAcceptRetry sleeps before next accept after error, it returns false if context is done.
+func waitAcceptRetry(ctx context.Context, delay *time.Duration) bool {
+	*delay = min(max(*delay*2, minAcceptDelay), maxAcceptDelay)
+
+	timer := time.NewTimer(*delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
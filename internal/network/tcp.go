@@ -3,22 +3,29 @@ package network
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/MitrickX/simple-kv/internal/config"
 	"github.com/MitrickX/simple-kv/internal/db"
+	"github.com/MitrickX/simple-kv/protocol"
 	"go.uber.org/zap"
 )
 
 const (
-	MessageHello = "HELLO"
-	MessageHi    = "HI"
-	MessageBye   = "BYE"
+	MessageHello = protocol.MessageHello
+	MessageHi    = protocol.MessageHi
+	MessageBye   = protocol.MessageBye
 
 	startBufSize = 4096
+	// number of requests read ahead of execution in framing protocol
+	pipelineDepth = 128
 )
 
 type TcpServer struct {
@@ -26,21 +33,58 @@ type TcpServer struct {
 	db          *db.DB
 	logger      *zap.Logger
 	connLimiter chan struct{}
+	conns       connections
+	options     serverOptions
+}
+
+// ServerOption configures TcpServer and RespServer.
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	certs *Certificates
+	// nil if metrics are disabled
+	metrics *serverMetrics
+}
+
+// WithTLS makes server accept only TLS connections, certificates may be reloaded while server is running.
+func WithTLS(certs *Certificates) ServerOption {
+	return func(o *serverOptions) {
+		o.certs = certs
+	}
+}
+
+func newServerOptions(opts []ServerOption) serverOptions {
+	var o serverOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// listener wraps listener by TLS if it is enabled.
+func (o serverOptions) listener(ln net.Listener) net.Listener {
+	if o.certs == nil {
+		return ln
+	}
+	return tls.NewListener(ln, o.certs.TLSConfig())
 }
 
 func NewTcpServer(
 	config *config.Config,
 	db *db.DB,
 	logger *zap.Logger,
+	opts ...ServerOption,
 ) *TcpServer {
 	return &TcpServer{
 		config:      config,
 		db:          db,
 		logger:      logger,
 		connLimiter: make(chan struct{}, config.Network.MaxConnections),
+		options:     newServerOptions(opts),
 	}
 }
 
+// Start serves clients until context is done, then it stops accepting connections and drains open ones.
 func (s *TcpServer) Start(ctx context.Context) error {
 	addr := s.config.Network.Address
 	lc := net.ListenConfig{}
@@ -49,35 +93,89 @@ func (s *TcpServer) Start(ctx context.Context) error {
 		s.logger.Error("failed to listen", zap.String("address", addr), zap.Error(err))
 		return err
 	}
-	defer ln.Close()
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on listener until context is done or listener is closed, e.g. listener on random port in tests.
+// Idle clients get BYE, in-flight queries are finished within shutdown timeout, then connections are closed.
+func (s *TcpServer) Serve(ctx context.Context, ln net.Listener) error {
+	ln = s.options.listener(ln)
+	stop := context.AfterFunc(ctx, func() {
+		ln.Close()
+	})
+	defer stop()
 
 	s.logger.Info("tpc server listening",
 		zap.String("address", ln.Addr().String()),
 		zap.Int64("idleTimeout", int64(s.config.Network.IdleTimeout)),
 		zap.Int64("maxConnections", int64(s.config.Network.MaxConnections)),
 		zap.Int("maxMessageSize", int(s.config.Network.MaxMessageSize)),
+		zap.Bool("tls", s.options.certs != nil),
 	)
 
+	s.accept(ctx, ln)
+	ln.Close()
+
+	s.logger.Info("tcp server is shutting down", zap.Int("connCount", len(s.connLimiter)))
+	if !s.conns.shutdown(time.Duration(s.config.Network.ShutdownTimeout)) {
+		s.logger.Warn("tcp server closed connections with unfinished queries cause of shutdown timeout")
+	}
+	s.logger.Info("tcp server stopped")
+
+	return nil
+}
+
+// Clients returns number of connected clients.
+func (s *TcpServer) Clients() int {
+	return s.conns.count()
+}
+
+func (s *TcpServer) accept(ctx context.Context, ln net.Listener) {
+	var delay time.Duration
 	for {
 		// limit number of connections using cond var
-		s.connLimiter <- struct{}{}
+		select {
+		case s.connLimiter <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
 		conn, err := ln.Accept()
 		if err != nil {
 			<-s.connLimiter
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
 			s.logger.Error("failed to accept connection", zap.Error(err))
+			if !waitAcceptRetry(ctx, &delay) {
+				return
+			}
 			continue
 		}
+		delay = 0
 
 		s.logger.Info("accepted connection", zap.String("remote", conn.RemoteAddr().String()), zap.Int("connCount", len(s.connLimiter)))
+		s.conns.add(conn)
+		s.options.metrics.connOpened(serverLabelTcp)
 		go s.handleConn(conn)
 	}
 }
 
 func (s *TcpServer) handleConn(conn net.Conn) {
+	version := 0
 	defer func() {
 		<-s.connLimiter
-		conn.Write([]byte(MessageBye))
+		s.options.metrics.connClosed(serverLabelTcp)
+		if version == protocol.Version {
+			writer := protocol.NewWriter(conn)
+			writer.WriteResponse(0, frameResponse(db.StatusResponse(MessageBye)))
+			writer.Flush()
+		} else {
+			conn.Write([]byte(MessageBye))
+		}
 		conn.Close()
+		s.conns.remove(conn)
 
 		s.logger.Info("closed connection", zap.String("remote", conn.RemoteAddr().String()))
 
@@ -86,73 +184,160 @@ func (s *TcpServer) handleConn(conn net.Conn) {
 		}
 	}()
 
-	s.handshake(conn)
+	version, err := s.handshake(conn)
+	if err != nil {
+		s.options.metrics.connRejected(serverLabelTcp)
+		s.logger.Error("connection error cause of fail handshake", zap.Error(err))
+		return
+	}
+
+	// transaction state lives as long as connection
+	session := s.db.NewSession()
 
-	// set idle deadline
-	conn.SetReadDeadline(time.Now().Add(time.Duration(s.config.Network.IdleTimeout)))
+	if version == protocol.Version {
+		s.serveFrames(conn, session)
+	} else {
+		s.serveLines(conn, session)
+	}
+}
 
+// serveLines serves queries separated by line breaks, responses are formatted as text.
+func (s *TcpServer) serveLines(conn net.Conn, session *db.Session) {
 	// init scanner with token size limit
 	scanner := bufio.NewScanner(conn)
 	bufSize := min(int(s.config.Network.MaxMessageSize), startBufSize)
 	scanner.Buffer(make([]byte, bufSize), int(s.config.Network.MaxMessageSize))
 
 	for {
-		for scanner.Scan() {
-			// move idle deadline
-			conn.SetReadDeadline(time.Now().Add(time.Duration(s.config.Network.IdleTimeout)))
+		// move idle deadline
+		s.conns.setReadDeadline(conn, time.Duration(s.config.Network.IdleTimeout))
+		if !scanner.Scan() {
+			break
+		}
 
-			query := scanner.Text()
+		query := scanner.Text()
 
-			s.logger.Debug("input query", zap.String("query", query))
+		s.logger.Debug("input query", zap.String("query", redactQuery(query)))
 
-			result, err := s.db.Exec(query)
+		response := session.Exec(query)
+		result := FormatText(response)
 
-			s.logger.Debug("execute query", zap.String("result", result), zap.Error(err))
+		s.logger.Debug("execute query", zap.String("result", result), zap.Error(response.Err))
 
-			if err != nil {
-				fmt.Fprintf(conn, "%s\n", err.Error())
-				continue
-			}
+		fmt.Fprintf(conn, "%s\n", result)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			fmt.Fprintf(conn, "%s\n", FormatText(db.ErrorResponse(err)))
+		}
+		// idle connections are interrupted on shutdown
+		if !s.conns.isClosing() {
+			s.logger.Error("connection error", zap.Error(err))
+		}
+	}
+}
+
+// serveFrames serves length-prefixed binary frames, so keys and values may contain any bytes.
+// Requests are read ahead while previous ones are executed, so client may pipeline them without waiting for responses.
+// Responses are tagged with request IDs, client must match them by ID.
+func (s *TcpServer) serveFrames(conn net.Conn, session *db.Session) {
+	writer := protocol.NewWriter(conn)
+
+	requests := make(chan pipelinedRequest, pipelineDepth)
+	done := make(chan struct{})
+	defer close(done)
+	go s.readFrames(conn, requests, done)
 
-			fmt.Fprintf(conn, "%s\n", result)
+	for req := range requests {
+		var response db.Response
+		if req.err != nil {
+			response = db.ErrorResponse(req.err)
+		} else if args, err := req.Command(); err != nil {
+			response = db.ErrorResponse(err)
+		} else {
+			s.logger.Debug("input request", zap.Uint64("id", req.ID), zap.Strings("args", redactArgs(args)))
+			response = session.ExecArgs(args)
 		}
 
-		err := scanner.Err()
-		if err != nil {
-			if errors.Is(err, bufio.ErrTooLong) {
-				fmt.Fprintf(conn, "error: %s\n", err.Error())
-			}
+		if err := writer.WriteResponse(req.ID, frameResponse(response)); err != nil {
 			s.logger.Error("connection error", zap.Error(err))
+			return
 		}
 
-		break
+		// flush responses of pipelined requests at once
+		if len(requests) == 0 {
+			if err := writer.Flush(); err != nil {
+				s.logger.Error("connection error", zap.Error(err))
+				return
+			}
+		}
 	}
+}
 
+type pipelinedRequest struct {
+	protocol.Request
+	// err is set if request can't be executed but connection may be used further, e.g. request is too large
+	err error
 }
 
-func (s *TcpServer) handshake(conn net.Conn) bool {
-	var buf = make([]byte, 8)
+// readFrames reads requests until connection is closed or broken, then closes requests channel.
+func (s *TcpServer) readFrames(conn net.Conn, requests chan<- pipelinedRequest, done <-chan struct{}) {
+	defer close(requests)
+
+	reader := protocol.NewReader(conn, int(s.config.Network.MaxMessageSize))
+	for {
+		// move idle deadline
+		s.conns.setReadDeadline(conn, time.Duration(s.config.Network.IdleTimeout))
+
+		req, err := reader.ReadRequest()
+		if err != nil && !errors.Is(err, protocol.ErrMessageTooLarge) {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !s.conns.isClosing() {
+				s.logger.Error("connection error", zap.Error(err))
+			}
+			return
+		}
+
+		select {
+		case requests <- pipelinedRequest{Request: req, err: err}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// handshake expects HELLO with optional version of framing protocol and replies HI with accepted version.
+// It returns accepted version of framing protocol, 0 means line protocol.
+func (s *TcpServer) handshake(conn net.Conn) (int, error) {
+	s.conns.setReadDeadline(conn, time.Duration(s.config.Network.IdleTimeout))
+
+	if err := tlsHandshake(conn); err != nil {
+		return 0, err
+	}
+
+	var buf = make([]byte, 16)
 	n, err := conn.Read(buf)
 	if err != nil {
-		s.logger.Error("connection error cause of fail handshake, can't read hello message", zap.Error(err))
-		return false
+		return 0, fmt.Errorf("can't read hello message: %w", err)
 	}
 
-	if string(buf[0:n]) != MessageHello {
-		s.logger.Error("connection error cause of fail handshake, expect HELLO", zap.Binary("buf", buf))
-		return false
+	hello := strings.Fields(string(buf[0:n]))
+	if len(hello) == 0 || len(hello) > 2 || hello[0] != MessageHello {
+		return 0, fmt.Errorf("expect HELLO, got %q", buf[0:n])
 	}
 
-	n, err = conn.Write([]byte(MessageHi))
-	if err != nil {
-		s.logger.Error("connection error cause of fail handshake, can't send hi message", zap.Error(err))
-		return false
+	version := 0
+	hi := MessageHi
+	// unsupported version is not confirmed, client falls back to line protocol then
+	if len(hello) == 2 && hello[1] == strconv.Itoa(protocol.Version) {
+		version = protocol.Version
+		hi = MessageHi + " " + hello[1]
 	}
 
-	if n != 2 {
-		s.logger.Error("connection error cause of fail handshake, can't send fully hi message", zap.Int("n", n))
-		return false
+	if _, err := conn.Write([]byte(hi)); err != nil {
+		return 0, fmt.Errorf("can't send hi message: %w", err)
 	}
 
-	return true
+	return version, nil
 }
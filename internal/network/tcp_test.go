@@ -0,0 +1,145 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/db"
+	"github.com/MitrickX/simple-kv/internal/interpreter"
+	"github.com/MitrickX/simple-kv/internal/interpreter/parser"
+	"github.com/MitrickX/simple-kv/internal/metrics"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/MitrickX/simple-kv/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func startTcpServer(t *testing.T, ctx context.Context, opts ...ServerOption) (string, <-chan error) {
+	t.Helper()
+
+	cfg := config.Default()
+	st := storage.NewStorage(engine.NewEngine())
+	server := NewTcpServer(&cfg, db.NewDB(interpreter.NewInterpreter(parser.NewParser()), st), zap.NewNop(), opts...)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, ln)
+	}()
+
+	return ln.Addr().String(), done
+}
+
+func dialTcpServer(t *testing.T, address string, hello string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = conn.Write([]byte(hello))
+	require.NoError(t, err)
+
+	buf := make([]byte, 16)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+
+	return conn
+}
+
+func TestTcpServer_Shutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	address, done := startTcpServer(t, ctx)
+
+	lineConn := dialTcpServer(t, address, MessageHello)
+	frameConn := dialTcpServer(t, address, MessageHello+" "+strconv.Itoa(protocol.Version))
+
+	// connection is usable before shutdown
+	_, err := lineConn.Write([]byte("SET key value\n"))
+	require.NoError(t, err)
+	lineReader := bufio.NewReader(lineConn)
+	line, err := lineReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "OK\n", line)
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server hasn't stopped")
+	}
+
+	// idle clients get BYE in their protocol
+	rest, err := lineReader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, MessageBye, rest)
+
+	id, response, err := protocol.NewReader(frameConn, 0).ReadResponse()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), id)
+	assert.Equal(t, protocol.Response{Type: protocol.StatusResponse, Status: MessageBye}, response)
+
+	// listener is closed
+	_, err = net.Dial("tcp", address)
+	assert.Error(t, err)
+}
+
+func TestConnections_ShutdownTimeout(t *testing.T) {
+	var conns connections
+
+	server, client := net.Pipe()
+	defer client.Close()
+	conns.add(server)
+
+	// response is never read by client, so connection is busy until it is closed
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := server.Write([]byte("OK\n"))
+		conns.remove(server)
+		writeErr <- err
+	}()
+
+	assert.False(t, conns.shutdown(10*time.Millisecond))
+	assert.ErrorIs(t, <-writeErr, io.ErrClosedPipe)
+	assert.True(t, conns.isClosing())
+}
+
+func TestTcpServer_Metrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := metrics.NewRegistry()
+	address, _ := startTcpServer(t, ctx, WithMetrics(registry))
+
+	dialTcpServer(t, address, MessageHello)
+
+	// connection without HELLO is closed by server
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET key\n"))
+	require.NoError(t, err)
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err)
+
+	var b strings.Builder
+	_, err = registry.WriteTo(&b)
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), "\nsimplekv_active_connections{server=\"tcp\"} 1\n")
+	assert.Contains(t, b.String(), "\nsimplekv_rejected_connections_total{server=\"tcp\"} 1\n")
+}
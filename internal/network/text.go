@@ -0,0 +1,65 @@
+package network
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MitrickX/simple-kv/internal/db"
+)
+
+// FormatText serializes response for line protocol, every response type has its own form:
+//
+//	OK                  status
+//	"value"             value, quoted with Go escapes, so it never contains line breaks
+//	(nil)               no value
+//	(integer) 1         integer
+//	(error) ERR message error with code
+//	(array) 2           array header followed by numbered items, one per line
+//	(empty array)       array without items
+//	(map) 2             map header followed by "key: value" items, one per line
+//	(empty map)         map without items
+func FormatText(response db.Response) string {
+	var b strings.Builder
+	writeText(&b, response, "")
+	return b.String()
+}
+
+func writeText(b *strings.Builder, response db.Response, indent string) {
+	switch response.Type {
+	case db.StatusResponseType:
+		b.WriteString(response.Status)
+	case db.ValueResponseType:
+		b.WriteString(strconv.Quote(response.Value))
+	case db.NilResponseType:
+		b.WriteString("(nil)")
+	case db.IntegerResponseType:
+		fmt.Fprintf(b, "(integer) %d", response.Integer)
+	case db.ErrorResponseType:
+		fmt.Fprintf(b, "(error) %s %s", response.Code, response.Err.Error())
+	case db.ArrayResponseType:
+		if response.Array == nil {
+			b.WriteString("(nil)")
+			return
+		}
+		if len(response.Array) == 0 {
+			b.WriteString("(empty array)")
+			return
+		}
+		fmt.Fprintf(b, "(array) %d", len(response.Array))
+		for i, item := range response.Array {
+			fmt.Fprintf(b, "\n%s%d) ", indent, i+1)
+			writeText(b, item, indent+"   ")
+		}
+	case db.MapResponseType:
+		if len(response.Map) == 0 {
+			b.WriteString("(empty map)")
+			return
+		}
+		fmt.Fprintf(b, "(map) %d", len(response.Map))
+		for _, entry := range response.Map {
+			fmt.Fprintf(b, "\n%s%s: ", indent, entry.Key)
+			writeText(b, entry.Value, indent+"   ")
+		}
+	}
+}
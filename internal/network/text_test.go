@@ -0,0 +1,58 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MitrickX/simple-kv/internal/db"
+)
+
+func TestFormatText(t *testing.T) {
+	tests := []struct {
+		name     string
+		response db.Response
+		want     string
+	}{
+		{name: "status", response: db.OKResponse(), want: "OK"},
+		{name: "value", response: db.ValueResponse("none"), want: `"none"`},
+		{name: "value with line break", response: db.ValueResponse("a\nb\""), want: `"a\nb\""`},
+		{name: "nil", response: db.NilResponse(), want: "(nil)"},
+		{name: "integer", response: db.IntegerResponse(-2), want: "(integer) -2"},
+		{
+			name:     "error",
+			response: db.ErrorResponse(errors.New("something failed")),
+			want:     "(error) ERR something failed",
+		},
+		{name: "empty array", response: db.ArrayResponse(), want: "(empty array)"},
+		{name: "nil array", response: db.NilArrayResponse(), want: "(nil)"},
+		{
+			name: "array",
+			response: db.ArrayResponse(
+				db.OKResponse(),
+				db.ArrayResponse(db.ValueResponse("v"), db.NilResponse()),
+				db.IntegerResponse(1),
+			),
+			want: "(array) 3\n1) OK\n2) (array) 2\n   1) \"v\"\n   2) (nil)\n3) (integer) 1",
+		},
+		{name: "empty map", response: db.MapResponse(), want: "(empty map)"},
+		{
+			name: "map",
+			response: db.MapResponse(
+				db.MapEntry{Key: "server", Value: db.MapResponse(
+					db.MapEntry{Key: "version", Value: db.ValueResponse("0.1.0")},
+					db.MapEntry{Key: "uptime", Value: db.IntegerResponse(5)},
+				)},
+				db.MapEntry{Key: "keys", Value: db.IntegerResponse(3)},
+			),
+			want: "(map) 2\nserver: (map) 2\n   version: \"0.1.0\"\n   uptime: (integer) 5\nkeys: (integer) 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatText(tt.response); got != tt.want {
+				t.Errorf("FormatText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
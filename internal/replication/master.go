@@ -0,0 +1,234 @@
+package replication
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+	"go.uber.org/zap"
+)
+
+// Master serves write-ahead log records to slaves.
+type Master struct {
+	config *config.Config
+	wal    *wal.WAL
+	logger *zap.Logger
+	// nil if authentication is disabled
+	acl *auth.ACL
+	// nil if slaves connect by plain text
+	tlsConfig *tls.Config
+
+	// LSN which every connected slave applied log up to, it is known by the last sync request of slave
+	appliedMx sync.Mutex
+	applied   map[net.Conn]uint64
+}
+
+// MasterOption configures Master.
+type MasterOption func(*Master)
+
+// WithACL makes slaves authenticate by user which may access all keys, log contains every one of them.
+func WithACL(acl *auth.ACL) MasterOption {
+	return func(m *Master) {
+		m.acl = acl
+	}
+}
+
+// WithTLS makes slaves connect by TLS, config may pick certificates for every connection to reload them.
+func WithTLS(config *tls.Config) MasterOption {
+	return func(m *Master) {
+		m.tlsConfig = config
+	}
+}
+
+func NewMaster(
+	config *config.Config,
+	wal *wal.WAL,
+	logger *zap.Logger,
+	opts ...MasterOption,
+) *Master {
+	m := &Master{
+		config:  config,
+		wal:     wal,
+		logger:  logger,
+		applied: make(map[net.Conn]uint64),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// AppliedLSN returns the least LSN which connected slaves applied log up to, false if no slave is connected.
+// Storage doesn't prune log after it, so slaves which lag behind can still catch up.
+func (m *Master) AppliedLSN() (uint64, bool) {
+	defer m.appliedMx.Unlock()
+	m.appliedMx.Lock()
+
+	var (
+		least uint64
+		found bool
+	)
+	for _, lsn := range m.applied {
+		if !found || lsn < least {
+			least, found = lsn, true
+		}
+	}
+	return least, found
+}
+
+func (m *Master) setApplied(conn net.Conn, lsn uint64) {
+	defer m.appliedMx.Unlock()
+	m.appliedMx.Lock()
+
+	m.applied[conn] = lsn
+}
+
+func (m *Master) forgetApplied(conn net.Conn) {
+	defer m.appliedMx.Unlock()
+	m.appliedMx.Lock()
+
+	delete(m.applied, conn)
+}
+
+// Start listens for slaves until context is done.
+func (m *Master) Start(ctx context.Context) error {
+	addr := m.config.Replication.MasterAddress
+	lc := net.ListenConfig{}
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		m.logger.Error("replication master failed to listen", zap.String("address", addr), zap.Error(err))
+		return err
+	}
+	if m.tlsConfig != nil {
+		ln = tls.NewListener(ln, m.tlsConfig)
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	m.logger.Info("replication master listening", zap.String("address", ln.Addr().String()))
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			m.logger.Error("replication master failed to accept connection", zap.Error(err))
+			continue
+		}
+
+		m.logger.Info("slave connected", zap.String("remote", conn.RemoteAddr().String()))
+		go m.handleConn(conn)
+	}
+}
+
+func (m *Master) handleConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		m.forgetApplied(conn)
+
+		m.logger.Info("slave disconnected", zap.String("remote", conn.RemoteAddr().String()))
+
+		if r := recover(); r != nil {
+			m.logger.Error("panic happened", zap.Any("recovery", r))
+		}
+	}()
+
+	decoder := gob.NewDecoder(conn)
+	encoder := gob.NewEncoder(conn)
+
+	conn.SetDeadline(time.Now().Add(m.idleTimeout()))
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			m.logger.Warn("slave failed tls handshake", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			return
+		}
+	}
+
+	var authReq authRequest
+	if err := decoder.Decode(&authReq); err != nil {
+		m.logger.Debug("replication master can't read auth request", zap.Error(err))
+		return
+	}
+	var authResp authResponse
+	if err := m.authenticate(authReq); err != nil {
+		m.logger.Warn("slave is not authenticated", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+		authResp.Error = err.Error()
+	}
+	if err := encoder.Encode(&authResp); err != nil || authResp.Error != "" {
+		return
+	}
+
+	for {
+		conn.SetDeadline(time.Now().Add(m.idleTimeout()))
+
+		var req syncRequest
+		if err := decoder.Decode(&req); err != nil {
+			m.logger.Debug("replication master can't read sync request", zap.Error(err))
+			return
+		}
+		// slave asks for records it hasn't applied yet
+		m.setApplied(conn, max(req.FromLSN, 1)-1)
+
+		resp := m.sync(req)
+		if err := encoder.Encode(&resp); err != nil {
+			m.logger.Error("replication master can't send sync response", zap.Error(err))
+			return
+		}
+	}
+}
+
+// authenticate checks credentials of slave if master has users.
+func (m *Master) authenticate(req authRequest) error {
+	if m.acl == nil {
+		return nil
+	}
+
+	user, err := m.acl.Authenticate(req.User, req.Password)
+	if err != nil {
+		return err
+	}
+	if !user.AllowAllKeys() {
+		return fmt.Errorf("%w: user %s has no permissions to access all keys", auth.ErrPermissionDenied, user.Name())
+	}
+	return nil
+}
+
+func (m *Master) sync(req syncRequest) syncResponse {
+	lastLSN := m.wal.LastLSN()
+	if req.FromLSN > lastLSN+1 {
+		return syncResponse{
+			Error: fmt.Sprintf("slave is ahead of master: requested LSN %d, master last LSN %d", req.FromLSN, lastLSN),
+		}
+	}
+
+	records, err := m.wal.ReadFrom(req.FromLSN, syncBatchSize)
+	if errors.Is(err, wal.ErrPruned) {
+		m.logger.Error("slave asks for pruned records, it must be seeded from master data",
+			zap.Uint64("from", req.FromLSN), zap.Error(err))
+		return syncResponse{Error: err.Error(), Pruned: true}
+	}
+	if err != nil {
+		m.logger.Error("replication master can't read wal", zap.Uint64("from", req.FromLSN), zap.Error(err))
+		return syncResponse{Error: err.Error()}
+	}
+
+	return syncResponse{Records: records, LastLSN: lastLSN}
+}
+
+// idleTimeout is how long master waits for next request of slave, it must be longer than slave sync interval.
+func (m *Master) idleTimeout() time.Duration {
+	return max(time.Duration(m.config.Network.IdleTimeout), 2*time.Duration(m.config.Replication.SyncInterval))
+}
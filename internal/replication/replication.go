@@ -0,0 +1,41 @@
+package replication
+
+import (
+	"errors"
+
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+)
+
+// max number of records master sends in one sync response
+const syncBatchSize = 1000
+
+// ErrLogPruned stops slave which needs records master has already pruned. There is no full resync,
+// so operator must seed slave from master: stop slave, replace its wal and snapshot directories
+// by copies of master ones and start it again.
+var ErrLogPruned = errors.New("replication error: master log is pruned past slave LSN, slave must be seeded from master data")
+
+// authRequest is the first message of slave on every connection, master which has users checks credentials.
+type authRequest struct {
+	User     string
+	Password string
+}
+
+// authResponse contains error message if slave isn't authenticated, then master closes connection.
+type authResponse struct {
+	Error string
+}
+
+// syncRequest is sent by slave to ask for log records starting with FromLSN.
+type syncRequest struct {
+	FromLSN uint64
+}
+
+// syncResponse contains records in LSN order or error message if master can't serve request.
+type syncResponse struct {
+	Records []wal.Record
+	Error   string
+	// Pruned is true if master has pruned records slave asked for, so slave can't catch up by log
+	Pruned bool
+	// LastLSN is LSN of the last record of master log, slave knows its lag by it
+	LastLSN uint64
+}
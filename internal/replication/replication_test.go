@@ -0,0 +1,441 @@
+package replication
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/gob"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/auth"
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/network"
+	"github.com/MitrickX/simple-kv/internal/storage"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+	"go.uber.org/zap"
+)
+
+func newTestStorage(t *testing.T, opts ...storage.Option) (storage.Storage, *wal.WAL) {
+	t.Helper()
+
+	w, err := wal.NewWAL(config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		MaxSegmentSize:       config.DataSize(256),
+		FlushingBatchTimeout: config.Timeout(time.Millisecond),
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create wal: %v", err)
+	}
+
+	st := storage.NewStorage(engine.NewEngine(), append(opts, storage.WithWAL(w))...)
+	if err := st.Recover(); err != nil {
+		t.Fatalf("failed to recover storage: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	return st, w
+}
+
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	return ln.Addr().String()
+}
+
+func TestReplication_SlaveFollowsMaster(t *testing.T) {
+	cfg := config.Default()
+	cfg.Replication.MasterAddress = freeAddress(t)
+	cfg.Replication.SyncInterval = config.Timeout(10 * time.Millisecond)
+
+	masterStorage, masterWAL := newTestStorage(t)
+	slaveStorage, _ := newTestStorage(t, storage.WithReadOnly())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	master := NewMaster(&cfg, masterWAL, zap.NewNop())
+	go master.Start(ctx)
+
+	slave := NewSlave(&cfg, slaveStorage, zap.NewNop())
+	go slave.Start(ctx)
+
+	for i := 0; i < 20; i++ {
+		if err := masterStorage.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d", i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := masterStorage.Del("key_0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for slaveStorage.LastLSN() < masterStorage.LastLSN() {
+		if time.Now().After(deadline) {
+			t.Fatalf("slave didn't catch up: slave LSN %d, master LSN %d", slaveStorage.LastLSN(), masterStorage.LastLSN())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if lag := slave.Lag(); lag != 0 {
+		t.Errorf("slave Lag() = %d after catching up, want 0", lag)
+	}
+	if slave.LastSync().IsZero() {
+		t.Errorf("slave LastSync() is zero after sync")
+	}
+
+	if _, ok := slaveStorage.Get("key_0"); ok {
+		t.Errorf("deleted key_0 exists on slave")
+	}
+	for i := 1; i < 20; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		want := fmt.Sprintf("value_%d", i)
+		if got, ok := slaveStorage.Get(key); !ok || got != want {
+			t.Errorf("slave Get(%q) = (%q, %v), want (%q, true)", key, got, ok, want)
+		}
+	}
+
+	if err := slaveStorage.Set("foo", "bar"); err != storage.ErrReadOnly {
+		t.Errorf("slave Set() error = %v, want %v", err, storage.ErrReadOnly)
+	}
+}
+
+func TestReplication_MasterTracksAppliedLSN(t *testing.T) {
+	cfg := config.Default()
+	cfg.Replication.MasterAddress = freeAddress(t)
+
+	masterStorage, masterWAL := newTestStorage(t)
+	for i := 0; i < 5; i++ {
+		if err := masterStorage.Set(fmt.Sprintf("key_%d", i), "value"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	master := NewMaster(&cfg, masterWAL, zap.NewNop())
+	go master.Start(ctx)
+
+	if _, ok := master.AppliedLSN(); ok {
+		t.Errorf("AppliedLSN() reports progress without slaves")
+	}
+
+	var conn net.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		if conn, err = net.Dial("tcp", cfg.Replication.MasterAddress); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to connect to master: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	encoder, decoder := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	// master without users accepts any slave
+	if err := encoder.Encode(&authRequest{}); err != nil {
+		t.Fatalf("failed to send auth request: %v", err)
+	}
+	var authResp authResponse
+	if err := decoder.Decode(&authResp); err != nil || authResp.Error != "" {
+		t.Fatalf("failed to authenticate: %v %s", err, authResp.Error)
+	}
+
+	// slave which asks for records from LSN 3 has applied log up to LSN 2
+	if err := encoder.Encode(&syncRequest{FromLSN: 3}); err != nil {
+		t.Fatalf("failed to send sync request: %v", err)
+	}
+	var resp syncResponse
+	if err := decoder.Decode(&resp); err != nil {
+		t.Fatalf("failed to read sync response: %v", err)
+	}
+	if lsn, ok := master.AppliedLSN(); !ok || lsn != 2 {
+		t.Errorf("AppliedLSN() = (%d, %v), want (2, true)", lsn, ok)
+	}
+
+	// disconnected slave doesn't hold log anymore
+	conn.Close()
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := master.AppliedLSN(); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("AppliedLSN() still reports progress of disconnected slave")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplication_SlaveStopsIfLogIsPruned(t *testing.T) {
+	cfg := config.Default()
+	cfg.Replication.MasterAddress = freeAddress(t)
+	cfg.Replication.SyncInterval = config.Timeout(10 * time.Millisecond)
+
+	masterStorage, masterWAL := newTestStorage(t)
+	for i := 0; i < 20; i++ {
+		if err := masterStorage.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d", i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if removed, err := masterWAL.Prune(masterWAL.LastLSN()); err != nil || removed == 0 {
+		t.Fatalf("Prune() = %d, %v, want removed segments", removed, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	master := NewMaster(&cfg, masterWAL, zap.NewNop())
+	go master.Start(ctx)
+
+	slaveStorage, _ := newTestStorage(t, storage.WithReadOnly())
+	slave := NewSlave(&cfg, slaveStorage, zap.NewNop())
+
+	done := make(chan error, 1)
+	go func() { done <- slave.Start(ctx) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrLogPruned) {
+			t.Errorf("slave Start() error = %v, want %v", err, ErrLogPruned)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slave keeps syncing with master which pruned records it needs")
+	}
+}
+
+func TestReplication_SlaveAuthentication(t *testing.T) {
+	hash, err := auth.HashPassword("secret", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl, err := auth.NewACL(config.ConfigAuth{
+		Users: []config.ConfigUser{
+			{Name: "replica", Password: hash, Keys: []string{auth.AllowAll}},
+			{Name: "reader", Password: hash, Commands: []string{auth.AllowAll}, Keys: []string{"public:*"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Replication.MasterAddress = freeAddress(t)
+
+	masterStorage, masterWAL := newTestStorage(t)
+	if err := masterStorage.Set("private:1", "value"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	master := NewMaster(&cfg, masterWAL, zap.NewNop(), WithACL(acl))
+	go master.Start(ctx)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		wantErr  bool
+	}{
+		{name: "no credentials", wantErr: true},
+		{name: "wrong password", user: "replica", password: "wrong", wantErr: true},
+		{name: "user can't access all keys", user: "reader", password: "secret", wantErr: true},
+		{name: "replication user", user: "replica", password: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slaveCfg := cfg
+			slaveCfg.Replication.User = tt.user
+			slaveCfg.Replication.Password = tt.password
+
+			slaveStorage, _ := newTestStorage(t, storage.WithReadOnly())
+			slave := NewSlave(&slaveCfg, slaveStorage, zap.NewNop())
+			defer slave.disconnect()
+
+			deadline := time.Now().Add(5 * time.Second)
+			err := slave.sync(ctx)
+			// master may not listen yet
+			for errors.Is(err, syscall.ECONNREFUSED) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				err = slave.sync(ctx)
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sync() succeeded, want master to reject slave")
+				}
+				if _, ok := slaveStorage.Get("private:1"); ok {
+					t.Errorf("rejected slave got records of master")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			if got, ok := slaveStorage.Get("private:1"); !ok || got != "value" {
+				t.Errorf("slave Get() = (%q, %v), want (%q, true)", got, ok, "value")
+			}
+		})
+	}
+}
+
+// writeTestCertificates writes self-signed CA and certificate for 127.0.0.1 issued by it to directory,
+// certificate may be used by both master and slave.
+func writeTestCertificates(t *testing.T, dir string) config.ConfigNetwork {
+	t.Helper()
+
+	write := func(name string, block *pem.Block) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return file
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "replication test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config.ConfigNetwork{
+		TLSCertFile:   write("node.crt", &pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		TLSKeyFile:    write("node.key", &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		TLSCAFile:     write("ca.crt", &pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		TLSClientAuth: true,
+	}
+}
+
+func TestReplication_MutualTLS(t *testing.T) {
+	masterCerts, err := network.NewCertificates(writeTestCertificates(t, t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to load certificates: %v", err)
+	}
+	// slave of another CA neither trusts master nor is trusted by it
+	otherCerts, err := network.NewCertificates(writeTestCertificates(t, t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to load certificates: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Replication.MasterAddress = freeAddress(t)
+
+	masterStorage, masterWAL := newTestStorage(t)
+	if err := masterStorage.Set("key", "value"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	master := NewMaster(&cfg, masterWAL, zap.NewNop(), WithTLS(masterCerts.TLSConfig()))
+	go master.Start(ctx)
+
+	tests := []struct {
+		name    string
+		opts    []SlaveOption
+		wantErr bool
+	}{
+		{name: "plain text", wantErr: true},
+		{name: "certificate of another CA", opts: []SlaveOption{WithSlaveTLS(otherCerts.ClientTLSConfig)}, wantErr: true},
+		{name: "certificate of master CA", opts: []SlaveOption{WithSlaveTLS(masterCerts.ClientTLSConfig)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slaveStorage, _ := newTestStorage(t, storage.WithReadOnly())
+			slave := NewSlave(&cfg, slaveStorage, zap.NewNop(), tt.opts...)
+			defer slave.disconnect()
+
+			deadline := time.Now().Add(5 * time.Second)
+			err := slave.sync(ctx)
+			// master may not listen yet
+			for errors.Is(err, syscall.ECONNREFUSED) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				err = slave.sync(ctx)
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sync() succeeded, want connection to be rejected")
+				}
+				if _, ok := slaveStorage.Get("key"); ok {
+					t.Errorf("rejected slave got records of master")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sync() unexpected error: %v", err)
+			}
+			if got, ok := slaveStorage.Get("key"); !ok || got != "value" {
+				t.Errorf("slave Get() = (%q, %v), want (%q, true)", got, ok, "value")
+			}
+			if state := slave.conn.(*tls.Conn).ConnectionState(); !state.HandshakeComplete {
+				t.Errorf("slave connection isn't secured by tls")
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package replication
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+	"go.uber.org/zap"
+)
+
+const (
+	dialTimeout         = time.Second
+	readWriteTimeout    = 10 * time.Second
+	defaultSyncInterval = time.Second
+)
+
+// Applier is a storage which slave fills with records pulled from master.
+type Applier interface {
+	LastLSN() uint64
+	ApplyReplicated(records []wal.Record) error
+}
+
+// Slave periodically pulls write-ahead log records it hasn't seen yet from master.
+type Slave struct {
+	config  *config.Config
+	storage Applier
+	logger  *zap.Logger
+	// returns tls config of the next connection to master, nil if slave connects by plain text
+	tlsConfig func() *tls.Config
+
+	conn    net.Conn
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+
+	// last LSN of master log and time of the last successful sync, they are read by metrics
+	masterLSN atomic.Uint64
+	lastSync  atomic.Int64
+}
+
+// SlaveOption configures Slave.
+type SlaveOption func(*Slave)
+
+// WithSlaveTLS makes slave connect to master by TLS, config is taken for every connection,
+// so reloaded certificates are used after reconnect.
+func WithSlaveTLS(config func() *tls.Config) SlaveOption {
+	return func(s *Slave) {
+		s.tlsConfig = config
+	}
+}
+
+func NewSlave(
+	config *config.Config,
+	storage Applier,
+	logger *zap.Logger,
+	opts ...SlaveOption,
+) *Slave {
+	s := &Slave{
+		config:  config,
+		storage: storage,
+		logger:  logger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Start syncs with master every sync interval until context is done.
+// It returns ErrLogPruned if master doesn't have records slave needs anymore.
+func (s *Slave) Start(ctx context.Context) error {
+	interval := time.Duration(s.config.Replication.SyncInterval)
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+
+	s.logger.Info("replication slave started",
+		zap.String("master", s.config.Replication.MasterAddress),
+		zap.Duration("syncInterval", interval),
+	)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer s.disconnect()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			if errors.Is(err, ErrLogPruned) {
+				return err
+			}
+			s.logger.Error("replication slave failed to sync", zap.Error(err))
+			s.disconnect()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync pulls records until slave catches up with master.
+func (s *Slave) sync(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+
+		s.conn.SetDeadline(time.Now().Add(readWriteTimeout))
+
+		req := syncRequest{FromLSN: s.storage.LastLSN() + 1}
+		if err := s.encoder.Encode(&req); err != nil {
+			return err
+		}
+
+		var resp syncResponse
+		if err := s.decoder.Decode(&resp); err != nil {
+			return err
+		}
+
+		if resp.Pruned {
+			return fmt.Errorf("%w: %s", ErrLogPruned, resp.Error)
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+
+		if err := s.storage.ApplyReplicated(resp.Records); err != nil {
+			return err
+		}
+		s.masterLSN.Store(resp.LastLSN)
+		s.lastSync.Store(time.Now().UnixNano())
+
+		if len(resp.Records) > 0 {
+			s.logger.Debug("replication slave applied records",
+				zap.Int("count", len(resp.Records)),
+				zap.Uint64("lastLSN", resp.Records[len(resp.Records)-1].LSN),
+			)
+		}
+
+		if len(resp.Records) < syncBatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Lag returns number of master log records which slave hasn't applied yet as of the last sync.
+func (s *Slave) Lag() uint64 {
+	masterLSN, lastLSN := s.masterLSN.Load(), s.storage.LastLSN()
+	if masterLSN < lastLSN {
+		return 0
+	}
+	return masterLSN - lastLSN
+}
+
+// LastSync returns time of the last successful sync with master, zero time if there was none.
+func (s *Slave) LastSync() time.Time {
+	nsec := s.lastSync.Load()
+	if nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec)
+}
+
+func (s *Slave) connect(ctx context.Context) error {
+	if s.conn != nil {
+		return nil
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := net.Dialer{Timeout: dialTimeout}
+	if s.tlsConfig != nil {
+		// server name is taken from master address
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: s.tlsConfig()}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", s.config.Replication.MasterAddress)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.config.Replication.MasterAddress)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	s.encoder = gob.NewEncoder(conn)
+	s.decoder = gob.NewDecoder(conn)
+
+	if err := s.authenticate(); err != nil {
+		s.disconnect()
+		return err
+	}
+
+	return nil
+}
+
+// authenticate sends credentials of config, master which has no users accepts any of them.
+func (s *Slave) authenticate() error {
+	s.conn.SetDeadline(time.Now().Add(readWriteTimeout))
+
+	req := authRequest{User: s.config.Replication.User, Password: s.config.Replication.Password}
+	if err := s.encoder.Encode(&req); err != nil {
+		return err
+	}
+
+	var resp authResponse
+	if err := s.decoder.Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("replication error: master rejected slave: %s", resp.Error)
+	}
+
+	return nil
+}
+
+func (s *Slave) disconnect() {
+	if s.conn == nil {
+		return
+	}
+
+	s.conn.Close()
+	s.conn = nil
+	s.encoder = nil
+	s.decoder = nil
+}
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"slices"
+	"sort"
+)
+
+const (
+	// minimal number of children of inner node except root
+	btreeDegree   = 32
+	btreeMaxItems = 2*btreeDegree - 1
+	btreeMinItems = btreeDegree - 1
+)
+
+// btree keeps keys sorted. It is not safe for concurrent use, engine guards it by its lock.
+// Nodes are split on the way down on insert and grown on the way down on delete, so every operation is one pass.
+type btree struct {
+	root *btreeNode
+	len  int
+}
+
+type btreeNode struct {
+	items []string
+	// nil for leaf, otherwise len(children) == len(items)+1
+	children []*btreeNode
+}
+
+func newBTree() *btree {
+	return &btree{}
+}
+
+// insert adds key, it returns false if key already exists.
+func (t *btree) insert(key string) bool {
+	if t.root == nil {
+		t.root = &btreeNode{items: []string{key}}
+		t.len++
+		return true
+	}
+
+	if len(t.root.items) >= btreeMaxItems {
+		old := t.root
+		t.root = &btreeNode{children: []*btreeNode{old}}
+		t.root.splitChild(0)
+	}
+
+	if !t.root.insert(key) {
+		return false
+	}
+	t.len++
+	return true
+}
+
+// delete removes key, it returns false if there is no such key.
+func (t *btree) delete(key string) bool {
+	if t.root == nil {
+		return false
+	}
+
+	deleted := t.root.remove(key)
+	if len(t.root.items) == 0 {
+		if len(t.root.children) > 0 {
+			t.root = t.root.children[0]
+		} else {
+			t.root = nil
+		}
+	}
+
+	if deleted {
+		t.len--
+	}
+	return deleted
+}
+
+// ascend calls fn for keys greater or equal to from in ascending order until fn returns false.
+func (t *btree) ascend(from string, fn func(key string) bool) {
+	if t.root != nil {
+		t.root.ascend(from, fn)
+	}
+}
+
+// descend calls fn in descending order for keys which are within upper bound until fn returns false.
+// Within must be true for all keys less than some bound and false for others.
+func (t *btree) descend(within func(key string) bool, fn func(key string) bool) {
+	if t.root != nil {
+		t.root.descend(within, fn)
+	}
+}
+
+func (n *btreeNode) leaf() bool {
+	return len(n.children) == 0
+}
+
+func (n *btreeNode) find(key string) (int, bool) {
+	i := sort.SearchStrings(n.items, key)
+	return i, i < len(n.items) && n.items[i] == key
+}
+
+// splitChild splits full child in two, its median item moves up to n.
+func (n *btreeNode) splitChild(i int) {
+	child := n.children[i]
+	median := child.items[btreeMinItems]
+
+	right := &btreeNode{items: slices.Clone(child.items[btreeMinItems+1:])}
+	child.items = truncate(child.items, btreeMinItems)
+	if !child.leaf() {
+		right.children = slices.Clone(child.children[btreeMinItems+1:])
+		child.children = truncate(child.children, btreeMinItems+1)
+	}
+
+	n.items = slices.Insert(n.items, i, median)
+	n.children = slices.Insert(n.children, i+1, right)
+}
+
+func (n *btreeNode) insert(key string) bool {
+	i, found := n.find(key)
+	if found {
+		return false
+	}
+
+	if n.leaf() {
+		n.items = slices.Insert(n.items, i, key)
+		return true
+	}
+
+	if len(n.children[i].items) >= btreeMaxItems {
+		n.splitChild(i)
+		switch {
+		case key == n.items[i]:
+			return false
+		case key > n.items[i]:
+			i++
+		}
+	}
+
+	return n.children[i].insert(key)
+}
+
+// remove deletes key from subtree, child is grown before descending to it, so it never gets too small.
+func (n *btreeNode) remove(key string) bool {
+	i, found := n.find(key)
+	if n.leaf() {
+		if !found {
+			return false
+		}
+		n.items = removeAt(n.items, i)
+		return true
+	}
+
+	if len(n.children[i].items) <= btreeMinItems {
+		n.growChild(i)
+		return n.remove(key)
+	}
+
+	if found {
+		// key is replaced by its predecessor which is the greatest key of the left subtree
+		n.items[i] = n.children[i].removeMax()
+		return true
+	}
+
+	return n.children[i].remove(key)
+}
+
+func (n *btreeNode) removeMax() string {
+	if n.leaf() {
+		last := n.items[len(n.items)-1]
+		n.items = removeAt(n.items, len(n.items)-1)
+		return last
+	}
+
+	i := len(n.children) - 1
+	if len(n.children[i].items) <= btreeMinItems {
+		n.growChild(i)
+		return n.removeMax()
+	}
+
+	return n.children[i].removeMax()
+}
+
+// growChild adds item to child taking it from sibling or merges child with sibling if both are small.
+func (n *btreeNode) growChild(i int) {
+	child := n.children[i]
+
+	if i > 0 && len(n.children[i-1].items) > btreeMinItems {
+		left := n.children[i-1]
+		child.items = slices.Insert(child.items, 0, n.items[i-1])
+		n.items[i-1] = left.items[len(left.items)-1]
+		left.items = removeAt(left.items, len(left.items)-1)
+		if !left.leaf() {
+			child.children = slices.Insert(child.children, 0, left.children[len(left.children)-1])
+			left.children = removeAt(left.children, len(left.children)-1)
+		}
+		return
+	}
+
+	if i < len(n.items) && len(n.children[i+1].items) > btreeMinItems {
+		right := n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = removeAt(right.items, 0)
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = removeAt(right.children, 0)
+		}
+		return
+	}
+
+	// the last child is merged with its left sibling
+	if i == len(n.items) {
+		i--
+		child = n.children[i]
+	}
+	right := n.children[i+1]
+	child.items = append(child.items, n.items[i])
+	child.items = append(child.items, right.items...)
+	child.children = append(child.children, right.children...)
+	n.items = removeAt(n.items, i)
+	n.children = removeAt(n.children, i+1)
+}
+
+func (n *btreeNode) ascend(from string, fn func(key string) bool) bool {
+	i := sort.SearchStrings(n.items, from)
+	if !n.leaf() && !n.children[i].ascend(from, fn) {
+		return false
+	}
+
+	for ; i < len(n.items); i++ {
+		if !fn(n.items[i]) {
+			return false
+		}
+		if !n.leaf() && !n.children[i+1].ascend(from, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (n *btreeNode) descend(within func(key string) bool, fn func(key string) bool) bool {
+	i := sort.Search(len(n.items), func(j int) bool {
+		return !within(n.items[j])
+	})
+	if !n.leaf() && !n.children[i].descend(within, fn) {
+		return false
+	}
+
+	for i--; i >= 0; i-- {
+		if !fn(n.items[i]) {
+			return false
+		}
+		if !n.leaf() && !n.children[i].descend(within, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// removeAt removes item keeping order, freed tail is cleared, so removed item may be garbage collected.
+func removeAt[T any](s []T, i int) []T {
+	copy(s[i:], s[i+1:])
+	return truncate(s, len(s)-1)
+}
+
+func truncate[T any](s []T, n int) []T {
+	clear(s[n:])
+	return s[:n]
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// checkBTree verifies that keys are sorted and every node except root has allowed number of items.
+func checkBTree(t *testing.T, tree *btree) []string {
+	t.Helper()
+
+	var keys []string
+	var walk func(n *btreeNode, depth int) int
+	walk = func(n *btreeNode, depth int) int {
+		if n != tree.root && (len(n.items) < btreeMinItems || len(n.items) > btreeMaxItems) {
+			t.Fatalf("node has %d items", len(n.items))
+		}
+		if n.leaf() {
+			keys = append(keys, n.items...)
+			return depth
+		}
+		if len(n.children) != len(n.items)+1 {
+			t.Fatalf("node has %d items and %d children", len(n.items), len(n.children))
+		}
+		leafDepth := -1
+		for i, child := range n.children {
+			d := walk(child, depth+1)
+			if leafDepth != -1 && d != leafDepth {
+				t.Fatal("leaves have different depth")
+			}
+			leafDepth = d
+			if i < len(n.items) {
+				keys = append(keys, n.items[i])
+			}
+		}
+		return leafDepth
+	}
+	if tree.root != nil {
+		walk(tree.root, 0)
+	}
+
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("keys are not sorted")
+	}
+	if len(keys) != tree.len {
+		t.Fatalf("tree has %d keys, len is %d", len(keys), tree.len)
+	}
+	return keys
+}
+
+func TestBTree_InsertDelete(t *testing.T) {
+	tree := newBTree()
+	want := make(map[string]struct{})
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("key:%d", rnd.Intn(5000))
+		_, exists := want[key]
+		if rnd.Intn(3) == 0 {
+			if tree.delete(key) != exists {
+				t.Fatalf("delete(%s) = %v, want %v", key, !exists, exists)
+			}
+			delete(want, key)
+		} else {
+			if tree.insert(key) == exists {
+				t.Fatalf("insert(%s) = %v, want %v", key, exists, !exists)
+			}
+			want[key] = struct{}{}
+		}
+	}
+
+	wantKeys := make([]string, 0, len(want))
+	for key := range want {
+		wantKeys = append(wantKeys, key)
+	}
+	sort.Strings(wantKeys)
+	if got := checkBTree(t, tree); !slices.Equal(got, wantKeys) {
+		t.Fatal("tree keys differ from inserted ones")
+	}
+
+	for _, key := range wantKeys {
+		if !tree.delete(key) {
+			t.Fatalf("delete(%s) = false", key)
+		}
+	}
+	if tree.root != nil || tree.len != 0 {
+		t.Fatal("tree is not empty")
+	}
+}
+
+func TestBTree_Iterate(t *testing.T) {
+	tree := newBTree()
+	for i := 0; i < 1000; i++ {
+		tree.insert(fmt.Sprintf("%04d", i))
+	}
+
+	var got []string
+	tree.ascend("0990", func(key string) bool {
+		got = append(got, key)
+		return len(got) < 5
+	})
+	if want := []string{"0990", "0991", "0992", "0993", "0994"}; !slices.Equal(got, want) {
+		t.Errorf("ascend() = %v, want %v", got, want)
+	}
+
+	got = nil
+	tree.descend(func(key string) bool { return key <= "0010" }, func(key string) bool {
+		got = append(got, key)
+		return true
+	})
+	if len(got) != 11 || got[0] != "0010" || got[10] != "0000" {
+		t.Errorf("descend() = %v", got)
+	}
+}
@@ -1,39 +1,505 @@
 package engine
 
-import "sync"
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// number of keys with expiration checked by one step of active expiration
+	expireSampleSize = 20
+	// max number of active expiration steps in one sweep
+	expireMaxSteps = 16
+	// number of keys compared to choose one to evict
+	evictionSampleSize = 5
+	// estimated memory used by map entry besides key and value
+	entryOverhead = 64
+	// access frequency of LFU policy is halved every period key is not accessed
+	lfuDecayPeriod = time.Minute
+	// number of keys copied by one step of dump, lock is released between steps
+	dumpChunkSize = 1000
+)
+
+var (
+	ErrOutOfMemory = errors.New("engine error: out of memory, command not allowed when used memory > max memory")
+	// ErrUnknownCursor is returned by engines which keep state of iteration, e.g. it is forgotten on restart
+	ErrUnknownCursor = errors.New("engine error: unknown cursor, iteration must be started again")
+)
+
+type EvictionPolicy string
+
+const (
+	EvictionPolicyNoEviction  EvictionPolicy = "noeviction"
+	EvictionPolicyAllKeysLRU  EvictionPolicy = "allkeys-lru"
+	EvictionPolicyAllKeysLFU  EvictionPolicy = "allkeys-lfu"
+	EvictionPolicyVolatileTTL EvictionPolicy = "volatile-ttl"
+	EvictionPolicyRandom      EvictionPolicy = "random"
+)
 
 type Engine interface {
 	Set(key, value string)
+	SetWithExpiration(key, value string, expireAt time.Time)
 	Get(key string) (string, bool)
 	Del(key string)
+	Expire(key string, expireAt time.Time) bool
+	Persist(key string) bool
+	ExpireAt(key string) (time.Time, bool)
+	DeleteExpired() int
+	Reserve(key string, valueSize int, evict EvictFunc) ([]string, error)
+	Stats() Stats
+	Dump(fn func(Item) error) error
+	Scan(cursor uint64, count int) ([]string, uint64, error)
+}
+
+// EvictFunc reports whether key may be evicted, it is called under lock of engine and must not block.
+// Nil EvictFunc allows to evict any key.
+type EvictFunc func(key string) bool
+
+// Durable is implemented by engines which keep data on disk. Writes are applied to memtable which is flushed
+// to disk in background, so write-ahead log is needed only for writes which are not flushed yet.
+// Every memtable has its own generation, generation grows by one when full memtable is passed to flush.
+type Durable interface {
+	// Generation returns generation of memtable which writes are applied to now.
+	Generation() uint64
+	// FlushedGeneration returns generation which all memtables are flushed up to, including it.
+	FlushedGeneration() uint64
+	// Flushed returns channel which is signaled after memtable is flushed.
+	Flushed() <-chan struct{}
+}
+
+// Item is a key with its value and expiration time, zero time means key never expires.
+type Item struct {
+	Key      string
+	Value    string
+	ExpireAt time.Time
+}
+
+// Stats describes engine state.
+type Stats struct {
+	Keys int
+	// Keys is an upper estimate, e.g. it counts versions of keys which aren't merged yet
+	KeysEstimated bool
+	// estimated memory used by keys and values
+	UsedMemory uint64
+	MaxMemory  uint64
+	// number of keys evicted because of memory limit
+	Evictions uint64
+}
+
+type Option func(*engine)
+
+// WithMaxMemory limits estimated memory used by keys and values, policy chooses keys evicted to free memory.
+// Zero size means no limit.
+func WithMaxMemory(size uint64, policy EvictionPolicy) Option {
+	return func(e *engine) {
+		e.maxMemory = int64(size)
+		e.policy = policy
+	}
+}
+
+type entry struct {
+	value string
+	// unix time in nanoseconds, zero means key never expires
+	expireAt int64
+	// unix time in nanoseconds of last access and access counter, updated by readers under read lock
+	lastAccess atomic.Int64
+	freq       atomic.Uint32
+}
+
+func (en *entry) expired(now int64) bool {
+	return en.expireAt != 0 && en.expireAt <= now
+}
+
+func (en *entry) touch(now int64) {
+	en.lastAccess.Store(now)
+	if freq := en.freq.Load(); freq < ^uint32(0) {
+		en.freq.CompareAndSwap(freq, freq+1)
+	}
+}
+
+// frequency returns access counter decayed by time key is not accessed.
+func (en *entry) frequency(now int64) uint32 {
+	periods := time.Duration(now-en.lastAccess.Load()) / lfuDecayPeriod
+	if periods >= 32 {
+		return 0
+	}
+	return en.freq.Load() >> periods
+}
+
+func entrySize(key string, valueSize int) int64 {
+	return int64(len(key) + valueSize + entryOverhead)
 }
 
 type engine struct {
 	mx *sync.RWMutex
-	kv map[string]string
+	kv map[string]*entry
+	// keys with expiration, active expiration samples them
+	expires map[string]struct{}
+	// sorted keys, nil if engine is not ordered
+	index *btree
+	// keys by their hashes for scan
+	hashes *hashIndex
+
+	maxMemory  int64
+	policy     EvictionPolicy
+	usedMemory int64
+	evictions  atomic.Uint64
+}
+
+func NewEngine(opts ...Option) Engine {
+	return newEngine(opts...)
 }
 
-func NewEngine() Engine {
-	return &engine{
-		mx: &sync.RWMutex{},
-		kv: make(map[string]string),
+func newEngine(opts ...Option) *engine {
+	e := &engine{
+		mx:      &sync.RWMutex{},
+		kv:      make(map[string]*entry),
+		expires: make(map[string]struct{}),
+		hashes:  newHashIndex(),
+		policy:  EvictionPolicyNoEviction,
 	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
 }
 
 func (e *engine) Set(key, value string) {
 	defer e.mx.Unlock()
 	e.mx.Lock()
-	e.kv[key] = value
+	e.set(key, value, 0)
+	delete(e.expires, key)
 }
+
+func (e *engine) SetWithExpiration(key, value string, expireAt time.Time) {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	now := time.Now().UnixNano()
+	if expireAt.UnixNano() <= now {
+		e.del(key)
+		return
+	}
+
+	e.set(key, value, expireAt.UnixNano())
+	e.expires[key] = struct{}{}
+}
+
 func (e *engine) Get(key string) (string, bool) {
-	defer e.mx.RUnlock()
+	now := time.Now().UnixNano()
+
 	e.mx.RLock()
-	val, ok := e.kv[key]
-	return val, ok
+	en, ok := e.kv[key]
+	if !ok {
+		e.mx.RUnlock()
+		return "", false
+	}
+	expired := en.expired(now)
+	value := en.value
+	if !expired {
+		en.touch(now)
+	}
+	e.mx.RUnlock()
+
+	if expired {
+		e.deleteIfExpired(key)
+		return "", false
+	}
+
+	return value, true
 }
 
 func (e *engine) Del(key string) {
 	defer e.mx.Unlock()
 	e.mx.Lock()
+	e.del(key)
+}
+
+// Expire sets expiration time of existing key.
+func (e *engine) Expire(key string, expireAt time.Time) bool {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	now := time.Now().UnixNano()
+	en, ok := e.kv[key]
+	if !ok || en.expired(now) {
+		return false
+	}
+
+	en.expireAt = expireAt.UnixNano()
+	if en.expired(now) {
+		e.del(key)
+		return true
+	}
+
+	e.expires[key] = struct{}{}
+	return true
+}
+
+// Persist removes expiration of key. It returns false if key doesn't exist or has no expiration.
+func (e *engine) Persist(key string) bool {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	en, ok := e.kv[key]
+	if !ok || en.expireAt == 0 || en.expired(time.Now().UnixNano()) {
+		return false
+	}
+
+	en.expireAt = 0
+	delete(e.expires, key)
+	return true
+}
+
+// ExpireAt returns expiration time of key, zero time means key never expires.
+func (e *engine) ExpireAt(key string) (time.Time, bool) {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	en, ok := e.kv[key]
+	if !ok || en.expired(time.Now().UnixNano()) {
+		return time.Time{}, false
+	}
+
+	if en.expireAt == 0 {
+		return time.Time{}, true
+	}
+
+	return time.Unix(0, en.expireAt), true
+}
+
+// DeleteExpired makes one cycle of active expiration: it checks random samples of keys with expiration
+// and repeats while a significant part of sample is expired. Returns number of deleted keys.
+func (e *engine) DeleteExpired() int {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	deleted := 0
+	for step := 0; step < expireMaxSteps; step++ {
+		now := time.Now().UnixNano()
+		sampled, expired := 0, 0
+
+		// map iteration order is random, so first keys are a random sample
+		for key := range e.expires {
+			if sampled == expireSampleSize {
+				break
+			}
+			sampled++
+
+			if e.kv[key].expired(now) {
+				e.del(key)
+				expired++
+			}
+		}
+
+		deleted += expired
+		if expired <= expireSampleSize/4 {
+			break
+		}
+	}
+
+	return deleted
+}
+
+func (e *engine) deleteIfExpired(key string) {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	if en, ok := e.kv[key]; ok && en.expired(time.Now().UnixNano()) {
+		e.del(key)
+	}
+}
+
+// Reserve makes room for value of key before it is set, evicting other keys by eviction policy.
+// Keys which evict doesn't allow to evict are skipped. Evicted keys are deleted and returned,
+// so caller can log their deletion. It returns ErrOutOfMemory if memory limit is reached
+// and policy doesn't allow to free enough memory, keys evicted before that are returned with error.
+func (e *engine) Reserve(key string, valueSize int, evict EvictFunc) ([]string, error) {
+	if e.maxMemory == 0 {
+		return nil, nil
+	}
+
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	need := entrySize(key, valueSize)
+	if need > e.maxMemory {
+		return nil, ErrOutOfMemory
+	}
+	if en, ok := e.kv[key]; ok {
+		need -= entrySize(key, len(en.value))
+	}
+
+	var (
+		evicted []string
+		skipped map[string]struct{}
+	)
+	now := time.Now().UnixNano()
+	for e.usedMemory+need > e.maxMemory {
+		victim, ok := e.evictionCandidate(key, skipped, now)
+		if !ok {
+			return evicted, ErrOutOfMemory
+		}
+		if evict != nil && !evict(victim) {
+			if skipped == nil {
+				skipped = make(map[string]struct{})
+			}
+			skipped[victim] = struct{}{}
+			continue
+		}
+		e.del(victim)
+		e.evictions.Add(1)
+		evicted = append(evicted, victim)
+	}
+
+	return evicted, nil
+}
+
+func (e *engine) Stats() Stats {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	return Stats{
+		Keys:       len(e.kv),
+		UsedMemory: uint64(e.usedMemory),
+		MaxMemory:  uint64(e.maxMemory),
+		Evictions:  e.evictions.Load(),
+	}
+}
+
+// Dump calls fn for every key which is not expired. Keys are walked by chunks like Scan walks them
+// and every chunk is copied under read lock, so writers wait only for copy of a chunk and fn may be slow
+// (e.g. write to disk) without blocking them. Every key which isn't changed during dump is dumped exactly once.
+func (e *engine) Dump(fn func(Item) error) error {
+	var cursor uint64
+	for {
+		keys, next, _ := e.Scan(cursor, dumpChunkSize)
+		for _, item := range e.items(keys) {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// items copies keys which are not expired, keys deleted since they were listed are skipped.
+func (e *engine) items(keys []string) []Item {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	now := time.Now().UnixNano()
+	items := make([]Item, 0, len(keys))
+	for _, key := range keys {
+		en, ok := e.kv[key]
+		if !ok || en.expired(now) {
+			continue
+		}
+		item := Item{Key: key, Value: en.value}
+		if en.expireAt != 0 {
+			item.ExpireAt = time.Unix(0, en.expireAt)
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// evictionCandidate chooses key to evict among random sample of keys, reserved and skipped keys are never chosen.
+func (e *engine) evictionCandidate(reserved string, skipped map[string]struct{}, now int64) (string, bool) {
+	var (
+		candidate string
+		best      *entry
+		found     bool
+		sampled   int
+	)
+
+	consider := func(key string) bool {
+		if _, skip := skipped[key]; skip || key == reserved {
+			return true
+		}
+		en := e.kv[key]
+		if !found || e.better(en, best, now) {
+			candidate, best, found = key, en, true
+		}
+		sampled++
+		return sampled < evictionSampleSize
+	}
+
+	// map iteration order is random, so first keys are a random sample
+	switch e.policy {
+	case EvictionPolicyAllKeysLRU, EvictionPolicyAllKeysLFU:
+		for key := range e.kv {
+			if !consider(key) {
+				break
+			}
+		}
+	case EvictionPolicyVolatileTTL:
+		for key := range e.expires {
+			if !consider(key) {
+				break
+			}
+		}
+	case EvictionPolicyRandom:
+		for key := range e.kv {
+			if _, skip := skipped[key]; !skip && key != reserved {
+				return key, true
+			}
+		}
+	}
+
+	return candidate, found
+}
+
+// better reports whether entry a should be evicted rather than b.
+func (e *engine) better(a, b *entry, now int64) bool {
+	switch e.policy {
+	case EvictionPolicyAllKeysLFU:
+		fa, fb := a.frequency(now), b.frequency(now)
+		if fa != fb {
+			return fa < fb
+		}
+		return a.lastAccess.Load() < b.lastAccess.Load()
+	case EvictionPolicyVolatileTTL:
+		return a.expireAt < b.expireAt
+	default:
+		return a.lastAccess.Load() < b.lastAccess.Load()
+	}
+}
+
+func (e *engine) set(key, value string, expireAt int64) {
+	en := &entry{value: value, expireAt: expireAt}
+	if old, ok := e.kv[key]; ok {
+		// overwrite is access to key, so it keeps its frequency
+		en.freq.Store(old.freq.Load())
+		e.usedMemory -= entrySize(key, len(old.value))
+	} else {
+		e.hashes.insert(key)
+		if e.index != nil {
+			e.index.insert(key)
+		}
+	}
+	en.touch(time.Now().UnixNano())
+
+	e.kv[key] = en
+	e.usedMemory += entrySize(key, len(value))
+}
+
+func (e *engine) del(key string) {
+	if en, ok := e.kv[key]; ok {
+		e.usedMemory -= entrySize(key, len(en.value))
+		e.hashes.delete(key)
+		if e.index != nil {
+			e.index.delete(key)
+		}
+	}
 	delete(e.kv, key)
+	delete(e.expires, key)
 }
@@ -1,9 +1,25 @@
 package engine
 
 import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+var testEngines = map[string]func() Engine{
+	"in_memory": func() Engine {
+		return NewEngine()
+	},
+	"in_memory_sharded": func() Engine {
+		return NewShardedEngine(DefaultShardsCount)
+	},
+	"btree": func() Engine {
+		return NewBTreeEngine()
+	},
+}
+
 func TestEngine_SetGetDel(t *testing.T) {
 	type op struct {
 		action string
@@ -96,23 +112,270 @@ func TestEngine_SetGetDel(t *testing.T) {
 		},
 	}
 
+	for engineName, newEngine := range testEngines {
+		for _, tt := range tests {
+			t.Run(engineName+"/"+tt.name, func(t *testing.T) {
+				e := newEngine()
+				for _, op := range tt.ops {
+					switch op.action {
+					case "set":
+						e.Set(op.key, op.value)
+					case "del":
+						e.Del(op.key)
+					}
+				}
+				for k, want := range tt.wantGet {
+					gotVal, gotOk := e.Get(k)
+					if gotVal != want.val || gotOk != want.ok {
+						t.Errorf("Get(%q) = (%q, %v), want (%q, %v)", k, gotVal, gotOk, want.val, want.ok)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEngine_Expiration(t *testing.T) {
+	for engineName, newEngine := range testEngines {
+		t.Run(engineName, func(t *testing.T) {
+			e := newEngine()
+			now := time.Now()
+
+			e.SetWithExpiration("expired", "value", now.Add(-time.Second))
+			if _, ok := e.Get("expired"); ok {
+				t.Errorf("Get(expired) returns key set with expiration time in the past")
+			}
+
+			e.SetWithExpiration("short", "value", now.Add(20*time.Millisecond))
+			if val, ok := e.Get("short"); !ok || val != "value" {
+				t.Errorf("Get(short) = (%q, %v), want (%q, true)", val, ok, "value")
+			}
+
+			e.Set("long", "value")
+			if !e.Expire("long", now.Add(time.Hour)) {
+				t.Errorf("Expire(long) = false, want true")
+			}
+			if expireAt, ok := e.ExpireAt("long"); !ok || !expireAt.Equal(time.Unix(0, now.Add(time.Hour).UnixNano())) {
+				t.Errorf("ExpireAt(long) = (%v, %v), want (%v, true)", expireAt, ok, now.Add(time.Hour))
+			}
+			if e.Expire("missing", now.Add(time.Hour)) {
+				t.Errorf("Expire(missing) = true, want false")
+			}
+
+			e.Set("persisted", "value")
+			e.Expire("persisted", now.Add(20*time.Millisecond))
+			if !e.Persist("persisted") {
+				t.Errorf("Persist(persisted) = false, want true")
+			}
+			if e.Persist("persisted") {
+				t.Errorf("second Persist(persisted) = true, want false")
+			}
+
+			e.SetWithExpiration("overwritten", "value", now.Add(20*time.Millisecond))
+			e.Set("overwritten", "value")
+
+			time.Sleep(30 * time.Millisecond)
+
+			if _, ok := e.Get("short"); ok {
+				t.Errorf("Get(short) returns expired key")
+			}
+			if _, ok := e.ExpireAt("short"); ok {
+				t.Errorf("ExpireAt(short) returns expired key")
+			}
+			for _, key := range []string{"long", "persisted", "overwritten"} {
+				if _, ok := e.Get(key); !ok {
+					t.Errorf("Get(%s) doesn't return key which must not expire", key)
+				}
+			}
+			if expireAt, ok := e.ExpireAt("persisted"); !ok || !expireAt.IsZero() {
+				t.Errorf("ExpireAt(persisted) = (%v, %v), want zero time", expireAt, ok)
+			}
+		})
+	}
+}
+
+func TestEngine_DeleteExpired(t *testing.T) {
+	for engineName, newEngine := range testEngines {
+		t.Run(engineName, func(t *testing.T) {
+			e := newEngine()
+			expireAt := time.Now().Add(10 * time.Millisecond)
+			for i := 0; i < 100; i++ {
+				e.SetWithExpiration(fmt.Sprintf("key_%d", i), "value", expireAt)
+			}
+			e.Set("forever", "value")
+
+			time.Sleep(20 * time.Millisecond)
+
+			deleted := 0
+			for i := 0; i < 100 && deleted < 100; i++ {
+				deleted += e.DeleteExpired()
+			}
+			if deleted != 100 {
+				t.Errorf("DeleteExpired() deleted %d keys, want 100", deleted)
+			}
+			if _, ok := e.Get("forever"); !ok {
+				t.Errorf("DeleteExpired() deleted key without expiration")
+			}
+		})
+	}
+}
+
+func TestEngine_Eviction(t *testing.T) {
+	// room for exactly four keys like "k1" with value "v"
+	maxMemory := uint64(4 * entrySize("k1", 1))
+
+	tests := []struct {
+		name        string
+		policy      EvictionPolicy
+		prepare     func(e Engine)
+		evict       EvictFunc
+		wantErr     error
+		wantEvicted string
+	}{
+		{
+			name:    "noeviction",
+			policy:  EvictionPolicyNoEviction,
+			wantErr: ErrOutOfMemory,
+		},
+		{
+			name:   "allkeys-lru evicts least recently used key",
+			policy: EvictionPolicyAllKeysLRU,
+			prepare: func(e Engine) {
+				e.Get("k1")
+			},
+			wantEvicted: "k2",
+		},
+		{
+			name:   "key which can't be evicted is skipped",
+			policy: EvictionPolicyAllKeysLRU,
+			prepare: func(e Engine) {
+				e.Get("k1")
+			},
+			evict:       func(key string) bool { return key != "k2" },
+			wantEvicted: "k3",
+		},
+		{
+			name:    "no key can be evicted",
+			policy:  EvictionPolicyAllKeysLRU,
+			evict:   func(string) bool { return false },
+			wantErr: ErrOutOfMemory,
+		},
+		{
+			name:   "allkeys-lfu evicts least frequently used key",
+			policy: EvictionPolicyAllKeysLFU,
+			prepare: func(e Engine) {
+				for i := 0; i < 3; i++ {
+					e.Get("k1")
+					e.Get("k2")
+					e.Get("k4")
+				}
+			},
+			wantEvicted: "k3",
+		},
+		{
+			name:   "volatile-ttl evicts key with nearest expiration",
+			policy: EvictionPolicyVolatileTTL,
+			prepare: func(e Engine) {
+				e.Expire("k1", time.Now().Add(time.Hour))
+				e.Expire("k4", time.Now().Add(time.Minute))
+			},
+			wantEvicted: "k4",
+		},
+		{
+			name:    "volatile-ttl without keys with expiration",
+			policy:  EvictionPolicyVolatileTTL,
+			wantErr: ErrOutOfMemory,
+		},
+		{
+			name:   "random",
+			policy: EvictionPolicyRandom,
+		},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			e := NewEngine()
-			for _, op := range tt.ops {
-				switch op.action {
-				case "set":
-					e.Set(op.key, op.value)
-				case "del":
-					e.Del(op.key)
+			e := NewEngine(WithMaxMemory(maxMemory, tt.policy))
+			for i := 1; i <= 4; i++ {
+				key := fmt.Sprintf("k%d", i)
+				if _, err := e.Reserve(key, 1, nil); err != nil {
+					t.Fatalf("Reserve(%q) unexpected error: %v", key, err)
 				}
+				e.Set(key, "v")
+				time.Sleep(time.Millisecond)
 			}
-			for k, want := range tt.wantGet {
-				gotVal, gotOk := e.Get(k)
-				if gotVal != want.val || gotOk != want.ok {
-					t.Errorf("Get(%q) = (%q, %v), want (%q, %v)", k, gotVal, gotOk, want.val, want.ok)
+			if tt.prepare != nil {
+				tt.prepare(e)
+			}
+
+			// overwrite of existing key with value of the same size doesn't need memory
+			if _, err := e.Reserve("k1", 1, nil); err != nil {
+				t.Fatalf("Reserve() of existing key unexpected error: %v", err)
+			}
+
+			evicted, err := e.Reserve("k5", 1, tt.evict)
+			if err != tt.wantErr {
+				t.Fatalf("Reserve() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stats := e.Stats()
+			if tt.wantErr != nil {
+				if stats.Keys != 4 || stats.Evictions != 0 {
+					t.Errorf("Stats() = %+v, want 4 keys and no evictions", stats)
+				}
+				return
+			}
+
+			if stats.Keys != 3 || stats.Evictions != 1 || len(evicted) != 1 {
+				t.Errorf("Stats() = %+v, evicted %v, want 3 keys and 1 eviction", stats, evicted)
+			}
+			if tt.wantEvicted != "" {
+				if len(evicted) == 1 && evicted[0] != tt.wantEvicted {
+					t.Errorf("Reserve() evicted %v, want %q", evicted, tt.wantEvicted)
+				}
+				if _, ok := e.Get(tt.wantEvicted); ok {
+					t.Errorf("key %q is not evicted", tt.wantEvicted)
 				}
 			}
+
+			e.Set("k5", "v")
+			if stats := e.Stats(); stats.UsedMemory != maxMemory {
+				t.Errorf("Stats().UsedMemory = %d, want %d", stats.UsedMemory, maxMemory)
+			}
 		})
 	}
 }
+
+// BenchmarkEngine_MixedLoad runs concurrent gets and sets on random keys with different share of writes.
+func BenchmarkEngine_MixedLoad(b *testing.B) {
+	const keysCount = 10000
+
+	keys := make([]string, keysCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key_%d", i)
+	}
+
+	for _, writePercent := range []int{1, 10, 50} {
+		for _, engineName := range []string{"in_memory", "in_memory_sharded"} {
+			b.Run(fmt.Sprintf("%s/writes_%d%%", engineName, writePercent), func(b *testing.B) {
+				e := testEngines[engineName]()
+				for _, key := range keys {
+					e.Set(key, key)
+				}
+
+				var seed atomic.Int64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rnd := rand.New(rand.NewSource(seed.Add(1)))
+					for pb.Next() {
+						key := keys[rnd.Intn(keysCount)]
+						if rnd.Intn(100) < writePercent {
+							e.Set(key, key)
+						} else {
+							e.Get(key)
+						}
+					}
+				})
+			})
+		}
+	}
+}
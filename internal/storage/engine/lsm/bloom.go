@@ -0,0 +1,79 @@
+package lsm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	// 10 bits per key and 7 hash functions give about 1% of false positives
+	bloomBitsPerKey = 10
+	bloomHashes     = 7
+)
+
+// bloom filter tells that key is definitely not in table, so table isn't read for missing keys.
+type bloom struct {
+	hashes uint8
+	bits   []byte
+}
+
+func newBloom(keyHashes []uint64) bloom {
+	size := max(len(keyHashes)*bloomBitsPerKey, 64)
+	b := bloom{hashes: bloomHashes, bits: make([]byte, (size+7)/8)}
+	for _, h := range keyHashes {
+		b.add(h)
+	}
+	return b
+}
+
+func bloomHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// positions of key are derived from two halves of its hash (double hashing)
+func (b bloom) add(h uint64) {
+	n := uint64(len(b.bits) * 8)
+	h1, h2 := h&0xffffffff, h>>32
+	for i := uint64(0); i < uint64(b.hashes); i++ {
+		pos := (h1 + i*h2) % n
+		b.bits[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+func (b bloom) mayContain(key string) bool {
+	if len(b.bits) == 0 {
+		return true
+	}
+
+	h := bloomHash(key)
+	n := uint64(len(b.bits) * 8)
+	h1, h2 := h&0xffffffff, h>>32
+	for i := uint64(0); i < uint64(b.hashes); i++ {
+		pos := (h1 + i*h2) % n
+		if b.bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bloom) encode(buf []byte) []byte {
+	buf = append(buf, b.hashes)
+	buf = binary.AppendUvarint(buf, uint64(len(b.bits)))
+	return append(buf, b.bits...)
+}
+
+func decodeBloom(buf []byte) (bloom, error) {
+	if len(buf) < 1 {
+		return bloom{}, fmt.Errorf("%w: invalid bloom filter", ErrCorruptedTable)
+	}
+	hashes := buf[0]
+	size, n := binary.Uvarint(buf[1:])
+	if n <= 0 || uint64(len(buf)-1-n) != size {
+		return bloom{}, fmt.Errorf("%w: invalid bloom filter", ErrCorruptedTable)
+	}
+	return bloom{hashes: hashes, bits: buf[1+n:]}, nil
+}
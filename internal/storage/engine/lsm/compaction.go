@@ -0,0 +1,201 @@
+package lsm
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flushLoop flushes full memtables until engine is closed, compaction is started after every flush.
+func (e *Engine) flushLoop() {
+	defer e.wg.Done()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-e.flushCh:
+		}
+
+		if err := e.flush(); err != nil {
+			e.logger.Error("failed to flush memtable", zap.Error(err))
+			time.AfterFunc(flushRetryDelay, func() {
+				select {
+				case e.flushCh <- struct{}{}:
+				default:
+				}
+			})
+			continue
+		}
+
+		select {
+		case e.compactCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// compactLoop compacts tables in background, so flushes and therefore writers don't wait for compaction.
+func (e *Engine) compactLoop() {
+	defer e.wg.Done()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-e.compactCh:
+		}
+
+		if err := e.compact(); err != nil {
+			e.logger.Error("failed to compact tables", zap.Error(err))
+		}
+	}
+}
+
+// flush writes memtable passed to flush to new table, then writers waiting for it are woken up.
+func (e *Engine) flush() error {
+	e.mx.RLock()
+	mem, id, generation := e.flushing, e.nextID, e.flushingGeneration
+	e.mx.RUnlock()
+	if mem == nil || len(mem.kv) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	t, err := e.writeTable(id, id, &mergeIterator{sources: []iterator{&memIterator{items: mem.sorted("")}}}, false)
+	if err != nil {
+		return err
+	}
+
+	e.mx.Lock()
+	e.tables = append([]*table{t}, e.tables...)
+	e.nextID++
+	e.flushing = nil
+	e.flushedGeneration = generation
+	e.flushed.Broadcast()
+	e.mx.Unlock()
+
+	select {
+	case e.flushedCh <- struct{}{}:
+	default:
+	}
+
+	e.logger.Debug("memtable flushed", zap.Uint64("id", id), zap.Int("entries", len(mem.kv)), zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// compact merges runs of tables of the same tier until there are no runs of compaction threshold size.
+// Tier of table grows by one every time its size is multiplied by threshold, so every key is rewritten
+// only once per tier (size-tiered compaction).
+func (e *Engine) compact() error {
+	for {
+		e.mx.RLock()
+		tables := e.acquireTables()
+		e.mx.RUnlock()
+
+		from, to, ok := e.pickRun(tables)
+		if !ok {
+			releaseTables(tables)
+			return nil
+		}
+
+		err := e.merge(tables, from, to)
+		releaseTables(tables)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// pickRun finds the first run of adjacent tables of the same tier, tables are ordered from the newest one.
+func (e *Engine) pickRun(tables []*table) (int, int, bool) {
+	for from := 0; from < len(tables); {
+		to := from + 1
+		for to < len(tables) && e.tier(tables[to]) == e.tier(tables[from]) {
+			to++
+		}
+		if to-from >= e.compactionThreshold {
+			return from, to, true
+		}
+		from = to
+	}
+	return 0, 0, false
+}
+
+func (e *Engine) tier(t *table) int {
+	tier := 0
+	for size := t.size / e.memtableSize; size >= int64(e.compactionThreshold); size /= int64(e.compactionThreshold) {
+		tier++
+	}
+	return tier
+}
+
+// merge replaces tables[from:to] by one table, tombstones and expired entries are dropped
+// if there are no older tables which may have versions they hide.
+func (e *Engine) merge(tables []*table, from, to int) error {
+	start := time.Now()
+	run := tables[from:to]
+
+	sources := make([]iterator, 0, len(run))
+	for _, t := range run {
+		sources = append(sources, t.iterator(""))
+	}
+	bottom := to == len(tables)
+
+	merged, err := e.writeTable(run[len(run)-1].first, run[0].last, &mergeIterator{sources: sources}, bottom)
+	if err != nil {
+		return err
+	}
+
+	e.mx.Lock()
+	// flushes only prepend tables, so run is found at the same distance from the end of list
+	offset := len(e.tables) - len(tables)
+	replaced := append([]*table{}, e.tables[:offset+from]...)
+	replaced = append(replaced, merged)
+	e.tables = append(replaced, e.tables[offset+to:]...)
+	e.mx.Unlock()
+
+	for _, t := range run {
+		t.obsolete.Store(true)
+		// reference of engine
+		t.release()
+	}
+
+	e.logger.Info("tables compacted",
+		zap.Int("tables", len(run)),
+		zap.Bool("bottom", bottom),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+// writeTable writes entries of iterator to table. Table is written even if it is empty,
+// it covers IDs of merged tables, so they are removed on open if compaction is interrupted.
+func (e *Engine) writeTable(first, last uint64, it *mergeIterator, dropDeleted bool) (*table, error) {
+	w, err := createTable(e.dir, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	for {
+		item, ok := it.next()
+		if !ok {
+			break
+		}
+		if dropDeleted && !item.live(now) {
+			continue
+		}
+		if err := w.add(item.key, item.entry); err != nil {
+			w.abort()
+			return nil, err
+		}
+	}
+	if err := it.error(); err != nil {
+		w.abort()
+		return nil, fmt.Errorf("lsm error: can't read tables: %w", err)
+	}
+
+	return w.finish()
+}
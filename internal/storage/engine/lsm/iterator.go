@@ -0,0 +1,68 @@
+package lsm
+
+// iterator walks entries of memtable or table in key order, every key occurs once.
+type iterator interface {
+	valid() bool
+	item() keyEntry
+	next()
+	error() error
+}
+
+type memIterator struct {
+	items []keyEntry
+}
+
+func (it *memIterator) valid() bool {
+	return len(it.items) > 0
+}
+
+func (it *memIterator) item() keyEntry {
+	return it.items[0]
+}
+
+func (it *memIterator) next() {
+	it.items = it.items[1:]
+}
+
+func (it *memIterator) error() error {
+	return nil
+}
+
+// mergeIterator merges sources ordered from the newest to the oldest one,
+// version of key from the newest source hides versions from older ones.
+type mergeIterator struct {
+	sources []iterator
+}
+
+// next returns the next key with its newest version, false means iteration is finished.
+func (m *mergeIterator) next() (keyEntry, bool) {
+	newest := -1
+	for i, source := range m.sources {
+		// on equal keys the newer source wins, it is found first
+		if source.valid() && (newest < 0 || source.item().key < m.sources[newest].item().key) {
+			newest = i
+		}
+	}
+	if newest < 0 {
+		return keyEntry{}, false
+	}
+
+	item := m.sources[newest].item()
+	for _, source := range m.sources {
+		if source.valid() && source.item().key == item.key {
+			source.next()
+		}
+	}
+
+	return item, true
+}
+
+// error returns the first read error of sources, iteration stops early on error.
+func (m *mergeIterator) error() error {
+	for _, source := range m.sources {
+		if err := source.error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
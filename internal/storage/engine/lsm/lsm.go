@@ -0,0 +1,602 @@
+// Package lsm implements disk-backed engine based on log-structured merge tree.
+// Writes go to memtable, full memtable is flushed to immutable sorted table, tables of similar size are merged
+// by background compaction. Memtable isn't logged, it is flushed on Close, so engine must be used with WAL
+// to not lose it on crash. Engine is durable: log is pruned up to writes which are flushed to tables.
+package lsm
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMemtableSize        = 4 * config.MB
+	DefaultCompactionThreshold = 4
+
+	// number of keys with expiration checked by one step of active expiration
+	expireSampleSize = 20
+	// max number of active expiration steps in one sweep
+	expireMaxSteps = 16
+	// number of lock stripes which make read-modify-write operations atomic for every key
+	keyLocksCount = 256
+	// number of SCAN cursors kept, the oldest one is forgotten when it is exceeded and scan by it fails
+	maxScanCursors = 1024
+	// delay before retry of failed flush
+	flushRetryDelay = time.Second
+)
+
+var (
+	ErrCorruptedTable = errors.New("lsm error: corrupted table")
+)
+
+var (
+	_ engine.Engine  = (*Engine)(nil)
+	_ engine.Durable = (*Engine)(nil)
+)
+
+type Engine struct {
+	dir                 string
+	memtableSize        int64
+	compactionThreshold int
+	logger              *zap.Logger
+
+	// mx guards memtables and list of tables, disk is read without it
+	mx  sync.RWMutex
+	mem *memtable
+	// memtable which is being flushed, nil if there is none, writers wait for it when memtable is full again
+	flushing *memtable
+	flushed  *sync.Cond
+	// generations of memtable, memtable being flushed and the last flushed one
+	generation         uint64
+	flushingGeneration uint64
+	flushedGeneration  uint64
+	// signaled after every flush
+	flushedCh chan struct{}
+	// tables from the newest to the oldest one
+	tables []*table
+	nextID uint64
+
+	keyLocks [keyLocksCount]sync.Mutex
+
+	// keys to continue SCAN from by cursor
+	cursorsMx   sync.Mutex
+	cursors     map[uint64]string
+	cursorOrder []uint64
+	nextCursor  uint64
+
+	flushCh   chan struct{}
+	compactCh chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewEngine opens tables of data directory and starts background flushing and compaction.
+func NewEngine(cfg config.ConfigEngine, logger *zap.Logger) (*Engine, error) {
+	if cfg.DataDirectory == "" {
+		return nil, errors.New("lsm error: data directory is not set")
+	}
+	if err := os.MkdirAll(cfg.DataDirectory, 0o755); err != nil {
+		return nil, fmt.Errorf("lsm error: can't create data directory: %w", err)
+	}
+
+	e := &Engine{
+		dir:                 cfg.DataDirectory,
+		memtableSize:        int64(cfg.MemtableSize),
+		compactionThreshold: cfg.CompactionThreshold,
+		logger:              logger,
+		mem:                 newMemtable(),
+		generation:          1,
+		flushedCh:           make(chan struct{}, 1),
+		cursors:             make(map[uint64]string),
+		flushCh:             make(chan struct{}, 1),
+		compactCh:           make(chan struct{}, 1),
+		done:                make(chan struct{}),
+	}
+	if e.memtableSize <= 0 {
+		e.memtableSize = DefaultMemtableSize
+	}
+	if e.compactionThreshold < 2 {
+		e.compactionThreshold = DefaultCompactionThreshold
+	}
+	e.flushed = sync.NewCond(&e.mx)
+
+	if err := e.open(); err != nil {
+		return nil, err
+	}
+
+	e.wg.Add(2)
+	go e.flushLoop()
+	go e.compactLoop()
+
+	return e, nil
+}
+
+// open opens tables, table which is merged into another one is left if compaction is interrupted, it is removed.
+func (e *Engine) open() error {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return fmt.Errorf("lsm error: can't read data directory: %w", err)
+	}
+
+	type tableFile struct {
+		name        string
+		first, last uint64
+	}
+	var files []tableFile
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == tmpSuffix {
+			os.Remove(filepath.Join(e.dir, entry.Name()))
+			continue
+		}
+		if first, last, ok := parseTableName(entry.Name()); ok {
+			files = append(files, tableFile{name: entry.Name(), first: first, last: last})
+		}
+	}
+
+	// wider tables go first, so merged table is kept and tables it covers are removed
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].last-files[i].first > files[j].last-files[j].first
+	})
+	var kept []tableFile
+	for _, file := range files {
+		covered := slices.ContainsFunc(kept, func(k tableFile) bool {
+			return k.first <= file.first && file.last <= k.last
+		})
+		if covered {
+			e.logger.Info("remove merged table", zap.String("name", file.name))
+			os.Remove(filepath.Join(e.dir, file.name))
+			continue
+		}
+		kept = append(kept, file)
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].last > kept[j].last
+	})
+	for _, file := range kept {
+		t, err := openTable(filepath.Join(e.dir, file.name), file.first, file.last)
+		if err != nil {
+			e.closeTables()
+			return err
+		}
+		e.tables = append(e.tables, t)
+		e.nextID = max(e.nextID, file.last+1)
+	}
+	e.nextID = max(e.nextID, 1)
+
+	e.logger.Info("lsm engine opened", zap.String("dir", e.dir), zap.Int("tables", len(e.tables)))
+	return nil
+}
+
+func (e *Engine) Set(key, value string) {
+	e.write(key, entry{value: value})
+}
+
+func (e *Engine) SetWithExpiration(key, value string, expireAt time.Time) {
+	if !expireAt.After(time.Now()) {
+		e.write(key, entry{tombstone: true})
+		return
+	}
+	e.write(key, entry{value: value, expireAt: expireAt.UnixNano()})
+}
+
+func (e *Engine) Get(key string) (string, bool) {
+	en, ok := e.lookup(key)
+	if !ok || !en.live(time.Now().UnixNano()) {
+		return "", false
+	}
+	return en.value, true
+}
+
+func (e *Engine) Del(key string) {
+	e.write(key, entry{tombstone: true})
+}
+
+// Expire sets expiration time of existing key.
+func (e *Engine) Expire(key string, expireAt time.Time) bool {
+	mx := e.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	en, ok := e.lookup(key)
+	if !ok || !en.live(time.Now().UnixNano()) {
+		return false
+	}
+
+	if !expireAt.After(time.Now()) {
+		e.put(key, entry{tombstone: true})
+		return true
+	}
+	en.expireAt = expireAt.UnixNano()
+	e.put(key, en)
+	return true
+}
+
+// Persist removes expiration of key. It returns false if key doesn't exist or has no expiration.
+func (e *Engine) Persist(key string) bool {
+	mx := e.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	en, ok := e.lookup(key)
+	if !ok || en.expireAt == 0 || !en.live(time.Now().UnixNano()) {
+		return false
+	}
+
+	en.expireAt = 0
+	e.put(key, en)
+	return true
+}
+
+// ExpireAt returns expiration time of key, zero time means key never expires.
+func (e *Engine) ExpireAt(key string) (time.Time, bool) {
+	en, ok := e.lookup(key)
+	if !ok || !en.live(time.Now().UnixNano()) {
+		return time.Time{}, false
+	}
+	if en.expireAt == 0 {
+		return time.Time{}, true
+	}
+	return time.Unix(0, en.expireAt), true
+}
+
+// DeleteExpired replaces expired keys of memtable by tombstones, so their values aren't flushed.
+// Expired keys of tables are dropped by compaction. Returns number of deleted keys.
+//
+// Sampled keys are deleted under their key locks and checked again, so Expire or Persist which read key
+// before it expired don't write it back after tombstone.
+func (e *Engine) DeleteExpired() int {
+	deleted := 0
+	for step := 0; step < expireMaxSteps; step++ {
+		expired := 0
+		for _, key := range e.sampleExpired() {
+			if e.deleteExpired(key) {
+				expired++
+			}
+		}
+
+		deleted += expired
+		if expired <= expireSampleSize/4 {
+			break
+		}
+	}
+
+	return deleted
+}
+
+// sampleExpired returns expired keys among sample of memtable keys with expiration.
+func (e *Engine) sampleExpired() []string {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	now := time.Now().UnixNano()
+	sampled := 0
+	var expired []string
+
+	// map iteration order is random, so first keys are a random sample
+	for key := range e.mem.expires {
+		if sampled == expireSampleSize {
+			break
+		}
+		sampled++
+
+		if !e.mem.kv[key].live(now) {
+			expired = append(expired, key)
+		}
+	}
+
+	return expired
+}
+
+// deleteExpired replaces key of memtable by tombstone if it is still expired.
+func (e *Engine) deleteExpired(key string) bool {
+	mx := e.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	// key may be rewritten or memtable may be passed to flush since it was sampled
+	en, ok := e.mem.get(key)
+	if !ok || en.tombstone || en.live(time.Now().UnixNano()) {
+		return false
+	}
+	e.mem.set(key, entry{tombstone: true})
+	return true
+}
+
+// Reserve never fails, data is kept on disk and memtable is limited by its size.
+func (e *Engine) Reserve(key string, valueSize int, evict engine.EvictFunc) ([]string, error) {
+	return nil, nil
+}
+
+// Stats estimates number of keys by entries of memtables and tables,
+// so overwritten and deleted keys are counted until compaction merges their versions.
+func (e *Engine) Stats() engine.Stats {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	stats := engine.Stats{
+		Keys:          len(e.mem.kv),
+		KeysEstimated: true,
+		UsedMemory:    uint64(e.mem.size),
+	}
+	if e.flushing != nil {
+		stats.Keys += len(e.flushing.kv)
+		stats.UsedMemory += uint64(e.flushing.size)
+	}
+	for _, t := range e.tables {
+		stats.Keys += int(t.count)
+	}
+
+	return stats
+}
+
+// Dump calls fn for every key which is not expired in key order.
+// Memtables are copied and tables are read without lock, so writers aren't blocked.
+func (e *Engine) Dump(fn func(engine.Item) error) error {
+	it, release := e.iterator("")
+	defer release()
+
+	now := time.Now().UnixNano()
+	for {
+		item, ok := it.next()
+		if !ok {
+			break
+		}
+		if !item.live(now) {
+			continue
+		}
+		dumped := engine.Item{Key: item.key, Value: item.value}
+		if item.expireAt != 0 {
+			dumped.ExpireAt = time.Unix(0, item.expireAt)
+		}
+		if err := fn(dumped); err != nil {
+			return err
+		}
+	}
+
+	return it.error()
+}
+
+// Scan walks keys in key order, cursor refers to the last returned key, so keys may be set and deleted
+// between calls: every key which exists during the whole iteration is returned exactly once.
+// Cursor may be used again, e.g. to retry failed call. Cursors are kept in memory and only the latest
+// of them are kept, so scan by cursor which is forgotten or made before restart fails with ErrUnknownCursor.
+func (e *Engine) Scan(cursor uint64, count int) ([]string, uint64, error) {
+	from := ""
+	if cursor != 0 {
+		last, ok := e.lookupCursor(cursor)
+		if !ok {
+			return nil, 0, engine.ErrUnknownCursor
+		}
+		// the least key greater than the last returned one
+		from = last + "\x00"
+	}
+
+	it, release := e.iterator(from)
+	defer release()
+
+	now := time.Now().UnixNano()
+	keys := []string{}
+	for len(keys) < max(count, 1) {
+		item, ok := it.next()
+		if !ok {
+			if err := it.error(); err != nil {
+				return nil, 0, fmt.Errorf("lsm error: can't scan keys: %w", err)
+			}
+			return keys, 0, nil
+		}
+		if item.live(now) {
+			keys = append(keys, item.key)
+		}
+	}
+
+	return keys, e.newCursor(keys[len(keys)-1]), nil
+}
+
+// Generation returns generation of memtable which writes are applied to now.
+func (e *Engine) Generation() uint64 {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	return e.generation
+}
+
+// FlushedGeneration returns generation which all memtables are flushed up to.
+func (e *Engine) FlushedGeneration() uint64 {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	return e.flushedGeneration
+}
+
+// Flushed returns channel which is signaled after memtable is flushed to table.
+func (e *Engine) Flushed() <-chan struct{} {
+	return e.flushedCh
+}
+
+// Close stops background work and flushes memtable, engine must not be used after it.
+func (e *Engine) Close() error {
+	close(e.done)
+	e.wg.Wait()
+
+	e.mx.Lock()
+	if len(e.mem.kv) > 0 {
+		if e.flushing == nil {
+			e.flushing = e.mem
+		} else {
+			// memtable is newer than the one being flushed, so it is merged on top of it
+			for key, en := range e.mem.kv {
+				e.flushing.set(key, en)
+			}
+		}
+		e.flushingGeneration = e.generation
+		e.generation++
+		e.mem = newMemtable()
+	}
+	e.mx.Unlock()
+
+	err := e.flush()
+	e.closeTables()
+	return err
+}
+
+func (e *Engine) closeTables() {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	for _, t := range e.tables {
+		t.release()
+	}
+	e.tables = nil
+}
+
+// write puts entry to memtable under lock of key, so it doesn't interleave with read-modify-write operations.
+func (e *Engine) write(key string, en entry) {
+	mx := e.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	e.put(key, en)
+}
+
+// put puts entry to memtable, full memtable is passed to background flush.
+func (e *Engine) put(key string, en entry) {
+	defer e.mx.Unlock()
+	e.mx.Lock()
+
+	e.mem.set(key, en)
+	if e.mem.size < e.memtableSize {
+		return
+	}
+
+	// only one memtable is flushed at once, writers wait for it, so disk sets pace of writes
+	for e.flushing != nil {
+		e.flushed.Wait()
+	}
+	// memtable may be passed to flush by another writer while this one waited
+	if e.mem.size < e.memtableSize {
+		return
+	}
+	e.flushing = e.mem
+	e.flushingGeneration = e.generation
+	e.generation++
+	e.mem = newMemtable()
+
+	select {
+	case e.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// lookup returns the newest version of key, tables are read without lock.
+func (e *Engine) lookup(key string) (entry, bool) {
+	e.mx.RLock()
+	if en, ok := e.mem.get(key); ok {
+		e.mx.RUnlock()
+		return en, true
+	}
+	if e.flushing != nil {
+		if en, ok := e.flushing.get(key); ok {
+			e.mx.RUnlock()
+			return en, true
+		}
+	}
+	tables := e.acquireTables()
+	e.mx.RUnlock()
+	defer releaseTables(tables)
+
+	for _, t := range tables {
+		en, ok, err := t.get(key)
+		if err != nil {
+			e.logger.Error("failed to read table", zap.String("path", t.path), zap.Error(err))
+			continue
+		}
+		if ok {
+			return en, true
+		}
+	}
+
+	return entry{}, false
+}
+
+// iterator merges copies of memtables and tables, tables are released by returned func.
+func (e *Engine) iterator(from string) (*mergeIterator, func()) {
+	e.mx.RLock()
+	sources := []iterator{&memIterator{items: e.mem.sorted(from)}}
+	if e.flushing != nil {
+		sources = append(sources, &memIterator{items: e.flushing.sorted(from)})
+	}
+	tables := e.acquireTables()
+	e.mx.RUnlock()
+
+	for _, t := range tables {
+		sources = append(sources, t.iterator(from))
+	}
+
+	return &mergeIterator{sources: sources}, func() {
+		releaseTables(tables)
+	}
+}
+
+// acquireTables must be called under lock.
+func (e *Engine) acquireTables() []*table {
+	tables := slices.Clone(e.tables)
+	for _, t := range tables {
+		t.acquire()
+	}
+	return tables
+}
+
+func releaseTables(tables []*table) {
+	for _, t := range tables {
+		t.release()
+	}
+}
+
+func (e *Engine) newCursor(last string) uint64 {
+	defer e.cursorsMx.Unlock()
+	e.cursorsMx.Lock()
+
+	e.nextCursor++
+	if e.nextCursor == 0 {
+		e.nextCursor++
+	}
+	e.cursors[e.nextCursor] = last
+	e.cursorOrder = append(e.cursorOrder, e.nextCursor)
+
+	if len(e.cursorOrder) > maxScanCursors {
+		delete(e.cursors, e.cursorOrder[0])
+		e.cursorOrder = e.cursorOrder[1:]
+	}
+
+	return e.nextCursor
+}
+
+// lookupCursor returns key which iteration continues after.
+func (e *Engine) lookupCursor(cursor uint64) (string, bool) {
+	defer e.cursorsMx.Unlock()
+	e.cursorsMx.Lock()
+
+	last, ok := e.cursors[cursor]
+	return last, ok
+}
+
+func (e *Engine) keyLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &e.keyLocks[h.Sum32()%keyLocksCount]
+}
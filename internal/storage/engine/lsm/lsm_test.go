@@ -0,0 +1,253 @@
+package lsm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestEngine(t *testing.T, dir string) *Engine {
+	t.Helper()
+
+	e, err := NewEngine(config.ConfigEngine{
+		DataDirectory:       dir,
+		MemtableSize:        config.DataSize(4 * config.KB),
+		CompactionThreshold: 2,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	return e
+}
+
+func tablesCount(e *Engine) int {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+	return len(e.tables)
+}
+
+func TestEngine_SetGetDel(t *testing.T) {
+	e := newTestEngine(t, t.TempDir())
+	defer e.Close()
+
+	// enough writes to flush many memtables and compact tables
+	for i := 0; i < 2000; i++ {
+		e.Set(fmt.Sprintf("key:%d", i%500), fmt.Sprintf("value:%d", i))
+	}
+	for i := 0; i < 500; i += 2 {
+		e.Del(fmt.Sprintf("key:%d", i))
+	}
+
+	require.Eventually(t, func() bool {
+		return tablesCount(e) < 10
+	}, 5*time.Second, 10*time.Millisecond, "tables are not compacted")
+
+	for i := 0; i < 500; i++ {
+		value, ok := e.Get(fmt.Sprintf("key:%d", i))
+		if i%2 == 0 {
+			assert.False(t, ok, "key:%d is deleted", i)
+			continue
+		}
+		assert.True(t, ok, "key:%d exists", i)
+		assert.Equal(t, fmt.Sprintf("value:%d", 1500+i), value)
+	}
+}
+
+func TestEngine_Expiration(t *testing.T) {
+	e := newTestEngine(t, t.TempDir())
+	defer e.Close()
+
+	e.SetWithExpiration("short", "value", time.Now().Add(10*time.Millisecond))
+	e.SetWithExpiration("long", "value", time.Now().Add(time.Hour))
+	e.Set("forever", "value")
+
+	expireAt, ok := e.ExpireAt("forever")
+	require.True(t, ok)
+	require.True(t, expireAt.IsZero())
+
+	require.True(t, e.Persist("long"))
+	require.False(t, e.Persist("long"))
+	require.True(t, e.Expire("forever", time.Now().Add(10*time.Millisecond)))
+	require.False(t, e.Expire("missing", time.Now().Add(time.Hour)))
+
+	time.Sleep(20 * time.Millisecond)
+	_, ok = e.Get("short")
+	require.False(t, ok)
+	_, ok = e.Get("forever")
+	require.False(t, ok)
+	_, ok = e.Get("long")
+	require.True(t, ok)
+
+	assert.Equal(t, 2, e.DeleteExpired(), "both expired keys are replaced by tombstones")
+}
+
+func TestEngine_DeleteExpiredWaitsForKeyLock(t *testing.T) {
+	e := newTestEngine(t, t.TempDir())
+	defer e.Close()
+
+	e.SetWithExpiration("key", "value", time.Now().Add(10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	// writer which read key before it expired holds its lock, e.g. Persist
+	mx := e.keyLock("key")
+	mx.Lock()
+
+	deleted := make(chan int)
+	go func() {
+		deleted <- e.DeleteExpired()
+	}()
+
+	select {
+	case <-deleted:
+		t.Fatal("DeleteExpired() deleted key locked by writer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	e.put("key", entry{value: "value"})
+	mx.Unlock()
+
+	assert.Equal(t, 0, <-deleted, "key written back by writer isn't expired anymore")
+	value, ok := e.Get("key")
+	require.True(t, ok)
+	assert.Equal(t, "value", value)
+}
+
+func TestEngine_StatsKeysIsEstimate(t *testing.T) {
+	e := newTestEngine(t, t.TempDir())
+	defer e.Close()
+
+	e.Set("key", "first")
+	e.Set("key", "second")
+	e.Del("other")
+
+	stats := e.Stats()
+	assert.True(t, stats.KeysEstimated)
+	assert.Equal(t, 2, stats.Keys, "tombstone is counted until compaction drops it")
+}
+
+func TestEngine_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	e := newTestEngine(t, dir)
+	for i := 0; i < 300; i++ {
+		e.Set(fmt.Sprintf("key:%03d", i), "value")
+	}
+	e.Del("key:000")
+	e.SetWithExpiration("key:001", "value", time.Now().Add(time.Hour))
+	require.NoError(t, e.Close())
+
+	e = newTestEngine(t, dir)
+	defer e.Close()
+
+	_, ok := e.Get("key:000")
+	assert.False(t, ok)
+	value, ok := e.Get("key:299")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+	expireAt, ok := e.ExpireAt("key:001")
+	assert.True(t, ok)
+	assert.False(t, expireAt.IsZero())
+
+	var keys []string
+	require.NoError(t, e.Dump(func(item engine.Item) error {
+		keys = append(keys, item.Key)
+		return nil
+	}))
+	require.Len(t, keys, 299)
+	assert.Equal(t, "key:001", keys[0])
+	assert.Equal(t, "key:299", keys[298])
+}
+
+func TestEngine_OpenRemovesMergedTables(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTable(t, dir, 1, 1, map[string]entry{"a": {value: "old"}})
+	writeTestTable(t, dir, 2, 2, map[string]entry{"b": {value: "old"}})
+	// compaction wrote merged table but was interrupted before merged tables were removed
+	writeTestTable(t, dir, 1, 2, map[string]entry{"a": {value: "new"}})
+	writeTestTable(t, dir, 3, 3, map[string]entry{"c": {value: "new"}})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, tableName(4, 4)+tmpSuffix), []byte("partial"), 0o644))
+
+	e := newTestEngine(t, dir)
+	defer e.Close()
+
+	require.Equal(t, 2, tablesCount(e))
+	value, _ := e.Get("a")
+	assert.Equal(t, "new", value)
+	_, ok := e.Get("b")
+	assert.False(t, ok)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestEngine_ScanConcurrentWrites(t *testing.T) {
+	e := newTestEngine(t, t.TempDir())
+	defer e.Close()
+
+	const stableKeys = 500
+	for i := 0; i < stableKeys; i++ {
+		e.Set(fmt.Sprintf("stable:%d", i), "value")
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			key := fmt.Sprintf("volatile:%d", i%300)
+			if i%2 == 0 {
+				e.Set(key, "value")
+			} else {
+				e.Del(key)
+			}
+		}
+	}()
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		keys, next, err := e.Scan(cursor, 7)
+		require.NoError(t, err)
+		for _, key := range keys {
+			seen[key]++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < stableKeys; i++ {
+		key := fmt.Sprintf("stable:%d", i)
+		assert.Equal(t, 1, seen[key], "key %s", key)
+	}
+
+	_, _, err := e.Scan(12345, 10)
+	assert.ErrorIs(t, err, engine.ErrUnknownCursor, "unknown cursor must not finish iteration silently")
+
+	// cursor may be used again, e.g. when reply to client is lost
+	first, cursor, err := e.Scan(0, 5)
+	require.NoError(t, err)
+	page, _, err := e.Scan(cursor, 5)
+	require.NoError(t, err)
+	again, _, err := e.Scan(cursor, 5)
+	require.NoError(t, err)
+	assert.Equal(t, page, again)
+	assert.NotContains(t, page, first[len(first)-1])
+}
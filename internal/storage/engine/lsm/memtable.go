@@ -0,0 +1,74 @@
+package lsm
+
+import (
+	"sort"
+)
+
+// estimated memory used by memtable entry besides key and value
+const entryOverhead = 64
+
+// entry is a version of key, tombstone hides older versions of key in tables until compaction drops them.
+type entry struct {
+	value string
+	// unix time in nanoseconds, zero means key never expires
+	expireAt  int64
+	tombstone bool
+}
+
+// live reports whether entry is a value which isn't expired.
+func (en entry) live(now int64) bool {
+	return !en.tombstone && (en.expireAt == 0 || en.expireAt > now)
+}
+
+// memtable keeps the newest writes in memory until it is flushed to table.
+type memtable struct {
+	kv map[string]entry
+	// keys with expiration, active expiration samples them
+	expires map[string]struct{}
+	size    int64
+}
+
+func newMemtable() *memtable {
+	return &memtable{
+		kv:      make(map[string]entry),
+		expires: make(map[string]struct{}),
+	}
+}
+
+func (m *memtable) set(key string, en entry) {
+	if old, ok := m.kv[key]; ok {
+		m.size -= int64(len(key) + len(old.value) + entryOverhead)
+	}
+	m.kv[key] = en
+	m.size += int64(len(key) + len(en.value) + entryOverhead)
+
+	if en.expireAt != 0 && !en.tombstone {
+		m.expires[key] = struct{}{}
+	} else {
+		delete(m.expires, key)
+	}
+}
+
+func (m *memtable) get(key string) (entry, bool) {
+	en, ok := m.kv[key]
+	return en, ok
+}
+
+// sorted returns entries with keys not less than from in key order.
+func (m *memtable) sorted(from string) []keyEntry {
+	items := make([]keyEntry, 0, len(m.kv))
+	for key, en := range m.kv {
+		if key >= from {
+			items = append(items, keyEntry{key: key, entry: en})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].key < items[j].key
+	})
+	return items
+}
+
+type keyEntry struct {
+	key string
+	entry
+}
@@ -0,0 +1,460 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+)
+
+const (
+	tableSuffix = ".sst"
+	tmpSuffix   = ".tmp"
+
+	// index has one key per block, so block is the most that is read from disk to get a key
+	blockSize = 4 * config.KB
+	bufSize   = 64 * config.KB
+
+	// data end, bloom offset, entries count, crc32 of index and bloom filter, magic
+	footerSize = 8 + 8 + 8 + 4 + 8
+
+	flagTombstone = 1
+)
+
+// tableMagic identifies table file format, the last byte is format version
+var tableMagic = [8]byte{'S', 'K', 'V', 'T', 'A', 'B', 'L', 1}
+
+// table is immutable sorted file of entries, it is named by range of IDs of flushed memtables it contains.
+//
+// File format: entries sorted by key, each is key prefixed with its length (uvarint), flags byte,
+// expiration time in unix nanoseconds (varint) and value prefixed with its length (uvarint);
+// sparse index of the first key of every block with its offset; bloom filter of all keys; footer.
+type table struct {
+	first, last uint64
+	path        string
+	file        *os.File
+	size        int64
+	count       uint64
+	index       []indexEntry
+	// offset of index, data blocks end there
+	dataEnd int64
+	bloom   bloom
+
+	// engine holds one reference while table is in use, readers hold others while they read it
+	refs atomic.Int32
+	// obsolete table is removed when the last reader releases it
+	obsolete atomic.Bool
+}
+
+type indexEntry struct {
+	key    string
+	offset int64
+}
+
+func tableName(first, last uint64) string {
+	return fmt.Sprintf("%020d-%020d%s", first, last, tableSuffix)
+}
+
+func parseTableName(name string) (uint64, uint64, bool) {
+	ids, ok := strings.CutSuffix(name, tableSuffix)
+	if !ok {
+		return 0, 0, false
+	}
+	firstID, lastID, ok := strings.Cut(ids, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseUint(firstID, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	last, err := strconv.ParseUint(lastID, 10, 64)
+	if err != nil || last < first {
+		return 0, 0, false
+	}
+	return first, last, true
+}
+
+// tableWriter writes entries in key order to temporary file, it is renamed when table is complete.
+type tableWriter struct {
+	dir         string
+	first, last uint64
+	file        *os.File
+	writer      *bufio.Writer
+	offset      int64
+	blockStart  int64
+	index       []indexEntry
+	hashes      []uint64
+	buf         []byte
+}
+
+func createTable(dir string, first, last uint64) (*tableWriter, error) {
+	file, err := os.Create(filepath.Join(dir, tableName(first, last)+tmpSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("lsm error: can't create table: %w", err)
+	}
+
+	return &tableWriter{
+		dir:    dir,
+		first:  first,
+		last:   last,
+		file:   file,
+		writer: bufio.NewWriterSize(file, bufSize),
+	}, nil
+}
+
+func (w *tableWriter) add(key string, en entry) error {
+	if len(w.index) == 0 || w.offset-w.blockStart >= blockSize {
+		w.index = append(w.index, indexEntry{key: key, offset: w.offset})
+		w.blockStart = w.offset
+	}
+
+	w.buf = encodeEntry(w.buf[:0], key, en)
+	if _, err := w.writer.Write(w.buf); err != nil {
+		return fmt.Errorf("lsm error: can't write table: %w", err)
+	}
+	w.offset += int64(len(w.buf))
+	w.hashes = append(w.hashes, bloomHash(key))
+
+	return nil
+}
+
+// finish writes index, bloom filter and footer, syncs file and renames it, then table is opened for reading.
+func (w *tableWriter) finish() (*table, error) {
+	defer os.Remove(w.file.Name())
+	defer w.file.Close()
+
+	var meta []byte
+	meta = binary.AppendUvarint(meta, uint64(len(w.index)))
+	for _, item := range w.index {
+		meta = binary.AppendUvarint(meta, uint64(len(item.key)))
+		meta = append(meta, item.key...)
+		meta = binary.AppendUvarint(meta, uint64(item.offset))
+	}
+	bloomOffset := w.offset + int64(len(meta))
+	meta = newBloom(w.hashes).encode(meta)
+
+	footer := binary.BigEndian.AppendUint64(nil, uint64(w.offset))
+	footer = binary.BigEndian.AppendUint64(footer, uint64(bloomOffset))
+	footer = binary.BigEndian.AppendUint64(footer, uint64(len(w.hashes)))
+	footer = binary.BigEndian.AppendUint32(footer, crc32.ChecksumIEEE(meta))
+	footer = append(footer, tableMagic[:]...)
+
+	if _, err := w.writer.Write(meta); err != nil {
+		return nil, fmt.Errorf("lsm error: can't write table: %w", err)
+	}
+	if _, err := w.writer.Write(footer); err != nil {
+		return nil, fmt.Errorf("lsm error: can't write table: %w", err)
+	}
+	if err := w.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("lsm error: can't write table: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return nil, fmt.Errorf("lsm error: can't sync table: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return nil, fmt.Errorf("lsm error: can't close table: %w", err)
+	}
+
+	path := filepath.Join(w.dir, tableName(w.first, w.last))
+	if err := os.Rename(w.file.Name(), path); err != nil {
+		return nil, fmt.Errorf("lsm error: can't rename table: %w", err)
+	}
+	if err := syncDir(w.dir); err != nil {
+		return nil, fmt.Errorf("lsm error: can't sync data directory: %w", err)
+	}
+
+	return openTable(path, w.first, w.last)
+}
+
+// abort removes incomplete table.
+func (w *tableWriter) abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// openTable reads index and bloom filter of table, data blocks are read on demand.
+func openTable(path string, first, last uint64) (*table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("lsm error: can't open table: %w", err)
+	}
+
+	t, err := readTableMeta(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("table %s: %w", path, err)
+	}
+
+	t.first, t.last, t.path = first, last, path
+	t.refs.Store(1)
+	return t, nil
+}
+
+func readTableMeta(file *os.File) (*table, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("lsm error: can't stat table: %w", err)
+	}
+	size := info.Size()
+	if size < footerSize {
+		return nil, fmt.Errorf("%w: file is too short", ErrCorruptedTable)
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := file.ReadAt(footer, size-footerSize); err != nil {
+		return nil, fmt.Errorf("lsm error: can't read table: %w", err)
+	}
+	if [8]byte(footer[footerSize-8:]) != tableMagic {
+		return nil, fmt.Errorf("%w: unknown format", ErrCorruptedTable)
+	}
+
+	dataEnd := int64(binary.BigEndian.Uint64(footer[0:8]))
+	bloomOffset := int64(binary.BigEndian.Uint64(footer[8:16]))
+	count := binary.BigEndian.Uint64(footer[16:24])
+	checksum := binary.BigEndian.Uint32(footer[24:28])
+	if dataEnd < 0 || bloomOffset < dataEnd || bloomOffset > size-footerSize {
+		return nil, fmt.Errorf("%w: invalid footer", ErrCorruptedTable)
+	}
+
+	meta := make([]byte, size-footerSize-dataEnd)
+	if _, err := file.ReadAt(meta, dataEnd); err != nil {
+		return nil, fmt.Errorf("lsm error: can't read table: %w", err)
+	}
+	if crc32.ChecksumIEEE(meta) != checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptedTable)
+	}
+
+	index, err := decodeIndex(meta[:bloomOffset-dataEnd])
+	if err != nil {
+		return nil, err
+	}
+	filter, err := decodeBloom(meta[bloomOffset-dataEnd:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &table{
+		file:    file,
+		size:    size,
+		count:   count,
+		index:   index,
+		dataEnd: dataEnd,
+		bloom:   filter,
+	}, nil
+}
+
+func decodeIndex(buf []byte) ([]indexEntry, error) {
+	reader := bytes.NewReader(buf)
+	n, err := binary.ReadUvarint(reader)
+	if err != nil || n > uint64(len(buf)) {
+		return nil, fmt.Errorf("%w: invalid index", ErrCorruptedTable)
+	}
+
+	index := make([]indexEntry, 0, n)
+	for i := uint64(0); i < n; i++ {
+		key, err := readString(reader)
+		if err != nil {
+			return nil, err
+		}
+		offset, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid index", ErrCorruptedTable)
+		}
+		index = append(index, indexEntry{key: key, offset: int64(offset)})
+	}
+
+	return index, nil
+}
+
+// acquire takes reference of table, so it isn't closed while it is read.
+func (t *table) acquire() {
+	t.refs.Add(1)
+}
+
+// release drops reference, the last one closes table and removes it if it is obsolete.
+func (t *table) release() {
+	if t.refs.Add(-1) > 0 {
+		return
+	}
+	t.file.Close()
+	if t.obsolete.Load() {
+		os.Remove(t.path)
+	}
+}
+
+// get reads block which may contain key, bloom filter skips most of tables without key.
+func (t *table) get(key string) (entry, bool, error) {
+	if !t.bloom.mayContain(key) {
+		return entry{}, false, nil
+	}
+
+	i := sort.Search(len(t.index), func(i int) bool {
+		return t.index[i].key > key
+	}) - 1
+	if i < 0 {
+		return entry{}, false, nil
+	}
+
+	end := t.dataEnd
+	if i+1 < len(t.index) {
+		end = t.index[i+1].offset
+	}
+	block := make([]byte, end-t.index[i].offset)
+	if _, err := t.file.ReadAt(block, t.index[i].offset); err != nil {
+		return entry{}, false, fmt.Errorf("lsm error: can't read table: %w", err)
+	}
+
+	reader := bytes.NewReader(block)
+	for {
+		k, en, err := decodeEntry(reader)
+		if errors.Is(err, io.EOF) {
+			return entry{}, false, nil
+		}
+		if err != nil {
+			return entry{}, false, err
+		}
+		if k == key {
+			return en, true, nil
+		}
+		if k > key {
+			return entry{}, false, nil
+		}
+	}
+}
+
+// iterator returns entries with keys not less than from, it starts reading from block of from.
+func (t *table) iterator(from string) *tableIterator {
+	var start int64
+	if i := sort.Search(len(t.index), func(i int) bool {
+		return t.index[i].key > from
+	}) - 1; i >= 0 {
+		start = t.index[i].offset
+	}
+
+	it := &tableIterator{
+		reader: bufio.NewReaderSize(io.NewSectionReader(t.file, start, t.dataEnd-start), bufSize),
+	}
+	it.next()
+	for it.valid() && it.cur.key < from {
+		it.next()
+	}
+	return it
+}
+
+type tableIterator struct {
+	reader *bufio.Reader
+	cur    keyEntry
+	ok     bool
+	err    error
+}
+
+func (it *tableIterator) valid() bool {
+	return it.ok
+}
+
+func (it *tableIterator) item() keyEntry {
+	return it.cur
+}
+
+func (it *tableIterator) next() {
+	key, en, err := decodeEntry(it.reader)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			it.err = err
+		}
+		it.ok = false
+		return
+	}
+	it.cur = keyEntry{key: key, entry: en}
+	it.ok = true
+}
+
+func (it *tableIterator) error() error {
+	return it.err
+}
+
+func encodeEntry(buf []byte, key string, en entry) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+
+	var flags byte
+	if en.tombstone {
+		flags |= flagTombstone
+	}
+	buf = append(buf, flags)
+	buf = binary.AppendVarint(buf, en.expireAt)
+
+	buf = binary.AppendUvarint(buf, uint64(len(en.value)))
+	return append(buf, en.value...)
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// decodeEntry returns io.EOF only if there are no more entries.
+func decodeEntry(reader byteReader) (string, entry, error) {
+	size, err := binary.ReadUvarint(reader)
+	if errors.Is(err, io.EOF) {
+		return "", entry{}, io.EOF
+	}
+	if err != nil {
+		return "", entry{}, fmt.Errorf("%w: invalid length", ErrCorruptedTable)
+	}
+	key := make([]byte, size)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return "", entry{}, fmt.Errorf("%w: unexpected end of file", ErrCorruptedTable)
+	}
+
+	flags, err := reader.ReadByte()
+	if err != nil {
+		return "", entry{}, fmt.Errorf("%w: unexpected end of file", ErrCorruptedTable)
+	}
+	expireAt, err := binary.ReadVarint(reader)
+	if err != nil {
+		return "", entry{}, fmt.Errorf("%w: invalid expiration time", ErrCorruptedTable)
+	}
+	value, err := readString(reader)
+	if err != nil {
+		return "", entry{}, err
+	}
+
+	return string(key), entry{value: value, expireAt: expireAt, tombstone: flags&flagTombstone != 0}, nil
+}
+
+func readString(reader byteReader) (string, error) {
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid length", ErrCorruptedTable)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", fmt.Errorf("%w: unexpected end of file", ErrCorruptedTable)
+	}
+
+	return string(buf), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,110 @@
+package lsm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestTable(t *testing.T, dir string, first, last uint64, entries map[string]entry) *table {
+	t.Helper()
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w, err := createTable(dir, first, last)
+	require.NoError(t, err)
+	for _, key := range keys {
+		require.NoError(t, w.add(key, entries[key]))
+	}
+	table, err := w.finish()
+	require.NoError(t, err)
+	t.Cleanup(table.release)
+
+	return table
+}
+
+func TestTable_GetIterator(t *testing.T) {
+	entries := make(map[string]entry)
+	for i := 0; i < 2000; i++ {
+		entries[fmt.Sprintf("key:%05d", i*2)] = entry{value: fmt.Sprintf("value:%d", i), expireAt: int64(i)}
+	}
+	entries["deleted"] = entry{tombstone: true}
+
+	table := writeTestTable(t, t.TempDir(), 1, 1, entries)
+	require.Greater(t, len(table.index), 1, "table has many blocks")
+	require.Equal(t, uint64(2001), table.count)
+
+	for key, want := range entries {
+		got, ok, err := table.get(key)
+		require.NoError(t, err)
+		require.True(t, ok, "key %s", key)
+		require.Equal(t, want, got)
+	}
+	for _, key := range []string{"a", "key:00001", "key:03999", "zzz"} {
+		_, ok, err := table.get(key)
+		require.NoError(t, err)
+		assert.False(t, ok, "key %s", key)
+	}
+
+	it := table.iterator("key:03001")
+	var keys []string
+	for ; it.valid(); it.next() {
+		keys = append(keys, it.item().key)
+	}
+	require.NoError(t, it.error())
+	require.Len(t, keys, 499)
+	assert.Equal(t, "key:03002", keys[0])
+	assert.Equal(t, "key:03998", keys[498])
+}
+
+func TestOpenTable_Corrupted(t *testing.T) {
+	dir := t.TempDir()
+	table := writeTestTable(t, dir, 1, 1, map[string]entry{"key": {value: "value"}})
+
+	data, err := os.ReadFile(table.path)
+	require.NoError(t, err)
+	// damage bloom filter which precedes footer
+	data[len(data)-footerSize-1] ^= 0xff
+	path := filepath.Join(dir, tableName(2, 2))
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = openTable(path, 2, 2)
+	assert.ErrorIs(t, err, ErrCorruptedTable)
+
+	require.NoError(t, os.WriteFile(path, data[:10], 0o644))
+	_, err = openTable(path, 2, 2)
+	assert.ErrorIs(t, err, ErrCorruptedTable)
+}
+
+func TestBloom(t *testing.T) {
+	var hashes []uint64
+	for i := 0; i < 1000; i++ {
+		hashes = append(hashes, bloomHash(fmt.Sprintf("key:%d", i)))
+	}
+	b := newBloom(hashes)
+
+	for i := 0; i < 1000; i++ {
+		require.True(t, b.mayContain(fmt.Sprintf("key:%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if b.mayContain(fmt.Sprintf("missing:%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300, "false positive rate is about 1%")
+
+	decoded, err := decodeBloom(b.encode(nil))
+	require.NoError(t, err)
+	assert.Equal(t, b, decoded)
+}
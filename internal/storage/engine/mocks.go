@@ -5,6 +5,8 @@
 package engine
 
 import (
+	"time"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -75,6 +77,218 @@ func (_c *MockEngine_Del_Call) RunAndReturn(run func(key string)) *MockEngine_De
 	return _c
 }
 
+// DeleteExpired provides a mock function for the type MockEngine
+func (_mock *MockEngine) DeleteExpired() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpired")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// MockEngine_DeleteExpired_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteExpired'
+type MockEngine_DeleteExpired_Call struct {
+	*mock.Call
+}
+
+// DeleteExpired is a helper method to define mock.On call
+func (_e *MockEngine_Expecter) DeleteExpired() *MockEngine_DeleteExpired_Call {
+	return &MockEngine_DeleteExpired_Call{Call: _e.mock.On("DeleteExpired")}
+}
+
+func (_c *MockEngine_DeleteExpired_Call) Run(run func()) *MockEngine_DeleteExpired_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockEngine_DeleteExpired_Call) Return(n int) *MockEngine_DeleteExpired_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *MockEngine_DeleteExpired_Call) RunAndReturn(run func() int) *MockEngine_DeleteExpired_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Dump provides a mock function for the type MockEngine
+func (_mock *MockEngine) Dump(fn func(Item) error) error {
+	ret := _mock.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Dump")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(func(Item) error) error); ok {
+		r0 = returnFunc(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockEngine_Dump_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Dump'
+type MockEngine_Dump_Call struct {
+	*mock.Call
+}
+
+// Dump is a helper method to define mock.On call
+//   - fn func(Item) error
+func (_e *MockEngine_Expecter) Dump(fn interface{}) *MockEngine_Dump_Call {
+	return &MockEngine_Dump_Call{Call: _e.mock.On("Dump", fn)}
+}
+
+func (_c *MockEngine_Dump_Call) Run(run func(fn func(Item) error)) *MockEngine_Dump_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 func(Item) error
+		if args[0] != nil {
+			arg0 = args[0].(func(Item) error)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_Dump_Call) Return(err error) *MockEngine_Dump_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockEngine_Dump_Call) RunAndReturn(run func(fn func(Item) error) error) *MockEngine_Dump_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Expire provides a mock function for the type MockEngine
+func (_mock *MockEngine) Expire(key string, expireAt time.Time) bool {
+	ret := _mock.Called(key, expireAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Expire")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(string, time.Time) bool); ok {
+		r0 = returnFunc(key, expireAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// MockEngine_Expire_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Expire'
+type MockEngine_Expire_Call struct {
+	*mock.Call
+}
+
+// Expire is a helper method to define mock.On call
+//   - key string
+//   - expireAt time.Time
+func (_e *MockEngine_Expecter) Expire(key interface{}, expireAt interface{}) *MockEngine_Expire_Call {
+	return &MockEngine_Expire_Call{Call: _e.mock.On("Expire", key, expireAt)}
+}
+
+func (_c *MockEngine_Expire_Call) Run(run func(key string, expireAt time.Time)) *MockEngine_Expire_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 time.Time
+		if args[1] != nil {
+			arg1 = args[1].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_Expire_Call) Return(b bool) *MockEngine_Expire_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *MockEngine_Expire_Call) RunAndReturn(run func(key string, expireAt time.Time) bool) *MockEngine_Expire_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExpireAt provides a mock function for the type MockEngine
+func (_mock *MockEngine) ExpireAt(key string) (time.Time, bool) {
+	ret := _mock.Called(key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExpireAt")
+	}
+
+	var r0 time.Time
+	var r1 bool
+	if returnFunc, ok := ret.Get(0).(func(string) (time.Time, bool)); ok {
+		return returnFunc(key)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) time.Time); ok {
+		r0 = returnFunc(key)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = returnFunc(key)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+	return r0, r1
+}
+
+// MockEngine_ExpireAt_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExpireAt'
+type MockEngine_ExpireAt_Call struct {
+	*mock.Call
+}
+
+// ExpireAt is a helper method to define mock.On call
+//   - key string
+func (_e *MockEngine_Expecter) ExpireAt(key interface{}) *MockEngine_ExpireAt_Call {
+	return &MockEngine_ExpireAt_Call{Call: _e.mock.On("ExpireAt", key)}
+}
+
+func (_c *MockEngine_ExpireAt_Call) Run(run func(key string)) *MockEngine_ExpireAt_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_ExpireAt_Call) Return(time1 time.Time, b bool) *MockEngine_ExpireAt_Call {
+	_c.Call.Return(time1, b)
+	return _c
+}
+
+func (_c *MockEngine_ExpireAt_Call) RunAndReturn(run func(key string) (time.Time, bool)) *MockEngine_ExpireAt_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function for the type MockEngine
 func (_mock *MockEngine) Get(key string) (string, bool) {
 	ret := _mock.Called(key)
@@ -135,6 +349,205 @@ func (_c *MockEngine_Get_Call) RunAndReturn(run func(key string) (string, bool))
 	return _c
 }
 
+// Persist provides a mock function for the type MockEngine
+func (_mock *MockEngine) Persist(key string) bool {
+	ret := _mock.Called(key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Persist")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = returnFunc(key)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// MockEngine_Persist_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Persist'
+type MockEngine_Persist_Call struct {
+	*mock.Call
+}
+
+// Persist is a helper method to define mock.On call
+//   - key string
+func (_e *MockEngine_Expecter) Persist(key interface{}) *MockEngine_Persist_Call {
+	return &MockEngine_Persist_Call{Call: _e.mock.On("Persist", key)}
+}
+
+func (_c *MockEngine_Persist_Call) Run(run func(key string)) *MockEngine_Persist_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_Persist_Call) Return(b bool) *MockEngine_Persist_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *MockEngine_Persist_Call) RunAndReturn(run func(key string) bool) *MockEngine_Persist_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Reserve provides a mock function for the type MockEngine
+func (_mock *MockEngine) Reserve(key string, valueSize int, evict EvictFunc) ([]string, error) {
+	ret := _mock.Called(key, valueSize, evict)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reserve")
+	}
+
+	var r0 []string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string, int, EvictFunc) ([]string, error)); ok {
+		return returnFunc(key, valueSize, evict)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string, int, EvictFunc) []string); ok {
+		r0 = returnFunc(key, valueSize, evict)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string, int, EvictFunc) error); ok {
+		r1 = returnFunc(key, valueSize, evict)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockEngine_Reserve_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Reserve'
+type MockEngine_Reserve_Call struct {
+	*mock.Call
+}
+
+// Reserve is a helper method to define mock.On call
+//   - key string
+//   - valueSize int
+//   - evict EvictFunc
+func (_e *MockEngine_Expecter) Reserve(key interface{}, valueSize interface{}, evict interface{}) *MockEngine_Reserve_Call {
+	return &MockEngine_Reserve_Call{Call: _e.mock.On("Reserve", key, valueSize, evict)}
+}
+
+func (_c *MockEngine_Reserve_Call) Run(run func(key string, valueSize int, evict EvictFunc)) *MockEngine_Reserve_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 int
+		if args[1] != nil {
+			arg1 = args[1].(int)
+		}
+		var arg2 EvictFunc
+		if args[2] != nil {
+			arg2 = args[2].(EvictFunc)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_Reserve_Call) Return(strings []string, err error) *MockEngine_Reserve_Call {
+	_c.Call.Return(strings, err)
+	return _c
+}
+
+func (_c *MockEngine_Reserve_Call) RunAndReturn(run func(key string, valueSize int, evict EvictFunc) ([]string, error)) *MockEngine_Reserve_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Scan provides a mock function for the type MockEngine
+func (_mock *MockEngine) Scan(cursor uint64, count int) ([]string, uint64, error) {
+	ret := _mock.Called(cursor, count)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Scan")
+	}
+
+	var r0 []string
+	var r1 uint64
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(uint64, int) ([]string, uint64, error)); ok {
+		return returnFunc(cursor, count)
+	}
+	if returnFunc, ok := ret.Get(0).(func(uint64, int) []string); ok {
+		r0 = returnFunc(cursor, count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(uint64, int) uint64); ok {
+		r1 = returnFunc(cursor, count)
+	} else {
+		r1 = ret.Get(1).(uint64)
+	}
+	if returnFunc, ok := ret.Get(2).(func(uint64, int) error); ok {
+		r2 = returnFunc(cursor, count)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// MockEngine_Scan_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Scan'
+type MockEngine_Scan_Call struct {
+	*mock.Call
+}
+
+// Scan is a helper method to define mock.On call
+//   - cursor uint64
+//   - count int
+func (_e *MockEngine_Expecter) Scan(cursor interface{}, count interface{}) *MockEngine_Scan_Call {
+	return &MockEngine_Scan_Call{Call: _e.mock.On("Scan", cursor, count)}
+}
+
+func (_c *MockEngine_Scan_Call) Run(run func(cursor uint64, count int)) *MockEngine_Scan_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 uint64
+		if args[0] != nil {
+			arg0 = args[0].(uint64)
+		}
+		var arg1 int
+		if args[1] != nil {
+			arg1 = args[1].(int)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_Scan_Call) Return(strings []string, v uint64, err error) *MockEngine_Scan_Call {
+	_c.Call.Return(strings, v, err)
+	return _c
+}
+
+func (_c *MockEngine_Scan_Call) RunAndReturn(run func(cursor uint64, count int) ([]string, uint64, error)) *MockEngine_Scan_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function for the type MockEngine
 func (_mock *MockEngine) Set(key string, value string) {
 	_mock.Called(key, value)
@@ -180,3 +593,99 @@ func (_c *MockEngine_Set_Call) RunAndReturn(run func(key string, value string))
 	_c.Run(run)
 	return _c
 }
+
+// SetWithExpiration provides a mock function for the type MockEngine
+func (_mock *MockEngine) SetWithExpiration(key string, value string, expireAt time.Time) {
+	_mock.Called(key, value, expireAt)
+	return
+}
+
+// MockEngine_SetWithExpiration_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetWithExpiration'
+type MockEngine_SetWithExpiration_Call struct {
+	*mock.Call
+}
+
+// SetWithExpiration is a helper method to define mock.On call
+//   - key string
+//   - value string
+//   - expireAt time.Time
+func (_e *MockEngine_Expecter) SetWithExpiration(key interface{}, value interface{}, expireAt interface{}) *MockEngine_SetWithExpiration_Call {
+	return &MockEngine_SetWithExpiration_Call{Call: _e.mock.On("SetWithExpiration", key, value, expireAt)}
+}
+
+func (_c *MockEngine_SetWithExpiration_Call) Run(run func(key string, value string, expireAt time.Time)) *MockEngine_SetWithExpiration_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 time.Time
+		if args[2] != nil {
+			arg2 = args[2].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockEngine_SetWithExpiration_Call) Return() *MockEngine_SetWithExpiration_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockEngine_SetWithExpiration_Call) RunAndReturn(run func(key string, value string, expireAt time.Time)) *MockEngine_SetWithExpiration_Call {
+	_c.Run(run)
+	return _c
+}
+
+// Stats provides a mock function for the type MockEngine
+func (_mock *MockEngine) Stats() Stats {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 Stats
+	if returnFunc, ok := ret.Get(0).(func() Stats); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(Stats)
+	}
+	return r0
+}
+
+// MockEngine_Stats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stats'
+type MockEngine_Stats_Call struct {
+	*mock.Call
+}
+
+// Stats is a helper method to define mock.On call
+func (_e *MockEngine_Expecter) Stats() *MockEngine_Stats_Call {
+	return &MockEngine_Stats_Call{Call: _e.mock.On("Stats")}
+}
+
+func (_c *MockEngine_Stats_Call) Run(run func()) *MockEngine_Stats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockEngine_Stats_Call) Return(stats Stats) *MockEngine_Stats_Call {
+	_c.Call.Return(stats)
+	return _c
+}
+
+func (_c *MockEngine_Stats_Call) RunAndReturn(run func() Stats) *MockEngine_Stats_Call {
+	_c.Call.Return(run)
+	return _c
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"strings"
+	"time"
+)
+
+// Ordered is implemented by engines which keep keys sorted, so they can return ranges of keys.
+// Limit zero means no limit, reverse returns keys in descending order. Expired keys are skipped.
+type Ordered interface {
+	// Range returns keys between start and end inclusive.
+	Range(start, end string, limit int, reverse bool) []string
+	// Prefix returns keys starting with prefix.
+	Prefix(prefix string, limit int, reverse bool) []string
+}
+
+// btreeEngine is engine which keeps its keys in B-tree besides hash map,
+// so point operations, expiration and eviction work as in map engine and keys may be iterated in order.
+type btreeEngine struct {
+	*engine
+}
+
+// NewBTreeEngine creates ordered engine, it implements Ordered.
+func NewBTreeEngine(opts ...Option) Engine {
+	e := newEngine(opts...)
+	e.index = newBTree()
+	return &btreeEngine{engine: e}
+}
+
+func (e *btreeEngine) Range(start, end string, limit int, reverse bool) []string {
+	if start > end {
+		return []string{}
+	}
+
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	c := e.collector(limit)
+	if reverse {
+		e.index.descend(func(key string) bool {
+			return key <= end
+		}, func(key string) bool {
+			return key >= start && c.add(key)
+		})
+	} else {
+		e.index.ascend(start, func(key string) bool {
+			return key <= end && c.add(key)
+		})
+	}
+
+	return c.keys
+}
+
+func (e *btreeEngine) Prefix(prefix string, limit int, reverse bool) []string {
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	c := e.collector(limit)
+	if reverse {
+		// keys with prefix follow keys less than prefix, so it is an upper bound of them
+		e.index.descend(func(key string) bool {
+			return key < prefix || strings.HasPrefix(key, prefix)
+		}, func(key string) bool {
+			return strings.HasPrefix(key, prefix) && c.add(key)
+		})
+	} else {
+		e.index.ascend(prefix, func(key string) bool {
+			return strings.HasPrefix(key, prefix) && c.add(key)
+		})
+	}
+
+	return c.keys
+}
+
+// keyCollector collects keys which are not expired up to limit, engine must be locked while it is used.
+type keyCollector struct {
+	e     *engine
+	now   int64
+	limit int
+	keys  []string
+}
+
+func (e *engine) collector(limit int) *keyCollector {
+	return &keyCollector{e: e, now: time.Now().UnixNano(), limit: limit, keys: []string{}}
+}
+
+// add appends key if it isn't expired, it returns false when limit is reached.
+func (c *keyCollector) add(key string) bool {
+	if c.e.kv[key].expired(c.now) {
+		return true
+	}
+	c.keys = append(c.keys, key)
+	return c.limit == 0 || len(c.keys) < c.limit
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBTreeEngine_RangePrefix(t *testing.T) {
+	e := NewBTreeEngine()
+	for _, key := range []string{"a", "user:1", "user:10", "user:2", "user:3", "users", "z"} {
+		e.Set(key, "value")
+	}
+	e.SetWithExpiration("user:25", "value", time.Now().Add(time.Millisecond))
+	e.Del("user:3")
+	time.Sleep(2 * time.Millisecond)
+
+	ordered := e.(Ordered)
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{name: "range", got: ordered.Range("user:1", "user:3", 0, false), want: []string{"user:1", "user:10", "user:2"}},
+		{name: "range with limit", got: ordered.Range("a", "z", 2, false), want: []string{"a", "user:1"}},
+		{name: "reverse range", got: ordered.Range("user:10", "users", 0, true), want: []string{"users", "user:2", "user:10"}},
+		{name: "reverse range with limit", got: ordered.Range("a", "z", 1, true), want: []string{"z"}},
+		{name: "empty range", got: ordered.Range("z", "a", 0, false), want: []string{}},
+		{name: "prefix", got: ordered.Prefix("user:", 0, false), want: []string{"user:1", "user:10", "user:2"}},
+		{name: "prefix with limit", got: ordered.Prefix("user", 2, false), want: []string{"user:1", "user:10"}},
+		{name: "reverse prefix", got: ordered.Prefix("user", 0, true), want: []string{"users", "user:2", "user:10", "user:1"}},
+		{name: "missing prefix", got: ordered.Prefix("b", 0, true), want: []string{}},
+		{name: "empty prefix", got: ordered.Prefix("", 0, false), want: []string{"a", "user:1", "user:10", "user:2", "users", "z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := NewEngine().(Ordered); ok {
+		t.Error("map engine must not be ordered")
+	}
+}
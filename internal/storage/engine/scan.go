@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"time"
+)
+
+// minimal number of hash index bits, index has 1<<bits buckets
+const minHashIndexBits = 4
+
+// Scan walks keys in order of their hashes, cursor is the hash position to continue from.
+// Zero cursor starts iteration, zero returned cursor means iteration is finished.
+// Position of key doesn't depend on other keys, so keys may be set and deleted between calls:
+// every key which exists during the whole iteration is returned exactly once,
+// keys added or deleted meanwhile may be returned or not.
+// Count is a hint, page may be larger if keys share the same hash. Any cursor is valid, so it never fails.
+func (e *engine) Scan(cursor uint64, count int) ([]string, uint64, error) {
+	page, more := e.scanPage(cursor, math.MaxUint32, count)
+	keys, next := cutPage(page, count, more)
+	return keys, next, nil
+}
+
+// Scan merges pages of all shards, they are ordered by the same hash as keys are split between shards.
+// Once count keys are collected, the rest of shards are asked only for keys up to the largest hash of them,
+// so page costs about count keys regardless of number of shards. Keys of the same hash are in the same shard.
+func (e *shardedEngine) Scan(cursor uint64, count int) ([]string, uint64, error) {
+	count = max(count, 1)
+
+	var (
+		page  = make(hashedKeys, 0, count)
+		more  bool
+		limit uint64 = math.MaxUint32
+		// the smallest hash of keys left out of page
+		minLeft uint64 = math.MaxUint32 + 1
+	)
+	for _, shard := range e.shards {
+		shardPage, shardMore := shard.scanPage(cursor, limit, count)
+		more = more || shardMore
+		for _, item := range shardPage {
+			switch {
+			case len(page) < count || item.hash == page[0].hash:
+				heap.Push(&page, item)
+			case item.hash > page[0].hash:
+				minLeft, more = min(minLeft, uint64(item.hash)), true
+			default:
+				left := heap.Pop(&page).(hashedKey)
+				minLeft, more = min(minLeft, uint64(left.hash)), true
+				heap.Push(&page, item)
+			}
+		}
+		if len(page) >= count {
+			limit = uint64(page[0].hash)
+		}
+	}
+
+	// keys of the same hash must not be split between pages
+	sort.Sort(sort.Reverse(page))
+	n := len(page)
+	for n > 0 && uint64(page[n-1].hash) >= minLeft {
+		n--
+	}
+	keys, next := cutPage(page[:n], count, more)
+	return keys, next, nil
+}
+
+type hashedKey struct {
+	hash uint32
+	key  string
+}
+
+// hashedKeys is max-heap by hash, it keeps keys with the smallest hashes.
+type hashedKeys []hashedKey
+
+func (h hashedKeys) Len() int           { return len(h) }
+func (h hashedKeys) Less(i, j int) bool { return h[i].hash > h[j].hash }
+func (h hashedKeys) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hashedKeys) Push(x any)        { *h = append(*h, x.(hashedKey)) }
+func (h *hashedKeys) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// hashIndex splits keys to buckets by the top bits of their hashes, so every key of a bucket has smaller hash
+// than keys of the next one and page is collected from the bucket of cursor without walking all keys.
+// Index doubles when there are twice more keys than buckets and halves when there are eight times less,
+// bucket b is split to 2b and 2b+1, so order of buckets is kept and cursor stays valid across resizes.
+type hashIndex struct {
+	bits    uint
+	buckets [][]hashedKey
+	size    int
+}
+
+func newHashIndex() *hashIndex {
+	return &hashIndex{
+		bits:    minHashIndexBits,
+		buckets: make([][]hashedKey, 1<<minHashIndexBits),
+	}
+}
+
+func (x *hashIndex) insert(key string) {
+	hash := hashKey(key)
+	b := x.bucket(hash)
+	x.buckets[b] = append(x.buckets[b], hashedKey{hash: hash, key: key})
+	x.size++
+
+	if x.size > 2*len(x.buckets) && x.bits < 32 {
+		x.resize(x.bits + 1)
+	}
+}
+
+func (x *hashIndex) delete(key string) {
+	hash := hashKey(key)
+	b := x.bucket(hash)
+	bucket := x.buckets[b]
+	for i := range bucket {
+		if bucket[i].key != key {
+			continue
+		}
+		last := len(bucket) - 1
+		bucket[i] = bucket[last]
+		bucket[last] = hashedKey{}
+		x.buckets[b] = bucket[:last]
+		x.size--
+		break
+	}
+
+	if x.size < len(x.buckets)/8 && x.bits > minHashIndexBits {
+		x.resize(x.bits - 1)
+	}
+}
+
+func (x *hashIndex) resize(bits uint) {
+	old := x.buckets
+	x.bits = bits
+	x.buckets = make([][]hashedKey, 1<<bits)
+	for _, bucket := range old {
+		for _, item := range bucket {
+			b := x.bucket(item.hash)
+			x.buckets[b] = append(x.buckets[b], item)
+		}
+	}
+}
+
+func (x *hashIndex) bucket(hash uint32) uint64 {
+	return uint64(hash) >> (32 - x.bits)
+}
+
+// scanPage returns at least count keys with the smallest hashes between cursor and limit inclusive,
+// sorted by hash, and reports whether there are more keys after them. Page is collected by whole buckets,
+// so it includes all keys of its last hash.
+func (e *engine) scanPage(cursor, limit uint64, count int) ([]hashedKey, bool) {
+	count = max(count, 1)
+
+	defer e.mx.RUnlock()
+	e.mx.RLock()
+
+	if cursor > math.MaxUint32 {
+		return nil, false
+	}
+
+	var (
+		now     = time.Now().UnixNano()
+		page    hashedKeys
+		more    bool
+		buckets = uint64(len(e.hashes.buckets))
+		b       = e.hashes.bucket(uint32(cursor))
+		last    = e.hashes.bucket(uint32(min(limit, math.MaxUint32)))
+	)
+	for ; b < buckets && b <= last && len(page) < count; b++ {
+		for _, item := range e.hashes.buckets[b] {
+			switch {
+			case uint64(item.hash) < cursor || e.kv[item.key].expired(now):
+			case uint64(item.hash) > limit:
+				more = true
+			default:
+				page = append(page, item)
+			}
+		}
+	}
+	for b < buckets && len(e.hashes.buckets[b]) == 0 {
+		b++
+	}
+
+	sort.Sort(sort.Reverse(page))
+	return page, more || b < buckets
+}
+
+// cutPage takes count keys of sorted page with all keys sharing hash of the last one and returns next cursor.
+func cutPage(page []hashedKey, count int, more bool) ([]string, uint64) {
+	n := min(max(count, 1), len(page))
+	for n > 0 && n < len(page) && page[n].hash == page[n-1].hash {
+		n++
+	}
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = page[i].key
+	}
+
+	if n == 0 || (n == len(page) && !more) {
+		return keys, 0
+	}
+	return keys, uint64(page[n-1].hash) + 1
+}
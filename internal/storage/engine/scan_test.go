@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scanAll iterates engine until cursor is zero and counts how many times every key is returned.
+func scanAll(t *testing.T, e Engine, count int) map[string]int {
+	t.Helper()
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for calls := 0; ; calls++ {
+		if calls > 100000 {
+			t.Fatal("scan doesn't finish")
+		}
+		keys, next, err := e.Scan(cursor, count)
+		if err != nil {
+			t.Fatalf("Scan(%d, %d) unexpected error: %v", cursor, count, err)
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+		if next == 0 {
+			return seen
+		}
+		if next <= cursor {
+			t.Fatalf("cursor moved back from %d to %d", cursor, next)
+		}
+		cursor = next
+	}
+}
+
+func TestEngine_Scan(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  int
+		count int
+	}{
+		{name: "empty", keys: 0, count: 10},
+		{name: "single page", keys: 5, count: 10},
+		{name: "many pages", keys: 1000, count: 7},
+		{name: "page of one key", keys: 50, count: 1},
+	}
+
+	for engineName, newEngine := range testEngines {
+		for _, tt := range tests {
+			t.Run(engineName+"/"+tt.name, func(t *testing.T) {
+				e := newEngine()
+				for i := 0; i < tt.keys; i++ {
+					e.Set(fmt.Sprintf("key:%d", i), "value")
+				}
+				e.SetWithExpiration("expiring", "value", time.Now().Add(time.Millisecond))
+				time.Sleep(2 * time.Millisecond)
+
+				seen := scanAll(t, e, tt.count)
+				if len(seen) != tt.keys {
+					t.Fatalf("got %d keys, want %d", len(seen), tt.keys)
+				}
+				for key, n := range seen {
+					if n != 1 {
+						t.Errorf("key %s is returned %d times", key, n)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEngine_ScanConcurrentWrites(t *testing.T) {
+	for engineName, newEngine := range testEngines {
+		t.Run(engineName, func(t *testing.T) {
+			e := newEngine()
+			const stableKeys = 500
+			for i := 0; i < stableKeys; i++ {
+				e.Set(fmt.Sprintf("stable:%d", i), "value")
+			}
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					key := fmt.Sprintf("volatile:%d", i%300)
+					if i%2 == 0 {
+						e.Set(key, "value")
+					} else {
+						e.Del(key)
+					}
+				}
+			}()
+
+			seen := scanAll(t, e, 10)
+			close(stop)
+			wg.Wait()
+
+			// keys existing during the whole scan are returned exactly once
+			for i := 0; i < stableKeys; i++ {
+				key := fmt.Sprintf("stable:%d", i)
+				if seen[key] != 1 {
+					t.Errorf("key %s is returned %d times", key, seen[key])
+				}
+			}
+		})
+	}
+}
+
+func TestCutPage(t *testing.T) {
+	page := []hashedKey{{hash: 1, key: "a"}, {hash: 2, key: "b"}, {hash: 2, key: "c"}, {hash: 5, key: "d"}}
+
+	tests := []struct {
+		name       string
+		count      int
+		more       bool
+		wantKeys   int
+		wantCursor uint64
+	}{
+		{name: "first key", count: 1, wantKeys: 1, wantCursor: 2},
+		{name: "keys of the same hash are not split", count: 2, wantKeys: 3, wantCursor: 3},
+		{name: "whole page", count: 4, wantKeys: 4, wantCursor: 0},
+		{name: "whole page with more keys", count: 4, more: true, wantKeys: 4, wantCursor: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, cursor := cutPage(page, tt.count, tt.more)
+			if len(keys) != tt.wantKeys || cursor != tt.wantCursor {
+				t.Errorf("cutPage() = %v, %d, want %d keys and cursor %d", keys, cursor, tt.wantKeys, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestEngine_ScanAcrossResize(t *testing.T) {
+	e := newEngine()
+	for i := 0; i < 1000; i++ {
+		e.Set(fmt.Sprintf("key:%d", i), "value")
+	}
+
+	// index grows while iteration is in progress, cursor is the same hash position in the larger index
+	keys, cursor, _ := e.Scan(0, 100)
+	seen := make(map[string]int)
+	for _, key := range keys {
+		seen[key]++
+	}
+	for i := 1000; i < 5000; i++ {
+		e.Set(fmt.Sprintf("key:%d", i), "value")
+	}
+	for cursor != 0 {
+		keys, cursor, _ = e.Scan(cursor, 100)
+		for _, key := range keys {
+			seen[key]++
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if n := seen[fmt.Sprintf("key:%d", i)]; n != 1 {
+			t.Errorf("key:%d is returned %d times", i, n)
+		}
+	}
+
+	for i := 10; i < 5000; i++ {
+		e.Del(fmt.Sprintf("key:%d", i))
+	}
+	if got := len(e.hashes.buckets); got > 8*10 {
+		t.Errorf("index has %d buckets for 10 keys, want it to shrink", got)
+	}
+	if seen := scanAll(t, e, 3); len(seen) != 10 {
+		t.Errorf("got %d keys after keys are deleted, want 10", len(seen))
+	}
+}
+
+func TestEngine_Dump(t *testing.T) {
+	for engineName, newEngine := range testEngines {
+		t.Run(engineName, func(t *testing.T) {
+			e := newEngine()
+			keys := 3*dumpChunkSize + 1
+			for i := 0; i < keys; i++ {
+				e.Set(fmt.Sprintf("key:%d", i), "value")
+			}
+			expireAt := time.Now().Add(time.Hour).Truncate(time.Nanosecond)
+			e.SetWithExpiration("volatile", "value", expireAt)
+			e.SetWithExpiration("expired", "value", time.Now().Add(time.Millisecond))
+			time.Sleep(2 * time.Millisecond)
+
+			dumped := make(map[string]int)
+			err := e.Dump(func(item Item) error {
+				dumped[item.Key]++
+				if item.Key == "volatile" && !item.ExpireAt.Equal(expireAt) {
+					t.Errorf("volatile is dumped with expiration %v, want %v", item.ExpireAt, expireAt)
+				}
+				// lock isn't held while item is handled
+				e.Set("written-during-dump", "value")
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Dump() unexpected error: %v", err)
+			}
+
+			for i := 0; i < keys; i++ {
+				if n := dumped[fmt.Sprintf("key:%d", i)]; n != 1 {
+					t.Errorf("key:%d is dumped %d times", i, n)
+				}
+			}
+			if dumped["volatile"] != 1 || dumped["expired"] != 0 {
+				t.Errorf("volatile is dumped %d times and expired %d times, want 1 and 0", dumped["volatile"], dumped["expired"])
+			}
+		})
+	}
+}
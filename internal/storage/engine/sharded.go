@@ -0,0 +1,113 @@
+package engine
+
+import "time"
+
+const DefaultShardsCount = 64
+
+// shardedEngine splits keys between independent engines by key hash,
+// so operations on keys of different shards don't wait for each other.
+type shardedEngine struct {
+	shards []*engine
+}
+
+// NewShardedEngine creates engine of independent shards, memory limit is split between shards equally.
+func NewShardedEngine(shardsCount int, opts ...Option) Engine {
+	if shardsCount <= 0 {
+		shardsCount = DefaultShardsCount
+	}
+
+	shards := make([]*engine, shardsCount)
+	for i := range shards {
+		shards[i] = newEngine(opts...)
+		if shards[i].maxMemory > 0 {
+			shards[i].maxMemory = max(shards[i].maxMemory/int64(shardsCount), 1)
+		}
+	}
+
+	return &shardedEngine{
+		shards: shards,
+	}
+}
+
+func (e *shardedEngine) Set(key, value string) {
+	e.shard(key).Set(key, value)
+}
+
+func (e *shardedEngine) SetWithExpiration(key, value string, expireAt time.Time) {
+	e.shard(key).SetWithExpiration(key, value, expireAt)
+}
+
+func (e *shardedEngine) Get(key string) (string, bool) {
+	return e.shard(key).Get(key)
+}
+
+func (e *shardedEngine) Del(key string) {
+	e.shard(key).Del(key)
+}
+
+func (e *shardedEngine) Expire(key string, expireAt time.Time) bool {
+	return e.shard(key).Expire(key, expireAt)
+}
+
+func (e *shardedEngine) Persist(key string) bool {
+	return e.shard(key).Persist(key)
+}
+
+func (e *shardedEngine) ExpireAt(key string) (time.Time, bool) {
+	return e.shard(key).ExpireAt(key)
+}
+
+func (e *shardedEngine) DeleteExpired() int {
+	deleted := 0
+	for _, shard := range e.shards {
+		deleted += shard.DeleteExpired()
+	}
+	return deleted
+}
+
+func (e *shardedEngine) Reserve(key string, valueSize int, evict EvictFunc) ([]string, error) {
+	return e.shard(key).Reserve(key, valueSize, evict)
+}
+
+func (e *shardedEngine) Stats() Stats {
+	var stats Stats
+	for _, shard := range e.shards {
+		shardStats := shard.Stats()
+		stats.Keys += shardStats.Keys
+		stats.KeysEstimated = stats.KeysEstimated || shardStats.KeysEstimated
+		stats.UsedMemory += shardStats.UsedMemory
+		stats.MaxMemory += shardStats.MaxMemory
+		stats.Evictions += shardStats.Evictions
+	}
+	return stats
+}
+
+// Dump copies keys shard by shard, so writers wait only for copying of their shard.
+func (e *shardedEngine) Dump(fn func(Item) error) error {
+	for _, shard := range e.shards {
+		if err := shard.Dump(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *shardedEngine) shard(key string) *engine {
+	return e.shards[hashKey(key)%uint32(len(e.shards))]
+}
+
+// hashKey is 32-bit FNV-1a hash, inlined to avoid allocations of hash.Hash on hot path.
+func hashKey(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+
+	return h
+}
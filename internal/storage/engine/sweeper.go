@@ -0,0 +1,28 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+const DefaultSweepInterval = 100 * time.Millisecond
+
+// RunSweeper actively deletes expired keys every interval until context is done,
+// so keys which are never accessed again don't occupy memory.
+func RunSweeper(ctx context.Context, e Engine, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.DeleteExpired()
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+)
+
+// evictor makes room for writes and logs deletes of keys evicted by engine before records of write,
+// so replicas and recovery delete the same keys instead of evicting their own ones.
+//
+// Stripe of evicted key is locked until write is finished, so delete of key is logged in the same order
+// as other writes of it. Stripes of keys locked by writer are not locked again, keys of stripes locked
+// by other writers are skipped and engine evicts other keys instead of them.
+type evictor struct {
+	s *storage
+	// keys locked by writer
+	keys []string
+	// stripes locked by writer or by evictor, nil until engine asks to evict the first key
+	held    map[uint32]struct{}
+	locked  []uint32
+	records []wal.Record
+}
+
+func (s *storage) newEvictor(keys ...string) *evictor {
+	return &evictor{s: s, keys: keys}
+}
+
+// reserve makes room for value of key, evicted keys are already deleted from engine,
+// their deletes are logged by log or abort.
+func (ev *evictor) reserve(key string, valueSize int) error {
+	evicted, err := ev.s.engine.Reserve(key, valueSize, ev.evict)
+	for _, victim := range evicted {
+		ev.records = append(ev.records, wal.Record{Op: wal.OpDel, Args: []string{victim}})
+		ev.s.touch(victim)
+	}
+	return err
+}
+
+func (ev *evictor) evict(key string) bool {
+	if ev.held == nil {
+		ev.held = make(map[uint32]struct{}, len(ev.keys))
+		for _, k := range ev.keys {
+			ev.held[keyLockIndex(k)] = struct{}{}
+		}
+	}
+
+	i := keyLockIndex(key)
+	if _, ok := ev.held[i]; ok {
+		return true
+	}
+	if !ev.s.keyLocks[i].TryLock() {
+		return false
+	}
+	ev.held[i] = struct{}{}
+	ev.locked = append(ev.locked, i)
+	return true
+}
+
+// log logs deletes of evicted keys and records of write by one write.
+func (ev *evictor) log(records ...wal.Record) error {
+	evicted := len(ev.records)
+	records = append(ev.records, records...)
+	ev.records = nil
+
+	if ev.s.wal != nil && len(records) > 0 {
+		if err := ev.s.wal.WriteMany(records); err != nil {
+			return err
+		}
+	}
+	ev.s.changes.Add(uint64(evicted))
+	return nil
+}
+
+// abort logs deletes of keys evicted before write failed, it returns err joined with error of log.
+func (ev *evictor) abort(err error) error {
+	if len(ev.records) == 0 {
+		return err
+	}
+	if logErr := ev.log(); logErr != nil {
+		return errors.Join(err, logErr)
+	}
+	return err
+}
+
+// take returns deletes of evicted keys to be logged by caller, e.g. by transaction.
+func (ev *evictor) take() []wal.Record {
+	records := ev.records
+	ev.records = nil
+	return records
+}
+
+// unlock unlocks stripes of evicted keys.
+func (ev *evictor) unlock() {
+	for _, i := range ev.locked {
+		ev.s.keyLocks[i].Unlock()
+	}
+}
+
+// setRecord returns record of set of key, zero time means key never expires.
+func setRecord(key, value string, expireAt time.Time) wal.Record {
+	if expireAt.IsZero() {
+		return wal.Record{Op: wal.OpSet, Args: []string{key, value}}
+	}
+	return wal.Record{Op: wal.OpSetWithExpiration, Args: []string{key, value, wal.FormatTime(expireAt)}}
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"github.com/MitrickX/simple-kv/internal/metrics"
+)
+
+func (s *storage) registerMetrics(registry *metrics.Registry) {
+	registry.NewGaugeFunc("simplekv_keys", "Number of keys including expired ones which are not swept yet, lsm engine counts versions of keys.", func() float64 {
+		return float64(s.Stats().Keys)
+	})
+	registry.NewGaugeFunc("simplekv_used_memory_bytes", "Estimated memory used by keys and values.", func() float64 {
+		return float64(s.Stats().UsedMemory)
+	})
+	registry.NewGaugeFunc("simplekv_max_memory_bytes", "Memory limit of keys and values, 0 means no limit.", func() float64 {
+		return float64(s.Stats().MaxMemory)
+	})
+	registry.NewCounterFunc("simplekv_evicted_keys_total", "Number of keys evicted because of memory limit.", func() float64 {
+		return float64(s.Stats().Evictions)
+	})
+
+	if s.wal != nil {
+		registry.NewGaugeFunc("simplekv_wal_last_lsn", "LSN of the last record written to write-ahead log.", func() float64 {
+			return float64(s.LastLSN())
+		})
+	}
+
+	if s.snapshotter != nil {
+		registry.NewGaugeFunc("simplekv_changes_since_snapshot",
+			"Number of writes made after the last snapshot, they are replayed from log on recovery.", func() float64 {
+				return float64(s.ChangesSinceSnapshot())
+			})
+		registry.NewGaugeFunc("simplekv_last_snapshot_timestamp_seconds",
+			"Unix time of the last written or loaded snapshot, 0 if there is none.", func() float64 {
+				t := s.LastSnapshotTime()
+				if t.IsZero() {
+					return 0
+				}
+				return float64(t.UnixNano()) / 1e9
+			})
+	}
+}
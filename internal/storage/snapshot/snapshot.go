@@ -0,0 +1,365 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"go.uber.org/zap"
+)
+
+const (
+	snapshotPrefix = "snapshot_"
+	snapshotSuffix = ".snap"
+	tmpSuffix      = ".tmp"
+
+	// previous snapshot is kept to recover from it if the newest one is damaged
+	keepSnapshots = 2
+
+	bufSize = 64 * config.KB
+
+	itemMarker = 1
+	endMarker  = 0
+)
+
+// magic identifies snapshot file format, the last byte is format version
+var magic = [8]byte{'S', 'K', 'V', 'S', 'N', 'A', 'P', 1}
+
+var (
+	ErrCorruptedSnapshot = errors.New("snapshot error: corrupted snapshot")
+)
+
+// Snapshotter writes dumps of engine to files named by LSN of the last log record they contain.
+//
+// File format: magic (8 bytes), LSN (8 bytes), items each starting with marker byte 1:
+// key and value prefixed with their length (uvarint), expiration time in unix nanoseconds (varint),
+// end marker byte 0 and crc32 of all previous bytes (4 bytes).
+type Snapshotter struct {
+	dir    string
+	logger *zap.Logger
+}
+
+func NewSnapshotter(cfg config.ConfigSnapshot, logger *zap.Logger) (*Snapshotter, error) {
+	if cfg.DataDirectory == "" {
+		return nil, errors.New("snapshot error: data directory is not set")
+	}
+
+	if err := os.MkdirAll(cfg.DataDirectory, 0o755); err != nil {
+		return nil, fmt.Errorf("snapshot error: can't create data directory: %w", err)
+	}
+
+	return &Snapshotter{
+		dir:    cfg.DataDirectory,
+		logger: logger,
+	}, nil
+}
+
+// Write writes snapshot of items passed by dump to temporary file and renames it when it is fully synced,
+// so crash never leaves partially written snapshot. Old snapshots are removed.
+func (s *Snapshotter) Write(lsn uint64, dump func(fn func(engine.Item) error) error) error {
+	path := filepath.Join(s.dir, snapshotName(lsn))
+	tmpPath := path + tmpSuffix
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("snapshot error: can't create file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	start := time.Now()
+	checksum := crc32.NewIEEE()
+	writer := bufio.NewWriterSize(io.MultiWriter(file, checksum), bufSize)
+
+	var buf []byte
+	buf = append(buf, magic[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, lsn)
+	if _, err := writer.Write(buf); err != nil {
+		return fmt.Errorf("snapshot error: can't write file: %w", err)
+	}
+
+	items := 0
+	err = dump(func(item engine.Item) error {
+		buf = encodeItem(buf[:0], item)
+		items++
+		_, err := writer.Write(buf)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot error: can't write file: %w", err)
+	}
+
+	if err := writer.WriteByte(endMarker); err != nil {
+		return fmt.Errorf("snapshot error: can't write file: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("snapshot error: can't write file: %w", err)
+	}
+	if _, err := file.Write(binary.BigEndian.AppendUint32(nil, checksum.Sum32())); err != nil {
+		return fmt.Errorf("snapshot error: can't write file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("snapshot error: can't sync file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("snapshot error: can't close file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("snapshot error: can't rename file: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("snapshot error: can't sync data directory: %w", err)
+	}
+
+	s.logger.Info("snapshot written",
+		zap.String("path", path),
+		zap.Uint64("lsn", lsn),
+		zap.Int("items", items),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return s.removeOld()
+}
+
+// Load reads the newest valid snapshot and calls load for every item. Damaged snapshots are skipped.
+// It returns LSN of the snapshot and false if there is no valid snapshot.
+func (s *Snapshotter) Load(load func(engine.Item) error) (uint64, bool, error) {
+	snapshots, err := s.list()
+	if err != nil {
+		return 0, false, err
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		path := snapshots[i].path
+
+		// checksum is verified before loading, so damaged snapshot doesn't leave partial data in engine
+		if err := verify(path); err != nil {
+			s.logger.Warn("snapshot is damaged, skip it", zap.String("path", path), zap.Error(err))
+			continue
+		}
+
+		if err := read(path, load); err != nil {
+			return 0, false, err
+		}
+
+		s.logger.Info("snapshot loaded", zap.String("path", path), zap.Uint64("lsn", snapshots[i].lsn))
+		return snapshots[i].lsn, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// OldestLSN returns LSN of the oldest kept snapshot, log records up to it are not needed for recovery.
+func (s *Snapshotter) OldestLSN() (uint64, bool, error) {
+	snapshots, err := s.list()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(snapshots) == 0 {
+		return 0, false, nil
+	}
+
+	return snapshots[0].lsn, true, nil
+}
+
+func (s *Snapshotter) removeOld() error {
+	snapshots, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(snapshots)-keepSnapshots; i++ {
+		if err := os.Remove(snapshots[i].path); err != nil {
+			return fmt.Errorf("snapshot error: can't remove old snapshot: %w", err)
+		}
+		s.logger.Debug("old snapshot removed", zap.String("path", snapshots[i].path))
+	}
+
+	return nil
+}
+
+type snapshotFile struct {
+	path string
+	lsn  uint64
+}
+
+func snapshotName(lsn uint64) string {
+	return fmt.Sprintf("%s%020d%s", snapshotPrefix, lsn, snapshotSuffix)
+}
+
+// list returns snapshots of directory ordered by LSN.
+func (s *Snapshotter) list() ([]snapshotFile, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot error: can't list snapshots: %w", err)
+	}
+
+	snapshots := make([]snapshotFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+
+		lsn, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, snapshotFile{path: filepath.Join(s.dir, name), lsn: lsn})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].lsn < snapshots[j].lsn
+	})
+
+	return snapshots, nil
+}
+
+func encodeItem(buf []byte, item engine.Item) []byte {
+	buf = append(buf, itemMarker)
+	buf = binary.AppendUvarint(buf, uint64(len(item.Key)))
+	buf = append(buf, item.Key...)
+	buf = binary.AppendUvarint(buf, uint64(len(item.Value)))
+	buf = append(buf, item.Value...)
+
+	var expireAt int64
+	if !item.ExpireAt.IsZero() {
+		expireAt = item.ExpireAt.UnixNano()
+	}
+	return binary.AppendVarint(buf, expireAt)
+}
+
+// verify compares checksum stored at the end of file with checksum of its content.
+func verify(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("snapshot error: can't open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("snapshot error: can't stat file: %w", err)
+	}
+	if info.Size() < int64(len(magic)+8+1+4) {
+		return ErrCorruptedSnapshot
+	}
+
+	checksum := crc32.NewIEEE()
+	if _, err := io.Copy(checksum, io.LimitReader(file, info.Size()-4)); err != nil {
+		return fmt.Errorf("snapshot error: can't read file: %w", err)
+	}
+
+	var stored [4]byte
+	if _, err := io.ReadFull(file, stored[:]); err != nil {
+		return fmt.Errorf("snapshot error: can't read file: %w", err)
+	}
+
+	if binary.BigEndian.Uint32(stored[:]) != checksum.Sum32() {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorruptedSnapshot)
+	}
+
+	return nil
+}
+
+func read(path string, load func(engine.Item) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("snapshot error: can't open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, bufSize)
+
+	var header [len(magic) + 8]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return fmt.Errorf("%w: can't read header", ErrCorruptedSnapshot)
+	}
+	if [8]byte(header[:8]) != magic {
+		return fmt.Errorf("%w: unknown format", ErrCorruptedSnapshot)
+	}
+
+	for {
+		marker, err := reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: unexpected end of file", ErrCorruptedSnapshot)
+		}
+		if marker == endMarker {
+			return nil
+		}
+		if marker != itemMarker {
+			return fmt.Errorf("%w: invalid item marker", ErrCorruptedSnapshot)
+		}
+
+		item, err := decodeItem(reader)
+		if err != nil {
+			return err
+		}
+
+		if err := load(item); err != nil {
+			return fmt.Errorf("snapshot error: can't load item: %w", err)
+		}
+	}
+}
+
+func decodeItem(reader *bufio.Reader) (engine.Item, error) {
+	key, err := readString(reader)
+	if err != nil {
+		return engine.Item{}, err
+	}
+
+	value, err := readString(reader)
+	if err != nil {
+		return engine.Item{}, err
+	}
+
+	expireAt, err := binary.ReadVarint(reader)
+	if err != nil {
+		return engine.Item{}, fmt.Errorf("%w: invalid expiration time", ErrCorruptedSnapshot)
+	}
+
+	item := engine.Item{Key: key, Value: value}
+	if expireAt != 0 {
+		item.ExpireAt = time.Unix(0, expireAt)
+	}
+
+	return item, nil
+}
+
+func readString(reader *bufio.Reader) (string, error) {
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid length", ErrCorruptedSnapshot)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", fmt.Errorf("%w: unexpected end of file", ErrCorruptedSnapshot)
+	}
+
+	return string(buf), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,117 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"go.uber.org/zap"
+)
+
+func dumpItems(items []engine.Item) func(fn func(engine.Item) error) error {
+	return func(fn func(engine.Item) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func loadItems(t *testing.T, s *Snapshotter) ([]engine.Item, uint64, bool) {
+	t.Helper()
+
+	var items []engine.Item
+	lsn, ok, err := s.Load(func(item engine.Item) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	return items, lsn, ok
+}
+
+func TestSnapshotter_WriteLoad(t *testing.T) {
+	s, err := NewSnapshotter(config.ConfigSnapshot{DataDirectory: t.TempDir()}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create snapshotter: %v", err)
+	}
+
+	if _, _, ok := loadItems(t, s); ok {
+		t.Fatalf("Load() found snapshot in empty directory")
+	}
+
+	first := []engine.Item{{Key: "a", Value: "1"}}
+	second := []engine.Item{
+		{Key: "a", Value: "2"},
+		{Key: "binary \x00\r\n", Value: ""},
+		{Key: "b", Value: "3", ExpireAt: time.Unix(0, time.Now().Add(time.Hour).UnixNano())},
+	}
+
+	for lsn, items := range map[uint64][]engine.Item{10: first, 20: second} {
+		if err := s.Write(lsn, dumpItems(items)); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+	}
+
+	items, lsn, ok := loadItems(t, s)
+	if !ok || lsn != 20 || !reflect.DeepEqual(items, second) {
+		t.Errorf("Load() = %v, %d, %v, want %v, 20, true", items, lsn, ok, second)
+	}
+
+	if oldest, ok, err := s.OldestLSN(); err != nil || !ok || oldest != 10 {
+		t.Errorf("OldestLSN() = %d, %v, %v, want 10, true, nil", oldest, ok, err)
+	}
+
+	// only two newest snapshots are kept
+	if err := s.Write(30, dumpItems(nil)); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if oldest, _, _ := s.OldestLSN(); oldest != 20 {
+		t.Errorf("OldestLSN() = %d, want 20", oldest)
+	}
+}
+
+func TestSnapshotter_DamagedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSnapshotter(config.ConfigSnapshot{DataDirectory: dir}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create snapshotter: %v", err)
+	}
+
+	items := []engine.Item{{Key: "a", Value: "1"}}
+	if err := s.Write(1, dumpItems(items)); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Write(2, dumpItems([]engine.Item{{Key: "a", Value: "2"}})); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	// flip one byte of value in the newest snapshot
+	path := filepath.Join(dir, snapshotName(2))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %v", err)
+	}
+	data[len(data)-6] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+
+	if err := verify(path); !errors.Is(err, ErrCorruptedSnapshot) {
+		t.Errorf("verify() error = %v, want %v", err, ErrCorruptedSnapshot)
+	}
+
+	got, lsn, ok := loadItems(t, s)
+	if !ok || lsn != 1 || !reflect.DeepEqual(got, items) {
+		t.Errorf("Load() = %v, %d, %v, want %v, 1, true", got, lsn, ok, items)
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// how often conditions of automatic snapshot are checked
+const snapshotCheckInterval = time.Second
+
+// RunSnapshotter makes snapshot of storage when interval passed since the last one or number of writes
+// reaches threshold, until context is done. Zero interval or threshold disables the condition.
+func RunSnapshotter(ctx context.Context, s Storage, interval time.Duration, writesThreshold uint64, logger *zap.Logger) {
+	if interval <= 0 && writesThreshold == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(min(snapshotCheckInterval, max(interval, time.Millisecond)))
+	defer ticker.Stop()
+
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changes := s.ChangesSinceSnapshot()
+			if changes == 0 {
+				continue
+			}
+
+			last := s.LastSnapshotTime()
+			if last.IsZero() {
+				last = started
+			}
+
+			if (interval > 0 && time.Since(last) >= interval) || (writesThreshold > 0 && changes >= writesThreshold) {
+				err := s.Snapshot()
+				if err != nil && !errors.Is(err, ErrSnapshotInProgress) {
+					logger.Error("failed to make snapshot", zap.Error(err))
+				}
+			}
+		}
+	}
+}
@@ -1,29 +1,841 @@
 package storage
 
-import "github.com/MitrickX/simple-kv/internal/storage/engine"
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/metrics"
+	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/MitrickX/simple-kv/internal/storage/snapshot"
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+)
+
+const (
+	// number of lock stripes which keep order of writes to WAL and engine the same for every key
+	keyLocksCount = 256
+	// number of key version stripes, keys of the same stripe share version,
+	// so modification of one of them aborts transactions watching another one
+	keyVersionsCount = 4096
+)
+
+var (
+	ErrReadOnly           = errors.New("storage error: read-only replica doesn't accept writes")
+	ErrSnapshotsDisabled  = errors.New("storage error: snapshots are not configured")
+	ErrSnapshotInProgress = errors.New("storage error: snapshot is already in progress")
+	ErrNotOrdered         = errors.New("storage error: engine doesn't keep keys ordered, use btree engine")
+	ErrSnapshotInTx       = errors.New("storage error: snapshot can't be taken inside transaction")
+)
 
 type Storage interface {
-	Set(key, value string)
+	Set(key, value string) error
+	SetWithExpiration(key, value string, expireAt time.Time) error
 	Get(key string) (string, bool)
-	Del(key string)
+	MGet(keys []string) ([]string, []bool)
+	Del(key string) (bool, error)
+	MDel(keys []string) (int, error)
+	MSet(keys, values []string) error
+	Update(key string, fn UpdateFunc) (string, error)
+	SetIf(key, value string, expireAt time.Time, cond Condition) (SetIfResult, error)
+	Expire(key string, expireAt time.Time) (bool, error)
+	Persist(key string) (bool, error)
+	ExpireAt(key string) (time.Time, bool)
+	Scan(cursor uint64, count int) ([]string, uint64, error)
+	Range(start, end string, limit int, reverse bool) ([]string, error)
+	Prefix(prefix string, limit int, reverse bool) ([]string, error)
+	Recover() error
+	Close() error
+	LastLSN() uint64
+	ApplyReplicated(records []wal.Record) error
+	Stats() engine.Stats
+	Snapshot() error
+	ChangesSinceSnapshot() uint64
+	LastSnapshotTime() time.Time
+	KeyVersion(key string) uint64
+	Begin(keys []string) *Tx
+}
+
+// Replicas reports progress of replicas which pull log of storage.
+type Replicas interface {
+	// AppliedLSN returns the least LSN which replicas applied log up to, false if there are none.
+	AppliedLSN() (uint64, bool)
+}
+
+type Option func(*storage)
+
+// WithWAL makes storage log every mutation to write-ahead log before applying it to engine.
+func WithWAL(wal *wal.WAL) Option {
+	return func(s *storage) {
+		s.wal = wal
+	}
+}
+
+// WithSnapshotter makes storage able to dump engine to snapshots and recover from the newest of them.
+func WithSnapshotter(snapshotter *snapshot.Snapshotter) Option {
+	return func(s *storage) {
+		s.snapshotter = snapshotter
+	}
 }
 
-func NewStorage(engine engine.Engine) Storage {
-	return &storage{
+// WithReplicas keeps log which replicas haven't applied yet, so pruning doesn't cut lagging replicas off.
+func WithReplicas(replicas Replicas) Option {
+	return func(s *storage) {
+		s.replicas = replicas
+	}
+}
+
+// WithReadOnly rejects writes of clients, data is changed only by replication.
+func WithReadOnly() Option {
+	return func(s *storage) {
+		s.readOnly = true
+	}
+}
+
+// WithMetrics exposes number of keys, memory, evictions and state of log and snapshots.
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(s *storage) {
+		s.registry = registry
+	}
+}
+
+func NewStorage(engine engine.Engine, opts ...Option) Storage {
+	s := &storage{
 		engine: engine,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// metrics depend on other options, so they are registered after all of them are applied
+	if s.registry != nil {
+		s.registerMetrics(s.registry)
+	}
+
+	return s
 }
 
 type storage struct {
-	engine engine.Engine
+	engine      engine.Engine
+	wal         *wal.WAL
+	snapshotter *snapshot.Snapshotter
+	replicas    Replicas
+	readOnly    bool
+	registry    *metrics.Registry
+	keyLocks    [keyLocksCount]sync.Mutex
+	// versions are changed under key locks
+	keyVersions [keyVersionsCount]atomic.Uint64
+
+	// writes hold read lock while they are logged and applied, snapshot takes write lock
+	// for a moment to get LSN which all applied writes are logged up to
+	writeMx sync.RWMutex
+	// writes of many keys are applied to engine under write lock and reads take read lock,
+	// so readers see either all or none of them, logs are flushed without this lock
+	applyMx sync.RWMutex
+	// number of writes since the last snapshot
+	changes      atomic.Uint64
+	snapshotMx   sync.Mutex
+	lastSnapshot atomic.Int64
+
+	// checkpoints of durable engine are taken in background after recovery
+	checkpointStop    chan struct{}
+	checkpointStopped chan struct{}
 }
 
-func (s *storage) Set(key, value string) {
+func (s *storage) Set(key, value string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	ev := s.newEvictor(key)
+	defer ev.unlock()
+
+	if err := ev.reserve(key, len(value)); err != nil {
+		return fmt.Errorf("storage set fail: %w", ev.abort(err))
+	}
+
+	if err := ev.log(setRecord(key, value, time.Time{})); err != nil {
+		return fmt.Errorf("storage set fail: %w", err)
+	}
+
 	s.engine.Set(key, value)
+	s.touch(key)
+	s.changes.Add(1)
+	return nil
 }
+
+func (s *storage) SetWithExpiration(key, value string, expireAt time.Time) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	ev := s.newEvictor(key)
+	defer ev.unlock()
+
+	if err := ev.reserve(key, len(value)); err != nil {
+		return fmt.Errorf("storage set fail: %w", ev.abort(err))
+	}
+
+	if err := ev.log(setRecord(key, value, expireAt)); err != nil {
+		return fmt.Errorf("storage set fail: %w", err)
+	}
+
+	s.engine.SetWithExpiration(key, value, expireAt)
+	s.touch(key)
+	s.changes.Add(1)
+	return nil
+}
+
 func (s *storage) Get(key string) (string, bool) {
+	defer s.applyMx.RUnlock()
+	s.applyMx.RLock()
+
 	return s.engine.Get(key)
 }
-func (s *storage) Del(key string) {
+
+// MGet returns values of keys, found[i] is false if keys[i] doesn't exist.
+// Keys are read at once, so writes of many keys are seen either entirely or not at all.
+func (s *storage) MGet(keys []string) (values []string, found []bool) {
+	defer s.applyMx.RUnlock()
+	s.applyMx.RLock()
+
+	values = make([]string, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = s.engine.Get(key)
+	}
+	return values, found
+}
+
+// Del deletes key. It returns false if key doesn't exist, then nothing is logged.
+func (s *storage) Del(key string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	if _, ok := s.engine.ExpireAt(key); !ok {
+		return false, nil
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Del(key); err != nil {
+			return false, fmt.Errorf("storage del fail: %w", err)
+		}
+	}
+
 	s.engine.Del(key)
+	s.touch(key)
+	s.changes.Add(1)
+	return true, nil
+}
+
+// MDel deletes keys and returns number of deleted ones. Deletes are logged by one write,
+// so failure leaves all keys unchanged, and applied at once, so readers don't see part of them.
+func (s *storage) MDel(keys []string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	unlock := s.lockKeys(keys)
+	defer unlock()
+
+	records := make([]wal.Record, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, ok := s.engine.ExpireAt(key); ok {
+			records = append(records, wal.Record{Op: wal.OpDel, Args: []string{key}})
+		}
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	if s.wal != nil {
+		if err := s.wal.WriteMany(records); err != nil {
+			return 0, fmt.Errorf("storage mdel fail: %w", err)
+		}
+	}
+
+	s.applyMx.Lock()
+	for _, record := range records {
+		s.engine.Del(record.Args[0])
+		s.touch(record.Args[0])
+	}
+	s.applyMx.Unlock()
+
+	s.changes.Add(uint64(len(records)))
+	return len(records), nil
+}
+
+// MSet sets keys to values of the same index. Memory is reserved for all keys before any of them is set
+// and sets are logged by one write, so failure leaves all keys unchanged. Sets are applied at once,
+// so readers don't see part of them.
+func (s *storage) MSet(keys, values []string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	unlock := s.lockKeys(keys)
+	defer unlock()
+
+	ev := s.newEvictor(keys...)
+	defer ev.unlock()
+
+	records := make([]wal.Record, 0, len(keys))
+	for i, key := range keys {
+		if err := ev.reserve(key, len(values[i])); err != nil {
+			return fmt.Errorf("storage mset fail: %w", ev.abort(err))
+		}
+		records = append(records, setRecord(key, values[i], time.Time{}))
+	}
+
+	if err := ev.log(records...); err != nil {
+		return fmt.Errorf("storage mset fail: %w", err)
+	}
+
+	s.applyMx.Lock()
+	for i, key := range keys {
+		s.engine.Set(key, values[i])
+		s.touch(key)
+	}
+	s.applyMx.Unlock()
+
+	s.changes.Add(uint64(len(keys)))
+	return nil
+}
+
+// UpdateFunc returns new value of key by its current value, ok is false if key doesn't exist.
+// If it returns error, key isn't changed.
+type UpdateFunc func(value string, ok bool) (string, error)
+
+// Update atomically replaces value of key by result of fn and logs new value as set of key,
+// so log is replayed without fn. Key keeps its expiration time. Errors of fn are returned as is.
+// Writers of key are serialized by key lock, so new value is computed and logged without lock of engine
+// and readers aren't blocked while log is flushed.
+func (s *storage) Update(key string, fn UpdateFunc) (string, error) {
+	if s.readOnly {
+		return "", ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	current, ok := s.engine.Get(key)
+	expireAt, alive := s.engine.ExpireAt(key)
+	if !ok || !alive {
+		// key may expire between reads, then it is created without expiration
+		current, ok, expireAt = "", false, time.Time{}
+	}
+
+	value, err := fn(current, ok)
+	if err != nil {
+		return "", err
+	}
+
+	ev := s.newEvictor(key)
+	defer ev.unlock()
+
+	if err := ev.reserve(key, len(value)); err != nil {
+		return "", fmt.Errorf("storage update fail: %w", ev.abort(err))
+	}
+
+	if err := ev.log(setRecord(key, value, expireAt)); err != nil {
+		return "", fmt.Errorf("storage update fail: %w", err)
+	}
+
+	if expireAt.IsZero() {
+		s.engine.Set(key, value)
+	} else {
+		s.engine.SetWithExpiration(key, value, expireAt)
+	}
+	s.touch(key)
+	s.changes.Add(1)
+	return value, nil
+}
+
+// Condition decides whether key is set by its current value, ok is false if key doesn't exist.
+type Condition func(value string, ok bool) bool
+
+// SetIfResult is a previous state of key and whether conditional set is applied.
+type SetIfResult struct {
+	// previous value, empty if key didn't exist
+	Value   string
+	Exists  bool
+	Applied bool
+}
+
+// SetIf sets value of key with expiration time if cond is true, zero time means key never expires.
+// Set is logged only if it is applied. Writers of key are serialized by key lock, so condition is checked
+// and set is logged without lock of engine and readers aren't blocked while log is flushed.
+func (s *storage) SetIf(key, value string, expireAt time.Time, cond Condition) (SetIfResult, error) {
+	if s.readOnly {
+		return SetIfResult{}, ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	var result SetIfResult
+	result.Value, result.Exists = s.engine.Get(key)
+	if !cond(result.Value, result.Exists) {
+		return result, nil
+	}
+
+	ev := s.newEvictor(key)
+	defer ev.unlock()
+
+	if err := ev.reserve(key, len(value)); err != nil {
+		return SetIfResult{}, fmt.Errorf("storage set fail: %w", ev.abort(err))
+	}
+
+	if err := ev.log(setRecord(key, value, expireAt)); err != nil {
+		return SetIfResult{}, fmt.Errorf("storage set fail: %w", err)
+	}
+
+	if expireAt.IsZero() {
+		s.engine.Set(key, value)
+	} else {
+		s.engine.SetWithExpiration(key, value, expireAt)
+	}
+	s.touch(key)
+	s.changes.Add(1)
+	result.Applied = true
+	return result, nil
+}
+
+// Expire sets expiration time of key. It returns false if key doesn't exist.
+func (s *storage) Expire(key string, expireAt time.Time) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	if _, ok := s.engine.ExpireAt(key); !ok {
+		return false, nil
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Expire(key, expireAt); err != nil {
+			return false, fmt.Errorf("storage expire fail: %w", err)
+		}
+	}
+
+	s.touch(key)
+	s.changes.Add(1)
+	return s.engine.Expire(key, expireAt), nil
+}
+
+// Persist removes expiration of key. It returns false if key doesn't exist or has no expiration.
+func (s *storage) Persist(key string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	mx := s.keyLock(key)
+	defer mx.Unlock()
+	mx.Lock()
+
+	if expireAt, ok := s.engine.ExpireAt(key); !ok || expireAt.IsZero() {
+		return false, nil
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Persist(key); err != nil {
+			return false, fmt.Errorf("storage persist fail: %w", err)
+		}
+	}
+
+	s.touch(key)
+	s.changes.Add(1)
+	return s.engine.Persist(key), nil
+}
+
+// ExpireAt returns expiration time of key, zero time means key never expires.
+func (s *storage) ExpireAt(key string) (time.Time, bool) {
+	defer s.applyMx.RUnlock()
+	s.applyMx.RLock()
+
+	return s.engine.ExpireAt(key)
+}
+
+// Scan returns page of keys starting from cursor and cursor of the next page, zero cursor starts and finishes iteration.
+// It is safe to scan while keys are modified, see engine.Engine.Scan.
+func (s *storage) Scan(cursor uint64, count int) ([]string, uint64, error) {
+	return s.engine.Scan(cursor, count)
+}
+
+// Range returns keys between start and end inclusive, it returns ErrNotOrdered if engine isn't engine.Ordered.
+func (s *storage) Range(start, end string, limit int, reverse bool) ([]string, error) {
+	ordered, ok := s.engine.(engine.Ordered)
+	if !ok {
+		return nil, ErrNotOrdered
+	}
+	return ordered.Range(start, end, limit, reverse), nil
+}
+
+// Prefix returns keys starting with prefix, it returns ErrNotOrdered if engine isn't engine.Ordered.
+func (s *storage) Prefix(prefix string, limit int, reverse bool) ([]string, error) {
+	ordered, ok := s.engine.(engine.Ordered)
+	if !ok {
+		return nil, ErrNotOrdered
+	}
+	return ordered.Prefix(prefix, limit, reverse), nil
+}
+
+// Recover loads the newest snapshot and replays write-ahead log after it into engine.
+// It must be called before storage starts serving queries.
+func (s *storage) Recover() error {
+	var from uint64
+	if s.snapshotter != nil {
+		lsn, ok, err := s.snapshotter.Load(s.load)
+		if err != nil {
+			return fmt.Errorf("storage recover fail: %w", err)
+		}
+		if ok {
+			from = lsn
+			s.lastSnapshot.Store(time.Now().UnixNano())
+		}
+	}
+
+	if s.wal == nil {
+		return nil
+	}
+
+	if err := s.wal.RecoverFrom(from, s.apply); err != nil {
+		return err
+	}
+
+	if durable, ok := s.engine.(engine.Durable); ok {
+		s.checkpointStop = make(chan struct{})
+		s.checkpointStopped = make(chan struct{})
+		go s.checkpointLoop(durable)
+	}
+
+	return nil
+}
+
+// Snapshot dumps engine to snapshot file and prunes log segments which are not needed for recovery anymore.
+// Writers are blocked only while LSN of snapshot is taken, engine is dumped concurrently with them.
+// Writes made during dump may get into snapshot, they are replayed from log again on recovery,
+// that is safe because every logged operation sets state of key or changes existing key only.
+func (s *storage) Snapshot() error {
+	if s.snapshotter == nil {
+		return ErrSnapshotsDisabled
+	}
+
+	if !s.snapshotMx.TryLock() {
+		return ErrSnapshotInProgress
+	}
+	defer s.snapshotMx.Unlock()
+
+	s.writeMx.Lock()
+	lsn := s.LastLSN()
+	changes := s.changes.Swap(0)
+	s.writeMx.Unlock()
+
+	if err := s.snapshotter.Write(lsn, s.engine.Dump); err != nil {
+		s.changes.Add(changes)
+		return fmt.Errorf("storage snapshot fail: %w", err)
+	}
+	s.lastSnapshot.Store(time.Now().UnixNano())
+
+	if s.wal == nil {
+		return nil
+	}
+
+	if err := s.prune(lsn); err != nil {
+		return fmt.Errorf("storage snapshot fail: %w", err)
+	}
+
+	return nil
+}
+
+// checkpointLoop prunes log of durable engine. Writes logged up to checkpoint are durable once memtable
+// they are applied to is flushed. Checkpoint is taken after every flush, so log keeps about one memtable.
+func (s *storage) checkpointLoop(durable engine.Durable) {
+	defer close(s.checkpointStopped)
+
+	var lsn, generation uint64
+	for {
+		select {
+		case <-s.checkpointStop:
+			return
+		case <-durable.Flushed():
+		}
+
+		// failed prune is repeated by the next checkpoint, it prunes log up to a later LSN
+		if lsn > 0 && generation <= durable.FlushedGeneration() {
+			_ = s.prune(lsn)
+		}
+
+		// all writes logged up to LSN are applied to memtable of generation or to older ones
+		s.writeMx.Lock()
+		lsn, generation = s.LastLSN(), durable.Generation()
+		s.writeMx.Unlock()
+	}
+}
+
+// prune removes log segments up to LSN. Older snapshot is kept in case the newest one is damaged,
+// so log is kept from the oldest snapshot, recovery replays it from there. Log is kept for replicas
+// from the least LSN they applied, otherwise they can't catch up. Disconnected replicas don't hold log,
+// replica which needs pruned records stops and must be seeded from master data.
+func (s *storage) prune(lsn uint64) error {
+	if s.replicas != nil {
+		if applied, ok := s.replicas.AppliedLSN(); ok {
+			lsn = min(lsn, applied)
+		}
+	}
+	if s.snapshotter != nil {
+		oldest, ok, err := s.snapshotter.OldestLSN()
+		if err != nil {
+			return err
+		}
+		if ok {
+			lsn = min(lsn, oldest)
+		}
+	}
+
+	_, err := s.wal.Prune(lsn)
+	return err
+}
+
+// ChangesSinceSnapshot returns number of writes made after the last snapshot.
+func (s *storage) ChangesSinceSnapshot() uint64 {
+	return s.changes.Load()
+}
+
+// LastSnapshotTime returns time of the last written or loaded snapshot, zero time if there is none.
+func (s *storage) LastSnapshotTime() time.Time {
+	nsec := s.lastSnapshot.Load()
+	if nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec)
+}
+
+// Close waits for snapshot in progress, flushes log and closes engine if it keeps data on disk.
+// Log of durable engine is pruned after engine flushes memtable on close.
+func (s *storage) Close() error {
+	defer s.snapshotMx.Unlock()
+	s.snapshotMx.Lock()
+
+	if s.checkpointStop != nil {
+		close(s.checkpointStop)
+		<-s.checkpointStopped
+	}
+
+	var errs []error
+	if s.wal != nil {
+		errs = append(errs, s.wal.Close())
+	}
+	if closer, ok := s.engine.(io.Closer); ok {
+		err := closer.Close()
+		errs = append(errs, err)
+
+		if _, durable := s.engine.(engine.Durable); durable && err == nil && s.wal != nil {
+			errs = append(errs, s.prune(s.LastLSN()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LastLSN returns LSN of the last logged record.
+func (s *storage) LastLSN() uint64 {
+	if s.wal == nil {
+		return 0
+	}
+
+	return s.wal.LastLSN()
+}
+
+// Stats returns engine statistics.
+func (s *storage) Stats() engine.Stats {
+	return s.engine.Stats()
+}
+
+// ApplyReplicated logs records pulled from master and applies them to engine.
+func (s *storage) ApplyReplicated(records []wal.Record) error {
+	if s.wal == nil {
+		return errors.New("storage error: replication requires wal")
+	}
+
+	defer s.writeMx.RUnlock()
+	s.writeMx.RLock()
+
+	if err := s.wal.Append(records); err != nil {
+		return fmt.Errorf("storage apply replicated fail: %w", err)
+	}
+
+	if err := s.applyMany(records); err != nil {
+		return err
+	}
+	s.changes.Add(uint64(len(records)))
+
+	return nil
+}
+
+// applyMany applies records at once, so readers see either all or none of them.
+func (s *storage) applyMany(records []wal.Record) error {
+	defer s.applyMx.Unlock()
+	s.applyMx.Lock()
+
+	for _, record := range records {
+		if err := s.apply(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply applies logged record to engine. It never evicts keys, evictions are logged as deletes,
+// so replicas and recovery get the same keys as master even if they are above memory limit.
+func (s *storage) apply(record wal.Record) error {
+	switch record.Op {
+	case wal.OpSet:
+		if len(record.Args) != 2 {
+			return fmt.Errorf("storage apply fail: invalid set record arguments: %d", len(record.Args))
+		}
+		s.engine.Set(record.Args[0], record.Args[1])
+	case wal.OpDel:
+		if len(record.Args) != 1 {
+			return fmt.Errorf("storage apply fail: invalid del record arguments: %d", len(record.Args))
+		}
+		s.engine.Del(record.Args[0])
+	case wal.OpSetWithExpiration:
+		if len(record.Args) != 3 {
+			return fmt.Errorf("storage apply fail: invalid set with expiration record arguments: %d", len(record.Args))
+		}
+		expireAt, err := wal.ParseTime(record.Args[2])
+		if err != nil {
+			return fmt.Errorf("storage apply fail: %w", err)
+		}
+		s.engine.SetWithExpiration(record.Args[0], record.Args[1], expireAt)
+	case wal.OpExpire:
+		if len(record.Args) != 2 {
+			return fmt.Errorf("storage apply fail: invalid expire record arguments: %d", len(record.Args))
+		}
+		expireAt, err := wal.ParseTime(record.Args[1])
+		if err != nil {
+			return fmt.Errorf("storage apply fail: %w", err)
+		}
+		s.engine.Expire(record.Args[0], expireAt)
+	case wal.OpPersist:
+		if len(record.Args) != 1 {
+			return fmt.Errorf("storage apply fail: invalid persist record arguments: %d", len(record.Args))
+		}
+		s.engine.Persist(record.Args[0])
+	default:
+		return fmt.Errorf("storage apply fail: unknown operation: %d", record.Op)
+	}
+
+	s.touch(record.Args[0])
+	return nil
+}
+
+// load applies snapshot item to engine.
+func (s *storage) load(item engine.Item) error {
+	if item.ExpireAt.IsZero() {
+		s.engine.Set(item.Key, item.Value)
+	} else {
+		s.engine.SetWithExpiration(item.Key, item.Value, item.ExpireAt)
+	}
+	return nil
+}
+
+// KeyVersion returns version of key which is changed by every write of key, e.g. to watch key
+// by optimistic transactions. Version is changed under key lock, so it doesn't change while key is locked by transaction.
+func (s *storage) KeyVersion(key string) uint64 {
+	return s.keyVersions[keyHash(key)%keyVersionsCount].Load()
+}
+
+func (s *storage) touch(key string) {
+	s.keyVersions[keyHash(key)%keyVersionsCount].Add(1)
+}
+
+func (s *storage) keyLock(key string) *sync.Mutex {
+	return &s.keyLocks[keyLockIndex(key)]
+}
+
+// lockKeys locks stripes of all keys in ascending order, so it doesn't deadlock with other writers.
+func (s *storage) lockKeys(keys []string) (unlock func()) {
+	indexes := make([]uint32, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, keyLockIndex(key))
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	for _, i := range indexes {
+		s.keyLocks[i].Lock()
+	}
+
+	return func() {
+		for _, i := range indexes {
+			s.keyLocks[i].Unlock()
+		}
+	}
+}
+
+func keyLockIndex(key string) uint32 {
+	return keyHash(key) % keyLocksCount
+}
+
+func keyHash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
 }
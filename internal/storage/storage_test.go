@@ -1,9 +1,22 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/MitrickX/simple-kv/internal/config"
 	"github.com/MitrickX/simple-kv/internal/storage/engine"
+	"github.com/MitrickX/simple-kv/internal/storage/engine/lsm"
+	"github.com/MitrickX/simple-kv/internal/storage/snapshot"
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
 )
 
 func TestStorage_Storage(t *testing.T) {
@@ -104,12 +117,19 @@ func TestStorage_Storage(t *testing.T) {
 			st := NewStorage(mockEng)
 
 			// Setup mock expectations
+			existing := make(map[string]bool)
 			for _, op := range tt.ops {
 				switch op.action {
 				case "set":
+					mockEng.EXPECT().Reserve(op.key, len(op.value), mock.Anything).Return(nil, nil)
 					mockEng.EXPECT().Set(op.key, op.value).Return()
+					existing[op.key] = true
 				case "del":
-					mockEng.EXPECT().Del(op.key).Return()
+					mockEng.EXPECT().ExpireAt(op.key).Return(time.Time{}, existing[op.key]).Once()
+					if existing[op.key] {
+						mockEng.EXPECT().Del(op.key).Return()
+					}
+					delete(existing, op.key)
 				}
 			}
 			for k, want := range tt.wantGet {
@@ -118,11 +138,15 @@ func TestStorage_Storage(t *testing.T) {
 
 			// Perform operations
 			for _, op := range tt.ops {
+				var err error
 				switch op.action {
 				case "set":
-					st.Set(op.key, op.value)
+					err = st.Set(op.key, op.value)
 				case "del":
-					st.Del(op.key)
+					_, err = st.Del(op.key)
+				}
+				if err != nil {
+					t.Fatalf("%s(%q) unexpected error: %v", op.action, op.key, err)
 				}
 			}
 			for k, want := range tt.wantGet {
@@ -136,3 +160,659 @@ func TestStorage_Storage(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_Recover(t *testing.T) {
+	cfg := config.ConfigWAL{
+		DataDirectory:  t.TempDir(),
+		MaxSegmentSize: config.DataSize(64),
+	}
+
+	w, err := wal.NewWAL(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create wal: %v", err)
+	}
+
+	st := NewStorage(engine.NewEngine(), WithWAL(w))
+	if err := st.Recover(); err != nil {
+		t.Fatalf("failed to recover empty storage: %v", err)
+	}
+
+	expireAt := time.Now().Add(time.Hour)
+	persisted := func(_ bool, err error) error { return err }
+	deleted := func(_ bool, err error) error { return err }
+	updated := func(_ string, err error) error { return err }
+	appendX := func(value string, _ bool) (string, error) { return value + "x", nil }
+	setIf := func(_ SetIfResult, err error) error { return err }
+	notExists := func(_ string, ok bool) bool { return !ok }
+	for _, err := range []error{
+		st.Set("a", "1"),
+		st.Set("b", "2"),
+		st.Set("c", "3"),
+		deleted(st.Del("b")),
+		st.Set("a", "4"),
+		st.SetWithExpiration("d", "5", expireAt),
+		st.SetWithExpiration("e", "6", time.Now().Add(time.Millisecond)),
+		persisted(st.Expire("c", expireAt)),
+		persisted(st.Expire("a", expireAt)),
+		persisted(st.Persist("a")),
+		updated(st.Update("d", appendX)),
+		updated(st.Update("f", appendX)),
+		setIf(st.SetIf("f", "skipped", time.Time{}, notExists)),
+		setIf(st.SetIf("g", "7", expireAt, notExists)),
+		st.MSet([]string{"h", "i", "h"}, []string{"8", "9", "10"}),
+	} {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := st.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	w, err = wal.NewWAL(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create wal: %v", err)
+	}
+
+	st = NewStorage(engine.NewEngine(), WithWAL(w))
+	if err := st.Recover(); err != nil {
+		t.Fatalf("failed to recover storage: %v", err)
+	}
+	defer st.Close()
+
+	wantGet := map[string]struct {
+		val string
+		ok  bool
+	}{
+		"a": {val: "4", ok: true},
+		"b": {val: "", ok: false},
+		"c": {val: "3", ok: true},
+		"d": {val: "5x", ok: true},
+		"e": {val: "", ok: false},
+		"f": {val: "x", ok: true},
+		"g": {val: "7", ok: true},
+		"h": {val: "10", ok: true},
+		"i": {val: "9", ok: true},
+	}
+	for k, want := range wantGet {
+		gotVal, gotOk := st.Get(k)
+		if gotVal != want.val || gotOk != want.ok {
+			t.Errorf("Get(%q) = (%q, %v), want (%q, %v)", k, gotVal, gotOk, want.val, want.ok)
+		}
+	}
+
+	wantExpireAt := map[string]time.Time{
+		"a": {},
+		"c": time.Unix(0, expireAt.UnixNano()),
+		"d": time.Unix(0, expireAt.UnixNano()),
+		"f": {},
+		"g": time.Unix(0, expireAt.UnixNano()),
+	}
+	for k, want := range wantExpireAt {
+		if got, _ := st.ExpireAt(k); !got.Equal(want) {
+			t.Errorf("ExpireAt(%q) = %v, want %v", k, got, want)
+		}
+	}
+}
+
+func TestStorage_ReadModifyWriteIsBatched(t *testing.T) {
+	const (
+		keys    = 20
+		timeout = 50 * time.Millisecond
+	)
+	w, err := wal.NewWAL(config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		FlushingBatchSize:    1000,
+		FlushingBatchTimeout: config.Timeout(timeout),
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create wal: %v", err)
+	}
+	st := NewStorage(engine.NewEngine(), WithWAL(w))
+	if err := st.Recover(); err != nil {
+		t.Fatalf("failed to recover empty storage: %v", err)
+	}
+	defer st.Close()
+
+	appendX := func(value string, _ bool) (string, error) { return value + "x", nil }
+	notExists := func(_ string, ok bool) bool { return !ok }
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < keys; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := st.Update(fmt.Sprintf("key_%d", i), appendX); err != nil {
+				t.Errorf("Update(key_%d) unexpected error: %v", i, err)
+			}
+			if _, err := st.SetIf(fmt.Sprintf("lock_%d", i), "1", time.Time{}, notExists); err != nil {
+				t.Errorf("SetIf(lock_%d) unexpected error: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// writes of different keys don't wait for each other, so they are flushed by a few batches
+	if elapsed := time.Since(start); elapsed > keys/2*timeout {
+		t.Errorf("%d concurrent writers took %v, want them to share flushes", keys, elapsed)
+	}
+}
+
+func TestStorage_RecoverFromSnapshot(t *testing.T) {
+	walCfg := config.ConfigWAL{
+		DataDirectory:  t.TempDir(),
+		MaxSegmentSize: config.DataSize(64),
+	}
+	snapshotCfg := config.ConfigSnapshot{
+		DataDirectory: t.TempDir(),
+	}
+
+	open := func() Storage {
+		w, err := wal.NewWAL(walCfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create wal: %v", err)
+		}
+		snapshotter, err := snapshot.NewSnapshotter(snapshotCfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create snapshotter: %v", err)
+		}
+		st := NewStorage(engine.NewEngine(), WithWAL(w), WithSnapshotter(snapshotter))
+		if err := st.Recover(); err != nil {
+			t.Fatalf("failed to recover storage: %v", err)
+		}
+		return st
+	}
+
+	set := func(st Storage, from, to int, value string) {
+		for i := from; i < to; i++ {
+			if err := st.Set(fmt.Sprintf("key_%d", i), value); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+
+	st := open()
+	set(st, 0, 20, "first")
+	if err := st.Snapshot(); err != nil {
+		t.Fatalf("failed to make snapshot: %v", err)
+	}
+	if got := st.ChangesSinceSnapshot(); got != 0 {
+		t.Errorf("ChangesSinceSnapshot() = %d, want 0", got)
+	}
+	set(st, 10, 30, "second")
+	if err := st.Snapshot(); err != nil {
+		t.Fatalf("failed to make snapshot: %v", err)
+	}
+	set(st, 25, 35, "third")
+	if _, err := st.Del("key_0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	// log is kept only from the older snapshot
+	segments, err := filepath.Glob(filepath.Join(walCfg.DataDirectory, "wal_*.log"))
+	if err != nil {
+		t.Fatalf("failed to list segments: %v", err)
+	}
+	if first := filepath.Base(segments[0]); first == "wal_00000000000000000001.log" {
+		t.Errorf("segments are not pruned, first segment is %s", first)
+	}
+
+	check := func(st Storage) {
+		t.Helper()
+		for i := 0; i < 35; i++ {
+			key := fmt.Sprintf("key_%d", i)
+			want := "first"
+			switch {
+			case i == 0:
+				want = ""
+			case i >= 25:
+				want = "third"
+			case i >= 10:
+				want = "second"
+			}
+			if got, _ := st.Get(key); got != want {
+				t.Errorf("Get(%q) = %q, want %q", key, got, want)
+			}
+		}
+	}
+
+	st = open()
+	check(st)
+	if err := st.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	// damaged newest snapshot is skipped, the older one and log after it give the same state
+	snapshots, err := filepath.Glob(filepath.Join(snapshotCfg.DataDirectory, "snapshot_*.snap"))
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %v (%v)", snapshots, err)
+	}
+	if err := os.WriteFile(snapshots[1], []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("failed to damage snapshot: %v", err)
+	}
+
+	st = open()
+	defer st.Close()
+	check(st)
+}
+
+func TestStorage_CheckpointDurableEngine(t *testing.T) {
+	walCfg := config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		MaxSegmentSize:       config.DataSize(256),
+		FlushingBatchTimeout: config.Timeout(time.Millisecond),
+	}
+	engineCfg := config.ConfigEngine{
+		DataDirectory: t.TempDir(),
+		MemtableSize:  config.DataSize(1 * config.KB),
+	}
+
+	open := func() Storage {
+		w, err := wal.NewWAL(walCfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create wal: %v", err)
+		}
+		eng, err := lsm.NewEngine(engineCfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to open lsm engine: %v", err)
+		}
+		st := NewStorage(eng, WithWAL(w))
+		if err := st.Recover(); err != nil {
+			t.Fatalf("failed to recover storage: %v", err)
+		}
+		return st
+	}
+
+	firstSegment := func() string {
+		segments, err := filepath.Glob(filepath.Join(walCfg.DataDirectory, "wal_*.log"))
+		if err != nil || len(segments) == 0 {
+			t.Fatalf("failed to list segments: %v (%v)", segments, err)
+		}
+		return filepath.Base(segments[0])
+	}
+
+	st := open()
+	for i := 0; i < 200; i++ {
+		if err := st.Set(fmt.Sprintf("key_%d", i), "value"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// log is pruned up to writes which are flushed to tables while storage works
+	deadline := time.Now().Add(time.Second)
+	for firstSegment() == "wal_00000000000000000001.log" {
+		if time.Now().After(deadline) {
+			t.Fatal("segments are not pruned after memtable flushes")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := st.Del("key_0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	st = open()
+	defer st.Close()
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		value, ok := st.Get(key)
+		if want := i != 0; ok != want || (ok && value != "value") {
+			t.Errorf("Get(%q) = %q, %v, want exists %v", key, value, ok, want)
+		}
+	}
+}
+
+type testReplicas struct {
+	applied uint64
+	ok      bool
+}
+
+func (r *testReplicas) AppliedLSN() (uint64, bool) {
+	return r.applied, r.ok
+}
+
+func TestStorage_SnapshotKeepsLogForReplicas(t *testing.T) {
+	w, err := wal.NewWAL(config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		MaxSegmentSize:       config.DataSize(64),
+		FlushingBatchTimeout: config.Timeout(time.Millisecond),
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create wal: %v", err)
+	}
+	snapshotter, err := snapshot.NewSnapshotter(config.ConfigSnapshot{DataDirectory: t.TempDir()}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create snapshotter: %v", err)
+	}
+	replicas := &testReplicas{applied: 5, ok: true}
+	st := NewStorage(engine.NewEngine(), WithWAL(w), WithSnapshotter(snapshotter), WithReplicas(replicas))
+	if err := st.Recover(); err != nil {
+		t.Fatalf("failed to recover storage: %v", err)
+	}
+	defer st.Close()
+
+	snapshotAll := func() {
+		t.Helper()
+		for i := 0; i < 20; i++ {
+			if err := st.Set(fmt.Sprintf("key_%d", i), "value"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		// the older snapshot limits pruning too, so both snapshots are made after writes
+		for i := 0; i < 2; i++ {
+			if err := st.Snapshot(); err != nil {
+				t.Fatalf("failed to make snapshot: %v", err)
+			}
+		}
+	}
+
+	snapshotAll()
+	if _, err := w.ReadFrom(replicas.applied+1, 1); err != nil {
+		t.Errorf("log which replica hasn't applied is pruned: %v", err)
+	}
+
+	replicas.ok = false
+	snapshotAll()
+	if _, err := w.ReadFrom(replicas.applied+1, 1); !errors.Is(err, wal.ErrPruned) {
+		t.Errorf("log isn't pruned without replicas: ReadFrom() error = %v, want %v", err, wal.ErrPruned)
+	}
+}
+
+func TestStorage_TxIsLoggedByOneWrite(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	walCfg := config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		FlushingBatchSize:    1000,
+		FlushingBatchTimeout: config.Timeout(timeout),
+	}
+
+	open := func() Storage {
+		w, err := wal.NewWAL(walCfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create wal: %v", err)
+		}
+		st := NewStorage(engine.NewEngine(), WithWAL(w))
+		if err := st.Recover(); err != nil {
+			t.Fatalf("failed to recover storage: %v", err)
+		}
+		return st
+	}
+
+	st := open()
+	if err := st.SetWithExpiration("volatile", "1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := st.Set("deleted", "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	tx := st.Begin([]string{"counter", "deleted", "volatile"})
+	for i := 0; i < 10; i++ {
+		if _, err := tx.Update("counter", func(value string, ok bool) (string, error) {
+			return value + "x", nil
+		}); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+	}
+	if ok, err := tx.Del("deleted"); !ok || err != nil {
+		t.Fatalf("Del() = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := tx.Persist("volatile"); !ok || err != nil {
+		t.Fatalf("Persist() = %v, %v, want true, nil", ok, err)
+	}
+
+	// transaction sees its writes, storage doesn't see them until commit
+	if got, _ := tx.Get("counter"); got != "xxxxxxxxxx" {
+		t.Errorf("tx Get(counter) = %q, want %q", got, "xxxxxxxxxx")
+	}
+	if _, ok := tx.Get("deleted"); ok {
+		t.Errorf("key deleted by transaction exists in it")
+	}
+	if _, ok := st.Get("counter"); ok {
+		t.Errorf("write of transaction is applied before commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*timeout {
+		t.Errorf("transaction of 12 writes took %v, want one flush of log", elapsed)
+	}
+
+	check := func(st Storage) {
+		t.Helper()
+		if got, _ := st.Get("counter"); got != "xxxxxxxxxx" {
+			t.Errorf("Get(counter) = %q, want %q", got, "xxxxxxxxxx")
+		}
+		if _, ok := st.Get("deleted"); ok {
+			t.Errorf("deleted key exists")
+		}
+		if expireAt, ok := st.ExpireAt("volatile"); !ok || !expireAt.IsZero() {
+			t.Errorf("ExpireAt(volatile) = %v, %v, want key without expiration", expireAt, ok)
+		}
+	}
+
+	check(st)
+	if err := st.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	st = open()
+	defer st.Close()
+	check(st)
+}
+
+func TestStorage_MDelIsLoggedByOneWrite(t *testing.T) {
+	const (
+		keys    = 20
+		timeout = 50 * time.Millisecond
+	)
+	walCfg := config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		FlushingBatchSize:    1000,
+		FlushingBatchTimeout: config.Timeout(timeout),
+	}
+
+	open := func() Storage {
+		w, err := wal.NewWAL(walCfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create wal: %v", err)
+		}
+		st := NewStorage(engine.NewEngine(), WithWAL(w))
+		if err := st.Recover(); err != nil {
+			t.Fatalf("failed to recover storage: %v", err)
+		}
+		return st
+	}
+
+	st := open()
+	names := make([]string, 0, keys+1)
+	values := make([]string, 0, keys)
+	for i := 0; i < keys; i++ {
+		names = append(names, fmt.Sprintf("key_%d", i))
+		values = append(values, "value")
+	}
+	if err := st.MSet(names, values); err != nil {
+		t.Fatalf("MSet() unexpected error: %v", err)
+	}
+	names = append(names, "missing", "key_0")
+
+	start := time.Now()
+	deleted, err := st.MDel(names)
+	if err != nil {
+		t.Fatalf("MDel() unexpected error: %v", err)
+	}
+	if deleted != keys {
+		t.Errorf("MDel() = %d, want %d", deleted, keys)
+	}
+	if elapsed := time.Since(start); elapsed > 3*timeout {
+		t.Errorf("delete of %d keys took %v, want one flush of log", keys, elapsed)
+	}
+
+	check := func(st Storage) {
+		t.Helper()
+		_, found := st.MGet(names)
+		for i, ok := range found {
+			if ok {
+				t.Errorf("deleted key %s exists", names[i])
+			}
+		}
+	}
+
+	check(st)
+	if err := st.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	st = open()
+	defer st.Close()
+	check(st)
+}
+
+func TestStorage_TxLocksOnlyItsKeys(t *testing.T) {
+	st := NewStorage(engine.NewEngine())
+	if err := st.Set("a", "0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	version := st.KeyVersion("a")
+
+	tx := st.Begin([]string{"a"})
+	if err := tx.Set("a", "1"); err != nil {
+		t.Fatalf("tx Set() unexpected error: %v", err)
+	}
+
+	// writers of other keys and readers don't wait for transaction
+	if err := st.Set("b", "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := st.Get("a"); got != "0" {
+		t.Errorf("Get(a) = %q, want %q before commit", got, "0")
+	}
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		if err := st.Set("a", "2"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+
+	select {
+	case <-written:
+		t.Fatalf("writer of key doesn't wait for transaction")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if st.KeyVersion("a") != version {
+		t.Errorf("version of key locked by transaction is changed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() unexpected error: %v", err)
+	}
+	<-written
+
+	if got, _ := st.Get("a"); got != "2" {
+		t.Errorf("Get(a) = %q, want write made after commit", got)
+	}
+	if st.KeyVersion("a") == version {
+		t.Errorf("version of key isn't changed by writes")
+	}
+}
+
+func TestStorage_EvictionsAreLogged(t *testing.T) {
+	const maxMemory = 1000
+	newEngine := func() engine.Engine {
+		return engine.NewEngine(engine.WithMaxMemory(maxMemory, engine.EvictionPolicyAllKeysLRU))
+	}
+	newWAL := func(dir string) *wal.WAL {
+		w, err := wal.NewWAL(config.ConfigWAL{
+			DataDirectory:        dir,
+			FlushingBatchTimeout: config.Timeout(time.Millisecond),
+		}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create wal: %v", err)
+		}
+		return w
+	}
+	items := func(st Storage) map[string]string {
+		t.Helper()
+		items := make(map[string]string)
+		var cursor uint64
+		for {
+			keys, next, err := st.Scan(cursor, 100)
+			if err != nil {
+				t.Fatalf("Scan() unexpected error: %v", err)
+			}
+			for _, key := range keys {
+				if value, ok := st.Get(key); ok {
+					items[key] = value
+				}
+			}
+			if next == 0 {
+				return items
+			}
+			cursor = next
+		}
+	}
+
+	masterDir := t.TempDir()
+	masterWAL := newWAL(masterDir)
+	master := NewStorage(newEngine(), WithWAL(masterWAL))
+	if err := master.Recover(); err != nil {
+		t.Fatalf("failed to recover storage: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := master.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d", i)); err != nil {
+			t.Fatalf("Set() unexpected error: %v", err)
+		}
+		// recently read keys are kept by LRU
+		master.Get("key_0")
+	}
+	if err := master.MSet([]string{"a", "b", "c"}, []string{"1", "2", "3"}); err != nil {
+		t.Fatalf("MSet() unexpected error: %v", err)
+	}
+	if master.Stats().Evictions == 0 {
+		t.Fatalf("no keys are evicted, test doesn't check anything")
+	}
+	want := items(master)
+	if _, ok := want["key_0"]; !ok {
+		t.Errorf("recently read key is evicted")
+	}
+
+	records, err := masterWAL.ReadFrom(1, 1000)
+	if err != nil {
+		t.Fatalf("ReadFrom() unexpected error: %v", err)
+	}
+	replica := NewStorage(newEngine(), WithWAL(newWAL(t.TempDir())), WithReadOnly())
+	if err := replica.Recover(); err != nil {
+		t.Fatalf("failed to recover replica: %v", err)
+	}
+	defer replica.Close()
+	if err := replica.ApplyReplicated(records); err != nil {
+		t.Fatalf("ApplyReplicated() unexpected error: %v", err)
+	}
+	if got := items(replica); !reflect.DeepEqual(got, want) {
+		t.Errorf("replica keys = %v, want keys of master %v", got, want)
+	}
+
+	if err := master.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+	recovered := NewStorage(newEngine(), WithWAL(newWAL(masterDir)))
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("failed to recover storage: %v", err)
+	}
+	defer recovered.Close()
+	if got := items(recovered); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered keys = %v, want keys of master %v", got, want)
+	}
+}
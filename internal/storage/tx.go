@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/storage/wal"
+)
+
+// Tx is a transaction of storage. Writes of transaction are kept in memory and its reads see them,
+// Commit logs all of them by one write and applies them to engine after log is flushed once.
+// Key listings (Scan, Range, Prefix) don't see writes of transaction, other methods go to storage as is.
+//
+// Transaction locks its keys from Begin until Commit or Discard, so other writers of them wait for it
+// and versions of them don't change. Transaction must access only keys it is begun with.
+type Tx struct {
+	Storage
+	s       *storage
+	entries map[string]txEntry
+	records []wal.Record
+	ev      *evictor
+	unlock  func()
+}
+
+// txEntry is a state of key written by transaction.
+type txEntry struct {
+	value string
+	// zero time means key never expires
+	expireAt time.Time
+	deleted  bool
+}
+
+// Begin starts transaction of keys and locks them, transaction must be finished by Commit or Discard.
+func (s *storage) Begin(keys []string) *Tx {
+	s.writeMx.RLock()
+	unlock := s.lockKeys(keys)
+	ev := s.newEvictor(keys...)
+
+	return &Tx{
+		Storage: s,
+		s:       s,
+		entries: make(map[string]txEntry),
+		ev:      ev,
+		unlock: func() {
+			ev.unlock()
+			unlock()
+			s.writeMx.RUnlock()
+		},
+	}
+}
+
+func (tx *Tx) Set(key, value string) error {
+	return tx.SetWithExpiration(key, value, time.Time{})
+}
+
+// SetWithExpiration sets key which expires at expireAt, zero time means key never expires.
+func (tx *Tx) SetWithExpiration(key, value string, expireAt time.Time) error {
+	if tx.s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := tx.reserve(key, len(value)); err != nil {
+		return fmt.Errorf("storage set fail: %w", err)
+	}
+
+	tx.set(key, value, expireAt)
+	return nil
+}
+
+func (tx *Tx) Get(key string) (string, bool) {
+	en, ok := tx.lookup(key)
+	return en.value, ok
+}
+
+func (tx *Tx) MGet(keys []string) ([]string, []bool) {
+	values := make([]string, len(keys))
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = tx.Get(key)
+	}
+	return values, found
+}
+
+// Del deletes key. It returns false if key doesn't exist, then nothing is logged.
+func (tx *Tx) Del(key string) (bool, error) {
+	if tx.s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	if _, ok := tx.lookup(key); !ok {
+		return false, nil
+	}
+
+	tx.records = append(tx.records, wal.Record{Op: wal.OpDel, Args: []string{key}})
+	tx.entries[key] = txEntry{deleted: true}
+	return true, nil
+}
+
+// MDel deletes keys and returns number of deleted ones.
+func (tx *Tx) MDel(keys []string) (int, error) {
+	var deleted int
+	for _, key := range keys {
+		ok, err := tx.Del(key)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// MSet sets keys to values of the same index. Memory is reserved for all keys before any of them is set,
+// so failure leaves all keys unchanged.
+func (tx *Tx) MSet(keys, values []string) error {
+	if tx.s.readOnly {
+		return ErrReadOnly
+	}
+
+	for i, key := range keys {
+		if err := tx.reserve(key, len(values[i])); err != nil {
+			return fmt.Errorf("storage mset fail: %w", err)
+		}
+	}
+
+	for i, key := range keys {
+		tx.set(key, values[i], time.Time{})
+	}
+	return nil
+}
+
+// Update replaces value of key by result of fn, key keeps its expiration time. Errors of fn are returned as is.
+func (tx *Tx) Update(key string, fn UpdateFunc) (string, error) {
+	if tx.s.readOnly {
+		return "", ErrReadOnly
+	}
+
+	current, ok := tx.lookup(key)
+	value, err := fn(current.value, ok)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.reserve(key, len(value)); err != nil {
+		return "", fmt.Errorf("storage update fail: %w", err)
+	}
+
+	tx.set(key, value, current.expireAt)
+	return value, nil
+}
+
+// SetIf sets value of key with expiration time if cond is true, zero time means key never expires.
+func (tx *Tx) SetIf(key, value string, expireAt time.Time, cond Condition) (SetIfResult, error) {
+	if tx.s.readOnly {
+		return SetIfResult{}, ErrReadOnly
+	}
+
+	current, ok := tx.lookup(key)
+	result := SetIfResult{Value: current.value, Exists: ok}
+	if !cond(result.Value, result.Exists) {
+		return result, nil
+	}
+
+	if err := tx.reserve(key, len(value)); err != nil {
+		return SetIfResult{}, fmt.Errorf("storage set fail: %w", err)
+	}
+
+	tx.set(key, value, expireAt)
+	result.Applied = true
+	return result, nil
+}
+
+// Expire sets expiration time of key. It returns false if key doesn't exist.
+func (tx *Tx) Expire(key string, expireAt time.Time) (bool, error) {
+	if tx.s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	en, ok := tx.lookup(key)
+	if !ok {
+		return false, nil
+	}
+
+	tx.records = append(tx.records, wal.Record{Op: wal.OpExpire, Args: []string{key, wal.FormatTime(expireAt)}})
+	if !expireAt.After(time.Now()) {
+		tx.entries[key] = txEntry{deleted: true}
+	} else {
+		en.expireAt = expireAt
+		tx.entries[key] = en
+	}
+	return true, nil
+}
+
+// Persist removes expiration of key. It returns false if key doesn't exist or has no expiration.
+func (tx *Tx) Persist(key string) (bool, error) {
+	if tx.s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	en, ok := tx.lookup(key)
+	if !ok || en.expireAt.IsZero() {
+		return false, nil
+	}
+
+	tx.records = append(tx.records, wal.Record{Op: wal.OpPersist, Args: []string{key}})
+	en.expireAt = time.Time{}
+	tx.entries[key] = en
+	return true, nil
+}
+
+// ExpireAt returns expiration time of key, zero time means key never expires.
+func (tx *Tx) ExpireAt(key string) (time.Time, bool) {
+	en, ok := tx.lookup(key)
+	return en.expireAt, ok
+}
+
+// Snapshot can't be taken inside transaction, it would wait for transaction to finish.
+func (tx *Tx) Snapshot() error {
+	return ErrSnapshotInTx
+}
+
+// Commit logs writes of transaction by one write and applies them to engine at once, so readers
+// don't see part of them. If log fails, nothing is applied. Keys of transaction are unlocked after it.
+func (tx *Tx) Commit() error {
+	defer tx.unlock()
+
+	if len(tx.records) == 0 {
+		return nil
+	}
+
+	s := tx.s
+	if s.wal != nil {
+		if err := s.wal.WriteMany(tx.records); err != nil {
+			return fmt.Errorf("storage commit fail: %w", err)
+		}
+	}
+
+	if err := s.applyMany(tx.records); err != nil {
+		return err
+	}
+	s.changes.Add(uint64(len(tx.records)))
+	return nil
+}
+
+// Discard unlocks keys of transaction without applying its writes.
+func (tx *Tx) Discard() {
+	tx.unlock()
+}
+
+// reserve makes room for value of key, deletes of evicted keys are logged by transaction before its next writes,
+// even if memory isn't reserved, because keys are already deleted from engine.
+func (tx *Tx) reserve(key string, valueSize int) error {
+	err := tx.ev.reserve(key, valueSize)
+	for _, record := range tx.ev.take() {
+		tx.records = append(tx.records, record)
+		tx.entries[record.Args[0]] = txEntry{deleted: true}
+	}
+	return err
+}
+
+func (tx *Tx) set(key, value string, expireAt time.Time) {
+	tx.records = append(tx.records, setRecord(key, value, expireAt))
+	tx.entries[key] = txEntry{value: value, expireAt: expireAt}
+}
+
+// lookup returns state of key written by transaction or stored one, expired key doesn't exist.
+func (tx *Tx) lookup(key string) (txEntry, bool) {
+	if en, ok := tx.entries[key]; ok {
+		if en.deleted || (!en.expireAt.IsZero() && !en.expireAt.After(time.Now())) {
+			return txEntry{}, false
+		}
+		return en, true
+	}
+
+	value, ok := tx.s.engine.Get(key)
+	expireAt, alive := tx.s.engine.ExpireAt(key)
+	if !ok || !alive {
+		return txEntry{}, false
+	}
+	return txEntry{value: value, expireAt: expireAt}, true
+}
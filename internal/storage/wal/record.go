@@ -0,0 +1,124 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+type Op byte
+
+const (
+	OpSet Op = iota + 1
+	OpDel
+	OpSetWithExpiration
+	OpExpire
+	OpPersist
+)
+
+const recordHeaderSize = 8
+
+var (
+	ErrCorruptedRecord = errors.New("wal error: corrupted record")
+)
+
+// Record is a single logged mutation. LSN is a log sequence number assigned by WAL,
+// it grows monotonically across segments.
+type Record struct {
+	LSN  uint64
+	Op   Op
+	Args []string
+}
+
+// encode writes record as frame: payload length (4 bytes), crc32 of payload (4 bytes), payload.
+// Payload: LSN (8 bytes), op (1 byte), number of args (uvarint) and args each prefixed with its length (uvarint).
+func (r Record) encode(buf []byte) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, recordHeaderSize)...)
+
+	buf = binary.BigEndian.AppendUint64(buf, r.LSN)
+	buf = append(buf, byte(r.Op))
+	buf = binary.AppendUvarint(buf, uint64(len(r.Args)))
+	for _, arg := range r.Args {
+		buf = binary.AppendUvarint(buf, uint64(len(arg)))
+		buf = append(buf, arg...)
+	}
+
+	payload := buf[start+recordHeaderSize:]
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[start+4:], crc32.ChecksumIEEE(payload))
+
+	return buf
+}
+
+// decodeRecord reads next record from reader, which has remaining bytes left.
+// Returns io.EOF if there are no more records and ErrCorruptedRecord if record is partially written or damaged,
+// e.g. if its length exceeds remaining bytes, so damaged length doesn't make it allocate a huge buffer.
+func decodeRecord(r io.Reader, remaining int64) (Record, int, error) {
+	var header [recordHeaderSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return Record{}, 0, io.EOF
+		}
+		return Record{}, n, ErrCorruptedRecord
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+
+	if int64(size) > remaining-recordHeaderSize {
+		return Record{}, n, fmt.Errorf("%w: length %d exceeds %d bytes left", ErrCorruptedRecord, size, remaining-recordHeaderSize)
+	}
+
+	payload := make([]byte, size)
+	m, err := io.ReadFull(r, payload)
+	n += m
+	if err != nil {
+		return Record{}, n, ErrCorruptedRecord
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return Record{}, n, ErrCorruptedRecord
+	}
+
+	record, err := decodePayload(payload)
+	if err != nil {
+		return Record{}, n, err
+	}
+
+	return record, n, nil
+}
+
+func decodePayload(payload []byte) (Record, error) {
+	if len(payload) < 9 {
+		return Record{}, ErrCorruptedRecord
+	}
+
+	record := Record{
+		LSN: binary.BigEndian.Uint64(payload[0:8]),
+		Op:  Op(payload[8]),
+	}
+	payload = payload[9:]
+
+	argc, n := binary.Uvarint(payload)
+	if n <= 0 || argc > uint64(len(payload)) {
+		return Record{}, fmt.Errorf("%w: invalid arguments count", ErrCorruptedRecord)
+	}
+	payload = payload[n:]
+
+	record.Args = make([]string, 0, argc)
+	for i := uint64(0); i < argc; i++ {
+		size, n := binary.Uvarint(payload)
+		if n <= 0 || size > uint64(len(payload)-n) {
+			return Record{}, fmt.Errorf("%w: invalid argument length", ErrCorruptedRecord)
+		}
+		payload = payload[n:]
+		record.Args = append(record.Args, string(payload[:size]))
+		payload = payload[size:]
+	}
+
+	return record, nil
+}
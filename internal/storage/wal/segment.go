@@ -0,0 +1,109 @@
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentPrefix = "wal_"
+	segmentSuffix = ".log"
+)
+
+// segment is a log file. Its name contains LSN of the first record, so segments are ordered by name.
+type segment struct {
+	path     string
+	firstLSN uint64
+}
+
+func segmentName(firstLSN uint64) string {
+	return fmt.Sprintf("%s%020d%s", segmentPrefix, firstLSN, segmentSuffix)
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+		return 0, false
+	}
+
+	lsn, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return lsn, true
+}
+
+// listSegments returns segments of directory ordered by first LSN.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]segment, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		lsn, ok := parseSegmentName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		segments = append(segments, segment{
+			path:     filepath.Join(dir, entry.Name()),
+			firstLSN: lsn,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].firstLSN < segments[j].firstLSN
+	})
+
+	return segments, nil
+}
+
+// readSegment appends to records those segment records which LSN is in [from, to], until there are limit records.
+func readSegment(seg segment, from, to uint64, limit int, records []Record) ([]Record, error) {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return nil, fmt.Errorf("wal error: can't open segment: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("wal error: can't stat segment: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(file, readBufSize)
+	remaining := info.Size()
+	for len(records) < limit {
+		record, n, err := decodeRecord(reader, remaining)
+		// the tail of active segment may be not fully written yet
+		if errors.Is(err, io.EOF) || errors.Is(err, ErrCorruptedRecord) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("wal error: can't read segment %s: %w", seg.path, err)
+		}
+		remaining -= int64(n)
+
+		if record.LSN > to {
+			break
+		}
+		if record.LSN >= from {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,525 @@
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxSegmentSize       = 10 * config.MB
+	defaultFlushingBatchSize    = 100
+	defaultFlushingBatchTimeout = 10 * time.Millisecond
+	readBufSize                 = 64 * config.KB
+)
+
+var (
+	ErrNotRecovered = errors.New("wal error: log must be recovered before writing")
+	ErrClosed       = errors.New("wal error: log is closed")
+	ErrLSNGap       = errors.New("wal error: replicated records don't continue log")
+	ErrPruned       = errors.New("wal error: requested records are pruned")
+)
+
+type writeRequest struct {
+	// local records, LSNs are assigned on write
+	records []Record
+	// records received from master, they keep their LSNs
+	replicated []Record
+	done       chan error
+}
+
+// WAL is a write-ahead log split into segment files of limited size.
+// Concurrent writes are grouped into batches, each batch is written and synced at once (group commit).
+type WAL struct {
+	mx                   sync.RWMutex
+	dir                  string
+	maxSegmentSize       int64
+	fsync                bool
+	flushingBatchSize    int
+	flushingBatchTimeout time.Duration
+	logger               *zap.Logger
+
+	requests chan writeRequest
+	stop     chan struct{}
+	stopped  chan struct{}
+
+	// file state is owned by flushing goroutine after recovery
+	file     *os.File
+	fileSize int64
+	lastLSN  atomic.Uint64
+
+	recovered bool
+	closed    bool
+}
+
+func NewWAL(cfg config.ConfigWAL, logger *zap.Logger) (*WAL, error) {
+	if cfg.DataDirectory == "" {
+		return nil, errors.New("wal error: data directory is not set")
+	}
+
+	if err := os.MkdirAll(cfg.DataDirectory, 0o755); err != nil {
+		return nil, fmt.Errorf("wal error: can't create data directory: %w", err)
+	}
+
+	maxSegmentSize := int64(cfg.MaxSegmentSize)
+	if maxSegmentSize <= 0 {
+		maxSegmentSize = defaultMaxSegmentSize
+	}
+
+	flushingBatchSize := cfg.FlushingBatchSize
+	if flushingBatchSize <= 0 {
+		flushingBatchSize = defaultFlushingBatchSize
+	}
+
+	flushingBatchTimeout := time.Duration(cfg.FlushingBatchTimeout)
+	if flushingBatchTimeout <= 0 {
+		flushingBatchTimeout = defaultFlushingBatchTimeout
+	}
+
+	return &WAL{
+		dir:                  cfg.DataDirectory,
+		maxSegmentSize:       maxSegmentSize,
+		fsync:                cfg.Fsync,
+		flushingBatchSize:    flushingBatchSize,
+		flushingBatchTimeout: flushingBatchTimeout,
+		logger:               logger,
+		requests:             make(chan writeRequest),
+		stop:                 make(chan struct{}),
+		stopped:              make(chan struct{}),
+	}, nil
+}
+
+// Recover reads all segments in order and calls apply for every record.
+// Partially written tail of the last segment (e.g. after crash) is truncated.
+// After recovery WAL starts accepting writes.
+func (w *WAL) Recover(apply func(Record) error) error {
+	return w.RecoverFrom(0, apply)
+}
+
+// RecoverFrom recovers log like Recover, but records up to LSN from are not applied, e.g. they are in snapshot.
+// New records continue numbering after from even if log doesn't contain these records anymore.
+func (w *WAL) RecoverFrom(from uint64, apply func(Record) error) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.recovered {
+		return errors.New("wal error: log is already recovered")
+	}
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return fmt.Errorf("wal error: can't list segments: %w", err)
+	}
+
+	for i, seg := range segments {
+		last := i == len(segments)-1
+
+		// all records of segment are before from if next segment starts not later than from
+		if !last && segments[i+1].firstLSN <= from+1 {
+			continue
+		}
+
+		size, err := w.recoverSegment(seg, last, from, apply)
+		if err != nil {
+			return err
+		}
+
+		if last && size < w.maxSegmentSize {
+			file, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return fmt.Errorf("wal error: can't open segment: %w", err)
+			}
+			w.file = file
+			w.fileSize = size
+		}
+	}
+
+	if w.lastLSN.Load() < from {
+		w.lastLSN.Store(from)
+	}
+
+	w.recovered = true
+	go w.loop()
+
+	w.logger.Info("wal recovered",
+		zap.String("dir", w.dir),
+		zap.Int("segments", len(segments)),
+		zap.Uint64("lastLSN", w.lastLSN.Load()),
+	)
+
+	return nil
+}
+
+func (w *WAL) recoverSegment(seg segment, last bool, from uint64, apply func(Record) error) (int64, error) {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return 0, fmt.Errorf("wal error: can't open segment: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("wal error: can't stat segment: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(file, readBufSize)
+
+	var offset int64
+	for {
+		record, n, err := decodeRecord(reader, info.Size()-offset)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if errors.Is(err, ErrCorruptedRecord) && last {
+			w.logger.Warn("wal segment has corrupted tail, truncate it",
+				zap.String("segment", seg.path),
+				zap.Int64("offset", offset),
+			)
+			if err := os.Truncate(seg.path, offset); err != nil {
+				return 0, fmt.Errorf("wal error: can't truncate segment: %w", err)
+			}
+			break
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("wal error: can't read segment %s: %w", seg.path, err)
+		}
+
+		if record.LSN > from {
+			if err := apply(record); err != nil {
+				return 0, fmt.Errorf("wal error: can't apply record %d: %w", record.LSN, err)
+			}
+		}
+
+		offset += int64(n)
+		w.lastLSN.Store(record.LSN)
+	}
+
+	return offset, nil
+}
+
+// Set logs set operation. It returns after the batch containing the record is written to disk.
+func (w *WAL) Set(key, value string) error {
+	return w.write(OpSet, key, value)
+}
+
+// Del logs del operation. It returns after the batch containing the record is written to disk.
+func (w *WAL) Del(key string) error {
+	return w.write(OpDel, key)
+}
+
+// SetWithExpiration logs set operation of key which expires at expireAt.
+func (w *WAL) SetWithExpiration(key, value string, expireAt time.Time) error {
+	return w.write(OpSetWithExpiration, key, value, FormatTime(expireAt))
+}
+
+// Expire logs change of key expiration time.
+func (w *WAL) Expire(key string, expireAt time.Time) error {
+	return w.write(OpExpire, key, FormatTime(expireAt))
+}
+
+// Persist logs removal of key expiration.
+func (w *WAL) Persist(key string) error {
+	return w.write(OpPersist, key)
+}
+
+// SetMany logs set operations of keys with values of the same index, records follow each other in log.
+func (w *WAL) SetMany(keys, values []string) error {
+	records := make([]Record, len(keys))
+	for i := range keys {
+		records[i] = Record{Op: OpSet, Args: []string{keys[i], values[i]}}
+	}
+
+	return w.WriteMany(records)
+}
+
+// WriteMany logs records by one write, they follow each other in log and are flushed by the same batch.
+// LSNs of records are assigned by log.
+func (w *WAL) WriteMany(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return w.send(writeRequest{
+		records: records,
+		done:    make(chan error, 1),
+	})
+}
+
+// Append logs records replicated from another log as is, their LSNs must continue this log.
+func (w *WAL) Append(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return w.send(writeRequest{
+		replicated: records,
+		done:       make(chan error, 1),
+	})
+}
+
+// ReadFrom returns up to limit records starting with LSN from, reading segments written so far.
+func (w *WAL) ReadFrom(from uint64, limit int) ([]Record, error) {
+	lastLSN := w.lastLSN.Load()
+	if from > lastLSN {
+		return nil, nil
+	}
+
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("wal error: can't list segments: %w", err)
+	}
+
+	// records before the first segment are pruned
+	firstLSN := lastLSN + 1
+	if len(segments) > 0 {
+		firstLSN = segments[0].firstLSN
+	}
+	if from < firstLSN {
+		return nil, fmt.Errorf("%w: first available record %d", ErrPruned, firstLSN)
+	}
+
+	// skip segments which contain only records before from
+	start := 0
+	for i, seg := range segments {
+		if seg.firstLSN <= from {
+			start = i
+		}
+	}
+
+	records := make([]Record, 0, min(limit, int(lastLSN-from+1)))
+	for i := start; i < len(segments) && len(records) < limit; i++ {
+		var err error
+		records, err = readSegment(segments[i], from, lastLSN, limit, records)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// Prune removes segments which contain only records up to LSN to, the active segment is never removed.
+// It returns number of removed segments.
+func (w *WAL) Prune(to uint64) (int, error) {
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return 0, fmt.Errorf("wal error: can't list segments: %w", err)
+	}
+
+	removed := 0
+	for i := 0; i < len(segments)-1; i++ {
+		if segments[i+1].firstLSN > to+1 {
+			break
+		}
+		if err := os.Remove(segments[i].path); err != nil {
+			return removed, fmt.Errorf("wal error: can't remove segment: %w", err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		w.logger.Info("wal segments pruned", zap.Int("segments", removed), zap.Uint64("lsn", to))
+	}
+
+	return removed, nil
+}
+
+// LastLSN returns LSN of the last written record.
+func (w *WAL) LastLSN() uint64 {
+	return w.lastLSN.Load()
+}
+
+// Close flushes pending batch and closes current segment.
+func (w *WAL) Close() error {
+	w.mx.Lock()
+	if w.closed {
+		w.mx.Unlock()
+		return nil
+	}
+	w.closed = true
+	recovered := w.recovered
+	w.mx.Unlock()
+
+	if recovered {
+		close(w.stop)
+		<-w.stopped
+	}
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.file.Close()
+}
+
+func (w *WAL) write(op Op, args ...string) error {
+	return w.send(writeRequest{
+		records: []Record{{Op: op, Args: args}},
+		done:    make(chan error, 1),
+	})
+}
+
+func (w *WAL) send(req writeRequest) error {
+	// read lock prevents Close from stopping flushing goroutine while request is being sent
+	w.mx.RLock()
+	if w.closed {
+		w.mx.RUnlock()
+		return ErrClosed
+	}
+	if !w.recovered {
+		w.mx.RUnlock()
+		return ErrNotRecovered
+	}
+	w.requests <- req
+	w.mx.RUnlock()
+
+	return <-req.done
+}
+
+// loop collects write requests into batches and flushes them when batch is full or on timeout.
+func (w *WAL) loop() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.flushingBatchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]writeRequest, 0, w.flushingBatchSize)
+	for {
+		select {
+		case req := <-w.requests:
+			batch = append(batch, req)
+			if len(batch) >= w.flushingBatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-w.stop:
+			if len(batch) > 0 {
+				w.flush(batch)
+			}
+			return
+		}
+	}
+}
+
+func (w *WAL) flush(batch []writeRequest) {
+	err := w.writeBatch(batch)
+	if err != nil {
+		w.logger.Error("wal failed to flush batch", zap.Int("size", len(batch)), zap.Error(err))
+	}
+
+	for _, req := range batch {
+		req.done <- err
+	}
+}
+
+func (w *WAL) writeBatch(batch []writeRequest) error {
+	lastLSN := w.lastLSN.Load()
+
+	if err := w.rotateIfNeeded(lastLSN + 1); err != nil {
+		return err
+	}
+
+	var buf []byte
+	lsn := lastLSN
+	for _, req := range batch {
+		if req.replicated != nil {
+			for _, record := range req.replicated {
+				if record.LSN != lsn+1 {
+					return fmt.Errorf("%w: expected LSN %d, got %d", ErrLSNGap, lsn+1, record.LSN)
+				}
+				buf = record.encode(buf)
+				lsn++
+			}
+			continue
+		}
+
+		for _, record := range req.records {
+			lsn++
+			record.LSN = lsn
+			buf = record.encode(buf)
+		}
+	}
+
+	if _, err := w.file.Write(buf); err != nil {
+		// cut off partially written batch, so next records don't follow garbage
+		_ = w.file.Truncate(w.fileSize)
+		return fmt.Errorf("wal error: can't write batch: %w", err)
+	}
+
+	if w.fsync {
+		if err := w.file.Sync(); err != nil {
+			// writers of batch get error, so its records are cut off and their LSNs are given to the next batch
+			_ = w.file.Truncate(w.fileSize)
+			return fmt.Errorf("wal error: can't sync segment: %w", err)
+		}
+	}
+
+	// LSN is published after records are on disk, so snapshots and slaves never see records which may be lost
+	w.fileSize += int64(len(buf))
+	w.lastLSN.Store(lsn)
+
+	return nil
+}
+
+func (w *WAL) rotateIfNeeded(nextLSN uint64) error {
+	if w.file != nil && w.fileSize < w.maxSegmentSize {
+		return nil
+	}
+
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("wal error: can't close segment: %w", err)
+		}
+		w.file = nil
+	}
+
+	path := filepath.Join(w.dir, segmentName(nextLSN))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("wal error: can't create segment: %w", err)
+	}
+
+	w.logger.Debug("wal segment created", zap.String("segment", path))
+
+	w.file = file
+	w.fileSize = 0
+
+	return nil
+}
+
+// FormatTime formats expiration time argument of record. Expiration time is logged as absolute unix time
+// in nanoseconds, so replay doesn't prolong keys life.
+func FormatTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// ParseTime parses expiration time argument of record.
+func ParseTime(arg string) (time.Time, error) {
+	nsec, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid time: %s", ErrCorruptedRecord, arg)
+	}
+
+	return time.Unix(0, nsec), nil
+}
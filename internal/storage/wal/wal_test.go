@@ -0,0 +1,268 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MitrickX/simple-kv/internal/config"
+	"go.uber.org/zap"
+)
+
+func newTestWAL(t *testing.T, cfg config.ConfigWAL) *WAL {
+	t.Helper()
+
+	w, err := NewWAL(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create wal: %v", err)
+	}
+
+	return w
+}
+
+func recoverAll(t *testing.T, w *WAL) []Record {
+	t.Helper()
+
+	var records []Record
+	err := w.Recover(func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to recover wal: %v", err)
+	}
+
+	return records
+}
+
+func TestWAL_WriteRecover(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxSegmentSize config.DataSize
+		wantSegments   int
+	}{
+		{
+			name:           "single segment",
+			maxSegmentSize: config.DataSize(config.MB),
+			wantSegments:   1,
+		},
+		{
+			name:           "segment per write",
+			maxSegmentSize: config.DataSize(1),
+			wantSegments:   5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.ConfigWAL{
+				DataDirectory:  t.TempDir(),
+				MaxSegmentSize: tt.maxSegmentSize,
+			}
+
+			w := newTestWAL(t, cfg)
+			recoverAll(t, w)
+
+			for _, err := range []error{
+				w.Set("foo", "bar"),
+				w.Set("with space", ""),
+				w.Del("foo"),
+				w.Set("foo", "baz"),
+				w.SetMany([]string{"a", "b"}, []string{"1", "2"}),
+			} {
+				if err != nil {
+					t.Fatalf("unexpected write error: %v", err)
+				}
+			}
+			w.Close()
+
+			segments, err := listSegments(cfg.DataDirectory)
+			if err != nil {
+				t.Fatalf("failed to list segments: %v", err)
+			}
+			if len(segments) != tt.wantSegments {
+				t.Errorf("got %d segments, want %d", len(segments), tt.wantSegments)
+			}
+
+			w = newTestWAL(t, cfg)
+			defer w.Close()
+
+			want := []Record{
+				{LSN: 1, Op: OpSet, Args: []string{"foo", "bar"}},
+				{LSN: 2, Op: OpSet, Args: []string{"with space", ""}},
+				{LSN: 3, Op: OpDel, Args: []string{"foo"}},
+				{LSN: 4, Op: OpSet, Args: []string{"foo", "baz"}},
+				{LSN: 5, Op: OpSet, Args: []string{"a", "1"}},
+				{LSN: 6, Op: OpSet, Args: []string{"b", "2"}},
+			}
+			if got := recoverAll(t, w); !reflect.DeepEqual(got, want) {
+				t.Errorf("recovered records = %+v, want %+v", got, want)
+			}
+
+			if err := w.Set("next", "lsn"); err != nil {
+				t.Fatalf("unexpected write error: %v", err)
+			}
+			if got := w.LastLSN(); got != 7 {
+				t.Errorf("LastLSN() = %d, want 7", got)
+			}
+		})
+	}
+}
+
+func TestWAL_RecoverTruncatesCorruptedTail(t *testing.T) {
+	cfg := config.ConfigWAL{DataDirectory: t.TempDir()}
+
+	w := newTestWAL(t, cfg)
+	recoverAll(t, w)
+	if err := w.Set("foo", "bar"); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	w.Close()
+
+	// emulate crash in the middle of record write
+	segments, err := listSegments(cfg.DataDirectory)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected single segment, got %v, %v", segments, err)
+	}
+	partial := Record{LSN: 2, Op: OpSet, Args: []string{"lost", "value"}}.encode(nil)
+	file, err := os.OpenFile(segments[0].path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	file.Write(partial[:len(partial)-3])
+	file.Close()
+
+	w = newTestWAL(t, cfg)
+	want := []Record{{LSN: 1, Op: OpSet, Args: []string{"foo", "bar"}}}
+	if got := recoverAll(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered records = %+v, want %+v", got, want)
+	}
+
+	if err := w.Set("baz", "qux"); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	w.Close()
+
+	w = newTestWAL(t, cfg)
+	defer w.Close()
+	want = append(want, Record{LSN: 2, Op: OpSet, Args: []string{"baz", "qux"}})
+	if got := recoverAll(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered records = %+v, want %+v", got, want)
+	}
+}
+
+func TestWAL_RecoverRejectsHugeRecordLength(t *testing.T) {
+	cfg := config.ConfigWAL{DataDirectory: t.TempDir()}
+
+	w := newTestWAL(t, cfg)
+	recoverAll(t, w)
+	if err := w.Set("foo", "bar"); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	w.Close()
+
+	// damaged header claims record of 4GB
+	segments, err := listSegments(cfg.DataDirectory)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected single segment, got %v, %v", segments, err)
+	}
+	header := make([]byte, recordHeaderSize)
+	binary.BigEndian.PutUint32(header, math.MaxUint32)
+	file, err := os.OpenFile(segments[0].path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	file.Write(append(header, "tail"...))
+	file.Close()
+
+	_, _, err = decodeRecord(bytes.NewReader(append(header, "tail"...)), int64(len(header)+4))
+	if !errors.Is(err, ErrCorruptedRecord) {
+		t.Errorf("decodeRecord() error = %v, want %v", err, ErrCorruptedRecord)
+	}
+
+	w = newTestWAL(t, cfg)
+	defer w.Close()
+	want := []Record{{LSN: 1, Op: OpSet, Args: []string{"foo", "bar"}}}
+	if got := recoverAll(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered records = %+v, want %+v", got, want)
+	}
+	if got, err := w.ReadFrom(1, 10); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadFrom() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestWAL_WriteBeforeRecover(t *testing.T) {
+	w := newTestWAL(t, config.ConfigWAL{DataDirectory: t.TempDir()})
+	defer w.Close()
+
+	if err := w.Set("foo", "bar"); err != ErrNotRecovered {
+		t.Errorf("Set() error = %v, want %v", err, ErrNotRecovered)
+	}
+}
+
+func TestWAL_GroupCommit(t *testing.T) {
+	const writers = 10
+
+	cfg := config.ConfigWAL{
+		DataDirectory:        t.TempDir(),
+		FlushingBatchSize:    writers,
+		FlushingBatchTimeout: config.Timeout(time.Hour),
+	}
+
+	w := newTestWAL(t, cfg)
+	recoverAll(t, w)
+
+	// batch is flushed as soon as it is full, writers must not wait for timeout
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- w.Set(fmt.Sprintf("key_%d", i), "value")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected write error: %v", err)
+		}
+	}
+	if got := w.LastLSN(); got != writers {
+		t.Errorf("LastLSN() = %d, want %d", got, writers)
+	}
+
+	// not full batch is flushed on close
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Del("key_0")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close wal: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+
+	w = newTestWAL(t, cfg)
+	defer w.Close()
+	records := recoverAll(t, w)
+	if len(records) != writers+1 {
+		t.Fatalf("recovered %d records, want %d", len(records), writers+1)
+	}
+	for i, record := range records {
+		if record.LSN != uint64(i+1) {
+			t.Errorf("record %d has LSN %d, want %d", i, record.LSN, i+1)
+		}
+	}
+}
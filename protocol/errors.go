@@ -0,0 +1,63 @@
+package protocol
+
+// Codes of server errors, they are sent in error responses, so clients match errors by codes, not by messages.
+const (
+	CodeNoTokensInQuery       = "NO_TOKENS_IN_QUERY"
+	CodeUnknownCommandType    = "UNKNOWN_COMMAND"
+	CodeInvalidArgumentFormat = "INVALID_ARGUMENT_FORMAT"
+	CodeInvalidExpireTime     = "INVALID_EXPIRE_TIME"
+	CodeInvalidCursor         = "INVALID_CURSOR"
+	CodeInvalidCount          = "INVALID_COUNT"
+	CodeUnknownOption         = "UNKNOWN_OPTION"
+	CodeInvalidLimit          = "INVALID_LIMIT"
+	CodeInvalidIncrement      = "INVALID_INCREMENT"
+	CodeConflictingOptions    = "CONFLICTING_OPTIONS"
+	CodeTooManyArguments      = "TOO_MANY_ARGUMENTS"
+
+	CodeNoEnoughArgumentsForSetCommand         = "NO_ENOUGH_ARGUMENTS_SET"
+	CodeNoEnoughArgumentsForGetCommand         = "NO_ENOUGH_ARGUMENTS_GET"
+	CodeNoEnoughArgumentsForDelCommand         = "NO_ENOUGH_ARGUMENTS_DEL"
+	CodeNoEnoughArgumentsForExpireCommand      = "NO_ENOUGH_ARGUMENTS_EXPIRE"
+	CodeNoEnoughArgumentsForTTLCommand         = "NO_ENOUGH_ARGUMENTS_TTL"
+	CodeNoEnoughArgumentsForPersistCommand     = "NO_ENOUGH_ARGUMENTS_PERSIST"
+	CodeNoEnoughArgumentsForWatchCommand       = "NO_ENOUGH_ARGUMENTS_WATCH"
+	CodeNoEnoughArgumentsForAuthCommand        = "NO_ENOUGH_ARGUMENTS_AUTH"
+	CodeNoEnoughArgumentsForScanCommand        = "NO_ENOUGH_ARGUMENTS_SCAN"
+	CodeNoEnoughArgumentsForKeysCommand        = "NO_ENOUGH_ARGUMENTS_KEYS"
+	CodeNoEnoughArgumentsForRangeCommand       = "NO_ENOUGH_ARGUMENTS_RANGE"
+	CodeNoEnoughArgumentsForPrefixCommand      = "NO_ENOUGH_ARGUMENTS_PREFIX"
+	CodeNoEnoughArgumentsForIncrCommand        = "NO_ENOUGH_ARGUMENTS_INCR"
+	CodeNoEnoughArgumentsForDecrCommand        = "NO_ENOUGH_ARGUMENTS_DECR"
+	CodeNoEnoughArgumentsForIncrByCommand      = "NO_ENOUGH_ARGUMENTS_INCRBY"
+	CodeNoEnoughArgumentsForIncrByFloatCommand = "NO_ENOUGH_ARGUMENTS_INCRBYFLOAT"
+	CodeNoEnoughArgumentsForSetNXCommand       = "NO_ENOUGH_ARGUMENTS_SETNX"
+	CodeNoEnoughArgumentsForGetSetCommand      = "NO_ENOUGH_ARGUMENTS_GETSET"
+	CodeNoEnoughArgumentsForCASCommand         = "NO_ENOUGH_ARGUMENTS_CAS"
+	CodeNoEnoughArgumentsForMGetCommand        = "NO_ENOUGH_ARGUMENTS_MGET"
+	CodeNoEnoughArgumentsForMSetCommand        = "NO_ENOUGH_ARGUMENTS_MSET"
+	CodeNoEnoughArgumentsForMDelCommand        = "NO_ENOUGH_ARGUMENTS_MDEL"
+
+	CodeNestedMulti         = "NESTED_MULTI"
+	CodeExecWithoutMulti    = "EXEC_WITHOUT_MULTI"
+	CodeDiscardWithoutMulti = "DISCARD_WITHOUT_MULTI"
+	CodeWatchInsideMulti    = "WATCH_INSIDE_MULTI"
+	CodeTransactionAborted  = "TRANSACTION_ABORTED"
+	CodeUnknownInfoSection  = "UNKNOWN_INFO_SECTION"
+	CodeNotInteger          = "NOT_INTEGER"
+	CodeNotFloat            = "NOT_FLOAT"
+	CodeIncrementOverflow   = "INCREMENT_OVERFLOW"
+
+	CodeAuthRequired       = "AUTH_REQUIRED"
+	CodeAuthNotEnabled     = "AUTH_NOT_ENABLED"
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodePermissionDenied   = "PERMISSION_DENIED"
+
+	CodeOutOfMemory        = "OUT_OF_MEMORY"
+	CodeReadOnly           = "READ_ONLY"
+	CodeSnapshotsDisabled  = "SNAPSHOTS_DISABLED"
+	CodeSnapshotInProgress = "SNAPSHOT_IN_PROGRESS"
+	CodeNotOrdered         = "NOT_ORDERED"
+
+	CodeMessageTooLarge = "MESSAGE_TOO_LARGE"
+	CodeProtocol        = "PROTOCOL_ERROR"
+)
@@ -0,0 +1,416 @@
+// Package protocol is a binary framing protocol of simple-kv server: framing codec, handshake messages
+// and codes of server errors. It has no dependencies on server, so clients link only it.
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Version of framing protocol, client asks for it in handshake: "HELLO 2", server confirms it: "HI 2".
+// Server which doesn't support asked version replies plain "HI", then line protocol is used.
+const Version = 2
+
+// Handshake messages, BYE is sent by server before closing connection.
+const (
+	MessageHello = "HELLO"
+	MessageHi    = "HI"
+	MessageBye   = "BYE"
+)
+
+// Frame is a length of payload (4 bytes, big endian) followed by payload.
+//
+// Request payload: request ID (8 bytes), opcode (1 byte), number of arguments (4 bytes)
+// and arguments each prefixed with its length (4 bytes).
+//
+// Response payload: ID of request (8 bytes) and response. Response is a type (1 byte) followed by
+// status, value: length (4 bytes) and bytes; nil: nothing; integer: 8 bytes;
+// array: number of items (4 bytes, 0xFFFFFFFF for nil array) and items;
+// error: class, code and message each prefixed with its length (4 bytes);
+// map: number of entries (4 bytes) and entries, each is a key prefixed with its length (4 bytes) and response.
+//
+// Request ID 0 is reserved for messages which server sends by itself, e.g. BYE before closing connection.
+const (
+	lengthSize        = 4
+	requestHeaderSize = 8 + 1 + 4
+	nilArrayLength    = ^uint32(0)
+)
+
+type Opcode byte
+
+const (
+	// OpCommand carries command name as the first argument, so any command may be sent in frames
+	OpCommand Opcode = iota
+	OpGet
+	OpSet
+	OpDel
+)
+
+const (
+	typeStatus byte = iota + 1
+	typeValue
+	typeNil
+	typeInteger
+	typeArray
+	typeError
+	typeMap
+)
+
+var (
+	ErrProtocol        = errors.New("frame error: protocol error")
+	ErrMessageTooLarge = errors.New("frame error: message is too large")
+	ErrUnknownOpcode   = errors.New("frame error: unknown opcode")
+)
+
+type Request struct {
+	ID   uint64
+	Op   Opcode
+	Args []string
+}
+
+// Command returns command name with arguments of request.
+func (r Request) Command() ([]string, error) {
+	var name string
+	switch r.Op {
+	case OpCommand:
+		if len(r.Args) == 0 {
+			return nil, fmt.Errorf("%w: command name is missing", ErrProtocol)
+		}
+		return r.Args, nil
+	case OpGet:
+		name = "GET"
+	case OpSet:
+		name = "SET"
+	case OpDel:
+		name = "DEL"
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownOpcode, r.Op)
+	}
+
+	return append([]string{name}, r.Args...), nil
+}
+
+// NewRequest makes request of command name and arguments, dedicated opcodes are used for GET, SET and DEL.
+func NewRequest(id uint64, args []string) Request {
+	if len(args) == 0 {
+		return Request{ID: id, Op: OpCommand}
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		return Request{ID: id, Op: OpGet, Args: args[1:]}
+	case "SET":
+		return Request{ID: id, Op: OpSet, Args: args[1:]}
+	case "DEL":
+		return Request{ID: id, Op: OpDel, Args: args[1:]}
+	default:
+		return Request{ID: id, Op: OpCommand, Args: args}
+	}
+}
+
+type Reader struct {
+	r              *bufio.Reader
+	maxMessageSize int
+}
+
+func NewReader(r io.Reader, maxMessageSize int) *Reader {
+	return &Reader{
+		r:              bufio.NewReader(r),
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Buffered returns number of bytes already read from connection, e.g. pipelined requests.
+func (r *Reader) Buffered() int {
+	return r.r.Buffered()
+}
+
+// ReadRequest reads next request. If request is larger than max message size, it is skipped
+// and ErrMessageTooLarge is returned with request ID, so connection may be used further.
+func (r *Reader) ReadRequest() (Request, error) {
+	size, err := r.readLength()
+	if err != nil {
+		return Request{}, err
+	}
+	if size < requestHeaderSize {
+		return Request{}, fmt.Errorf("%w: request is too short", ErrProtocol)
+	}
+
+	if r.maxMessageSize > 0 && size > r.maxMessageSize {
+		var id [8]byte
+		if _, err := io.ReadFull(r.r, id[:]); err != nil {
+			return Request{}, err
+		}
+		if _, err := io.CopyN(io.Discard, r.r, int64(size-len(id))); err != nil {
+			return Request{}, err
+		}
+		return Request{ID: binary.BigEndian.Uint64(id[:])}, ErrMessageTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		return Request{}, err
+	}
+
+	req := Request{
+		ID: binary.BigEndian.Uint64(payload[0:8]),
+		Op: Opcode(payload[8]),
+	}
+	argc := binary.BigEndian.Uint32(payload[9:13])
+	payload = payload[requestHeaderSize:]
+
+	// every argument takes at least 4 bytes of its length
+	if uint64(argc)*lengthSize > uint64(len(payload)) {
+		return req, fmt.Errorf("%w: invalid number of arguments", ErrProtocol)
+	}
+
+	req.Args = make([]string, 0, argc)
+	for i := uint32(0); i < argc; i++ {
+		var arg string
+		arg, payload, err = readString(payload)
+		if err != nil {
+			return req, err
+		}
+		req.Args = append(req.Args, arg)
+	}
+
+	if len(payload) != 0 {
+		return req, fmt.Errorf("%w: unexpected bytes after arguments", ErrProtocol)
+	}
+
+	return req, nil
+}
+
+// ReadResponse reads next response and returns it with ID of request.
+func (r *Reader) ReadResponse() (uint64, Response, error) {
+	size, err := r.readLength()
+	if err != nil {
+		return 0, Response{}, err
+	}
+	if size < 8+1 {
+		return 0, Response{}, fmt.Errorf("%w: response is too short", ErrProtocol)
+	}
+	if r.maxMessageSize > 0 && size > r.maxMessageSize {
+		return 0, Response{}, ErrMessageTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		return 0, Response{}, err
+	}
+
+	id := binary.BigEndian.Uint64(payload[0:8])
+	response, rest, err := decodeResponse(payload[8:])
+	if err != nil {
+		return id, Response{}, err
+	}
+	if len(rest) != 0 {
+		return id, Response{}, fmt.Errorf("%w: unexpected bytes after response", ErrProtocol)
+	}
+
+	return id, response, nil
+}
+
+func (r *Reader) readLength() (int, error) {
+	var header [lengthSize]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint32(header[:])), nil
+}
+
+type Writer struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: bufio.NewWriter(w),
+	}
+}
+
+func (w *Writer) WriteRequest(req Request) error {
+	buf := append(w.buf[:0], 0, 0, 0, 0)
+	buf = binary.BigEndian.AppendUint64(buf, req.ID)
+	buf = append(buf, byte(req.Op))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(req.Args)))
+	for _, arg := range req.Args {
+		buf = appendString(buf, arg)
+	}
+
+	return w.writeFrame(buf)
+}
+
+func (w *Writer) WriteResponse(id uint64, response Response) error {
+	buf := append(w.buf[:0], 0, 0, 0, 0)
+	buf = binary.BigEndian.AppendUint64(buf, id)
+	buf = encodeResponse(buf, response)
+
+	return w.writeFrame(buf)
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) writeFrame(buf []byte) error {
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-lengthSize))
+	w.buf = buf
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func encodeResponse(buf []byte, response Response) []byte {
+	switch response.Type {
+	case StatusResponse:
+		buf = append(buf, typeStatus)
+		buf = appendString(buf, response.Status)
+	case ValueResponse:
+		buf = append(buf, typeValue)
+		buf = appendString(buf, response.Value)
+	case NilResponse:
+		buf = append(buf, typeNil)
+	case IntegerResponse:
+		buf = append(buf, typeInteger)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(response.Integer))
+	case ArrayResponse:
+		buf = append(buf, typeArray)
+		if response.Array == nil {
+			return binary.BigEndian.AppendUint32(buf, nilArrayLength)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(response.Array)))
+		for _, item := range response.Array {
+			buf = encodeResponse(buf, item)
+		}
+	case ErrorResponse:
+		buf = append(buf, typeError)
+		buf = appendString(buf, response.Class)
+		buf = appendString(buf, response.Code)
+		buf = appendString(buf, response.Message)
+	case MapResponse:
+		buf = append(buf, typeMap)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(response.Map)))
+		for _, entry := range response.Map {
+			buf = appendString(buf, entry.Key)
+			buf = encodeResponse(buf, entry.Value)
+		}
+	}
+
+	return buf
+}
+
+func decodeResponse(payload []byte) (Response, []byte, error) {
+	if len(payload) == 0 {
+		return Response{}, nil, fmt.Errorf("%w: response type is missing", ErrProtocol)
+	}
+
+	var (
+		typ      = payload[0]
+		response Response
+		err      error
+	)
+	payload = payload[1:]
+
+	switch typ {
+	case typeStatus:
+		response.Type = StatusResponse
+		response.Status, payload, err = readString(payload)
+		return response, payload, err
+	case typeValue:
+		response.Type = ValueResponse
+		response.Value, payload, err = readString(payload)
+		return response, payload, err
+	case typeNil:
+		return Response{Type: NilResponse}, payload, nil
+	case typeInteger:
+		if len(payload) < 8 {
+			return Response{}, nil, fmt.Errorf("%w: integer is truncated", ErrProtocol)
+		}
+		return Response{Type: IntegerResponse, Integer: int64(binary.BigEndian.Uint64(payload))}, payload[8:], nil
+	case typeArray:
+		if len(payload) < lengthSize {
+			return Response{}, nil, fmt.Errorf("%w: array length is truncated", ErrProtocol)
+		}
+		count := binary.BigEndian.Uint32(payload)
+		payload = payload[lengthSize:]
+		if count == nilArrayLength {
+			return Response{Type: ArrayResponse}, payload, nil
+		}
+		// every item takes at least 1 byte of its type
+		if uint64(count) > uint64(len(payload)) {
+			return Response{}, nil, fmt.Errorf("%w: invalid array length", ErrProtocol)
+		}
+		items := make([]Response, 0, count)
+		for i := uint32(0); i < count; i++ {
+			var item Response
+			item, payload, err = decodeResponse(payload)
+			if err != nil {
+				return Response{}, nil, err
+			}
+			items = append(items, item)
+		}
+		return Response{Type: ArrayResponse, Array: items}, payload, nil
+	case typeError:
+		response.Type = ErrorResponse
+		if response.Class, payload, err = readString(payload); err != nil {
+			return Response{}, nil, err
+		}
+		if response.Code, payload, err = readString(payload); err != nil {
+			return Response{}, nil, err
+		}
+		if response.Message, payload, err = readString(payload); err != nil {
+			return Response{}, nil, err
+		}
+		return response, payload, nil
+	case typeMap:
+		if len(payload) < lengthSize {
+			return Response{}, nil, fmt.Errorf("%w: map length is truncated", ErrProtocol)
+		}
+		count := binary.BigEndian.Uint32(payload)
+		payload = payload[lengthSize:]
+		// every entry takes at least 4 bytes of key length and 1 byte of value type
+		if uint64(count)*(lengthSize+1) > uint64(len(payload)) {
+			return Response{}, nil, fmt.Errorf("%w: invalid map length", ErrProtocol)
+		}
+		entries := make([]MapEntry, 0, count)
+		for i := uint32(0); i < count; i++ {
+			var entry MapEntry
+			if entry.Key, payload, err = readString(payload); err != nil {
+				return Response{}, nil, err
+			}
+			if entry.Value, payload, err = decodeResponse(payload); err != nil {
+				return Response{}, nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return Response{Type: MapResponse, Map: entries}, payload, nil
+	default:
+		return Response{}, nil, fmt.Errorf("%w: unknown response type %d", ErrProtocol, typ)
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+func readString(payload []byte) (string, []byte, error) {
+	if len(payload) < lengthSize {
+		return "", nil, fmt.Errorf("%w: length is truncated", ErrProtocol)
+	}
+
+	size := binary.BigEndian.Uint32(payload)
+	payload = payload[lengthSize:]
+	if uint64(size) > uint64(len(payload)) {
+		return "", nil, fmt.Errorf("%w: string is truncated", ErrProtocol)
+	}
+
+	return string(payload[:size]), payload[size:], nil
+}
@@ -0,0 +1,172 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_RoundTrip(t *testing.T) {
+	requests := []Request{
+		{ID: 1, Op: OpSet, Args: []string{"key", "line1\nline2\x00\xff"}},
+		{ID: 2, Op: OpGet, Args: []string{"key"}},
+		{ID: 3, Op: OpCommand, Args: []string{"MULTI"}},
+		{ID: 4, Op: OpDel, Args: []string{""}},
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	for _, req := range requests {
+		require.NoError(t, writer.WriteRequest(req))
+	}
+	require.NoError(t, writer.Flush())
+
+	reader := NewReader(&buf, 1024)
+	for _, want := range requests {
+		got, err := reader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := reader.ReadRequest()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestResponse_RoundTrip(t *testing.T) {
+	value := func(v string) Response { return Response{Type: ValueResponse, Value: v} }
+	integer := func(n int64) Response { return Response{Type: IntegerResponse, Integer: n} }
+	ok := Response{Type: StatusResponse, Status: "OK"}
+	responses := []Response{
+		ok,
+		value("line1\nline2\x00"),
+		value(""),
+		{Type: NilResponse},
+		integer(-2),
+		{Type: ArrayResponse, Array: []Response{}},
+		{Type: ArrayResponse},
+		{Type: ArrayResponse, Array: []Response{ok, {Type: ArrayResponse, Array: []Response{integer(1), {Type: NilResponse}}}}},
+		{Type: MapResponse, Map: []MapEntry{}},
+		{Type: MapResponse, Map: []MapEntry{
+			{Key: "server", Value: Response{Type: MapResponse, Map: []MapEntry{{Key: "version", Value: value("0.1.0")}}}},
+			{Key: "keys", Value: integer(3)},
+		}},
+		{Type: ErrorResponse, Class: "ERR", Code: CodeNotInteger, Message: "value is not an integer"},
+		{Type: ErrorResponse, Class: "ERR", Message: "something failed"},
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	for i, response := range responses {
+		require.NoError(t, writer.WriteResponse(uint64(i), response))
+	}
+	require.NoError(t, writer.Flush())
+
+	reader := NewReader(&buf, 0)
+	for i, want := range responses {
+		id, got, err := reader.ReadResponse()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i), id)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestReader_MessageTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	require.NoError(t, writer.WriteRequest(Request{ID: 7, Op: OpSet, Args: []string{"key", string(make([]byte, 100))}}))
+	require.NoError(t, writer.WriteRequest(Request{ID: 8, Op: OpGet, Args: []string{"key"}}))
+	require.NoError(t, writer.Flush())
+
+	reader := NewReader(&buf, 64)
+
+	// too large request is skipped, but its ID is known to reply with error
+	req, err := reader.ReadRequest()
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Equal(t, uint64(7), req.ID)
+
+	req, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, Request{ID: 8, Op: OpGet, Args: []string{"key"}}, req)
+}
+
+func TestReader_ProtocolError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name:  "request is too short",
+			input: []byte{0, 0, 0, 2, 0, 1},
+		},
+		{
+			name:  "argument is truncated",
+			input: []byte{0, 0, 0, 18, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 1, 0, 0, 0, 9, 'k'},
+		},
+		{
+			name:  "too many arguments",
+			input: []byte{0, 0, 0, 13, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 9},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(tt.input), 1024).ReadRequest()
+			assert.ErrorIs(t, err, ErrProtocol)
+		})
+	}
+}
+
+func TestRequest_Command(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     Request
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "get",
+			req:  Request{Op: OpGet, Args: []string{"key"}},
+			want: []string{"GET", "key"},
+		},
+		{
+			name: "set",
+			req:  Request{Op: OpSet, Args: []string{"key", "value"}},
+			want: []string{"SET", "key", "value"},
+		},
+		{
+			name: "del",
+			req:  Request{Op: OpDel, Args: []string{"key"}},
+			want: []string{"DEL", "key"},
+		},
+		{
+			name: "command",
+			req:  Request{Op: OpCommand, Args: []string{"TTL", "key"}},
+			want: []string{"TTL", "key"},
+		},
+		{
+			name:    "command without name",
+			req:     Request{Op: OpCommand},
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "unknown opcode",
+			req:     Request{Op: 200, Args: []string{"key"}},
+			wantErr: ErrUnknownOpcode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.Command()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package protocol
+
+type ResponseType int
+
+const (
+	// StatusResponse is a short status message, e.g. OK or QUEUED
+	StatusResponse ResponseType = iota + 1
+	// ValueResponse is a stored value, it may contain any bytes
+	ValueResponse
+	// NilResponse means there is no value, e.g. key doesn't exist
+	NilResponse
+	IntegerResponse
+	// ArrayResponse is a list of responses, nil list means there is no array at all
+	ArrayResponse
+	ErrorResponse
+	// MapResponse is a list of named responses in fixed order, e.g. sections of INFO
+	MapResponse
+)
+
+// Response is a typed result of command as it is sent in frames, only fields of its type are set.
+type Response struct {
+	Type    ResponseType
+	Status  string
+	Value   string
+	Integer int64
+	Array   []Response
+	Map     []MapEntry
+	// Class is a class of error, e.g. ERR or OOM, it is the first word of error message in Redis protocol
+	Class string
+	// Code identifies particular error, it is empty for errors without code
+	Code    string
+	Message string
+}
+
+// MapEntry is a named item of map response.
+type MapEntry struct {
+	Key   string
+	Value Response
+}